@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// defaultAccount names the only account when N26_ACCOUNTS is not set. Data stored before several
+// accounts were supported belongs to it.
+const defaultAccount = "default"
+
+// accountNamePattern restricts account names to what fits an environment variable prefix
+var accountNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_]*$`)
+
+// globalVariables cannot be set per account, every account shares the database, its accounts list
+// and the timezone its dates are read in
+var globalVariables = []string{"DB_CONN", "N26_ACCOUNTS", "ACCOUNT", "AUTO_MIGRATE", "N26_TIMEZONE"}
+
+// loadAccountNames reads N26_ACCOUNTS, a comma-separated list of account names (default: one
+// account named "default" configured by the plain variables)
+func loadAccountNames() ([]string, error) {
+	value := strings.TrimSpace(os.Getenv("N26_ACCOUNTS"))
+	if value == "" {
+		return []string{defaultAccount}, nil
+	}
+
+	var names []string
+	for _, part := range strings.Split(value, ",") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if !accountNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid account name %q in N26_ACCOUNTS (use letters, digits and underscores)", part)
+		}
+		if slices.Contains(names, name) {
+			return nil, fmt.Errorf("account %q is listed twice in N26_ACCOUNTS", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// selectedAccount returns the account a command works on: the one named in ACCOUNT, or the only
+// configured one
+func selectedAccount() (string, error) {
+	names, err := loadAccountNames()
+	if err != nil {
+		return "", err
+	}
+
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("ACCOUNT")))
+	switch {
+	case name == "" && len(names) == 1:
+		return names[0], nil
+	case name == "":
+		return "", fmt.Errorf("several accounts are configured, select one with ACCOUNT (one of %s)", strings.Join(names, ", "))
+	case !slices.Contains(names, name):
+		return "", fmt.Errorf("unknown account %q in ACCOUNT (configured: %s)", name, strings.Join(names, ", "))
+	}
+	return name, nil
+}
+
+// accountEnvPrefix returns the prefix of the per-account variables of an account, e.g. "ACCOUNT_ALICE_"
+func accountEnvPrefix(name string) string {
+	return "ACCOUNT_" + strings.ToUpper(name) + "_"
+}
+
+// AccountConfig holds the settings of one account: every ACCOUNT_<NAME>_<VAR> sets <VAR> for the
+// account, variables it does not set keep their global value. The overrides are resolved once and
+// looked up here; the process environment is never changed, so one account's secrets and settings
+// cannot leak into another account's run.
+type AccountConfig struct {
+	Name      string
+	overrides map[string]string
+	hidden    map[string]bool // Global sources of the secrets the account sets a source of its own for
+}
+
+// globalConfig reads the plain variables only, for the settings shared by every account
+var globalConfig = AccountConfig{Name: defaultAccount}
+
+// loadAccountConfig resolves the settings of an account from its ACCOUNT_<NAME>_ variables. Setting
+// one source of a secret (NAME, NAME_FILE or NAME_COMMAND) hides the other sources of the global
+// value, so an account can pick a different source than the default.
+func loadAccountConfig(name string) AccountConfig {
+	prefix := accountEnvPrefix(name)
+	config := AccountConfig{Name: name, overrides: make(map[string]string), hidden: make(map[string]bool)}
+	for _, entry := range os.Environ() {
+		key, value, _ := strings.Cut(entry, "=")
+		variable, ok := strings.CutPrefix(key, prefix)
+		if !ok || variable == "" || slices.Contains(globalVariables, variable) {
+			continue
+		}
+
+		base := strings.TrimSuffix(strings.TrimSuffix(variable, "_FILE"), "_COMMAND")
+		for _, source := range secretSources(base) {
+			config.hidden[source] = true
+		}
+		config.overrides[variable] = value
+	}
+	return config
+}
+
+// Getenv returns the value of a variable for the account, empty when neither the account nor the
+// environment sets it
+func (c AccountConfig) Getenv(variable string) string {
+	if value, ok := c.overrides[variable]; ok {
+		return value
+	}
+	if c.hidden[variable] {
+		return ""
+	}
+	return os.Getenv(variable)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestLoadAccountNames(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: "", want: []string{defaultAccount}},
+		{value: "Alice, bob_2", want: []string{"alice", "bob_2"}},
+		{value: "alice,,bob", wantErr: true},
+		{value: "alice,ALICE", wantErr: true},
+		{value: "alice-smith", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Setenv("N26_ACCOUNTS", tt.value)
+		got, err := loadAccountNames()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("loadAccountNames(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("loadAccountNames(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSelectedAccount(t *testing.T) {
+	t.Setenv("N26_ACCOUNTS", "")
+	t.Setenv("ACCOUNT", "")
+	if got, err := selectedAccount(); err != nil || got != defaultAccount {
+		t.Errorf("selectedAccount() = %q, %v, want %q", got, err, defaultAccount)
+	}
+
+	t.Setenv("N26_ACCOUNTS", "alice,bob")
+	if _, err := selectedAccount(); err == nil {
+		t.Error("selectedAccount() with several accounts and no ACCOUNT succeeded, want error")
+	}
+	t.Setenv("ACCOUNT", "Bob")
+	if got, err := selectedAccount(); err != nil || got != "bob" {
+		t.Errorf("selectedAccount() = %q, %v, want %q", got, err, "bob")
+	}
+	t.Setenv("ACCOUNT", "carol")
+	if _, err := selectedAccount(); err == nil {
+		t.Error("selectedAccount() with an unknown account succeeded, want error")
+	}
+}
+
+func TestLoadAccountConfig(t *testing.T) {
+	t.Setenv("N26_EMAIL", "global@example.com")
+	t.Setenv("N26_PASSWORD", "global-secret")
+	t.Setenv("DISCORD_WEBHOOK_URL", "https://example.com/global")
+	t.Setenv("ACCOUNT_ALICE_N26_EMAIL", "alice@example.com")
+	t.Setenv("ACCOUNT_ALICE_N26_PASSWORD_FILE", "/run/secrets/alice")
+	t.Setenv("ACCOUNT_ALICE_DB_CONN", "sqlite://alice.db")
+	t.Setenv("DB_CONN", "sqlite://shared.db")
+
+	config := loadAccountConfig("alice")
+	if got := config.Getenv("N26_EMAIL"); got != "alice@example.com" {
+		t.Errorf("N26_EMAIL = %q, want the account's value", got)
+	}
+	if got := config.Getenv("N26_PASSWORD_FILE"); got != "/run/secrets/alice" {
+		t.Errorf("N26_PASSWORD_FILE = %q, want the account's value", got)
+	}
+	if got := config.Getenv("N26_PASSWORD"); got != "" {
+		t.Errorf("N26_PASSWORD = %q, want the global source hidden by the account's file", got)
+	}
+	if got := config.Getenv("DISCORD_WEBHOOK_URL"); got != "https://example.com/global" {
+		t.Errorf("DISCORD_WEBHOOK_URL = %q, want the global value", got)
+	}
+	if got := config.Getenv("DB_CONN"); got != "sqlite://shared.db" {
+		t.Errorf("DB_CONN = %q, want the global value, it cannot be set per account", got)
+	}
+
+	// The process environment is left alone
+	if got := os.Getenv("N26_EMAIL"); got != "global@example.com" {
+		t.Errorf("os N26_EMAIL = %q, want the global value", got)
+	}
+	if got := os.Getenv("N26_PASSWORD"); got != "global-secret" {
+		t.Errorf("os N26_PASSWORD = %q, want the global value", got)
+	}
+	if _, ok := os.LookupEnv("N26_PASSWORD_FILE"); ok {
+		t.Error("os N26_PASSWORD_FILE is set, want the account's secret kept out of the environment")
+	}
+	if got := loadAccountConfig("bob").Getenv("N26_EMAIL"); got != "global@example.com" {
+		t.Errorf("bob N26_EMAIL = %q, want the global value", got)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// AlertRepository remembers which alerts were sent to which notification channel,
+// so budget, balance and similar alerts are sent once per channel
+type AlertRepository interface {
+	FilterUnsent(channel string, keys []string) ([]string, error)
+	MarkAlertsSent(channel string, keys []string) error
+}
+
+// SQLAlertRepository implements AlertRepository on both PostgreSQL and SQLite,
+// the queries only use syntax the two have in common
+type SQLAlertRepository struct {
+	db      *sql.DB
+	account string
+}
+
+// NewSQLAlertRepository creates an alert repository for an account on a migrated database
+func NewSQLAlertRepository(db *sql.DB, account string) *SQLAlertRepository {
+	return &SQLAlertRepository{db: db, account: account}
+}
+
+// FilterUnsent returns the alert keys not sent to the channel yet, in their original order
+func (r *SQLAlertRepository) FilterUnsent(channel string, keys []string) ([]string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	// Only a handful of alerts are checked per run, one lookup each keeps the query portable
+	stmt, err := r.db.Prepare(`SELECT COUNT(*) FROM sent_alerts WHERE account = $1 AND channel = $2 AND alert_key = $3`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare sent alert lookup: %w", err)
+	}
+	defer stmt.Close()
+
+	var unsent []string
+	for _, key := range keys {
+		var count int
+		if err := stmt.QueryRow(r.account, channel, key).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to check sent alert: %w", err)
+		}
+		if count == 0 {
+			unsent = append(unsent, key)
+		}
+	}
+	return unsent, nil
+}
+
+// MarkAlertsSent records that the alerts were sent to the channel
+func (r *SQLAlertRepository) MarkAlertsSent(channel string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO sent_alerts (account, alert_key, channel, sent_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+		ON CONFLICT (account, alert_key, channel) DO NOTHING
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare alert insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, key := range keys {
+		if _, err := stmt.Exec(r.account, key, channel); err != nil {
+			return fmt.Errorf("failed to mark alert as sent: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit sent alerts: %w", err)
+	}
+	return nil
+}
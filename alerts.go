@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// AlertKind identifies what an alert is about, channels style alerts by kind
+type AlertKind string
+
+const (
+	AlertBudget       AlertKind = "budget"       // A category crossed a budget threshold
+	AlertSubscription AlertKind = "subscription" // A recurring payment is missing, early or more expensive
+	AlertAnomaly      AlertKind = "anomaly"      // A new transaction stands out from the history
+	AlertBalance      AlertKind = "balance"      // The balance is low or dropped sharply
+	AlertIntegrity    AlertKind = "integrity"    // The parsed transactions do not add up to the statement balances
+	AlertDigest       AlertKind = "digest"       // Weekly or monthly spending summary
+)
+
+// Alert is a message about the account as a whole rather than individual transactions
+type Alert struct {
+	Kind  AlertKind
+	Title string
+	Lines []string
+}
+
+// AlertItem is one line of an alert. Key identifies it so it is sent once per channel,
+// Covers lists keys that count as sent along with it, e.g. lower budget thresholds.
+type AlertItem struct {
+	Key    string
+	Line   string
+	Covers []string
+}
+
+// sendAlerts sends every channel one alert with the items it has not received yet.
+// A failed channel gets the items again on the next run, the other channels do not.
+func sendAlerts(alertRepo AlertRepository, notifiers []Notifier, kind AlertKind, title string, items []AlertItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = item.Key
+	}
+
+	var failed []string
+	for _, notifier := range notifiers {
+		channel := notifier.Channel()
+		unsent, err := alertRepo.FilterUnsent(channel, keys)
+		if err != nil {
+			return err
+		}
+		if len(unsent) == 0 {
+			continue
+		}
+
+		isUnsent := make(map[string]bool, len(unsent))
+		for _, key := range unsent {
+			isUnsent[key] = true
+		}
+		var lines, sent []string
+		for _, item := range items {
+			if isUnsent[item.Key] {
+				lines = append(lines, item.Line)
+				sent = append(sent, item.Key)
+				sent = append(sent, item.Covers...)
+			}
+		}
+
+		if err := notifier.SendAlert(Alert{Kind: kind, Title: title, Lines: lines}); err != nil {
+			log.Printf("Warning: Failed to send %s alert to %s: %v", kind, channel, err)
+			failed = append(failed, channel)
+			continue
+		}
+		fmt.Printf("Sent %d %s alerts to %s\n", len(lines), kind, channel)
+		if err := alertRepo.MarkAlertsSent(channel, sent); err != nil {
+			// Not fatal, but the alerts will be sent to this channel again
+			log.Printf("Warning: Failed to record %s alerts: %v", channel, err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%s alert to %s failed, it will be retried on the next run", kind, strings.Join(failed, ", "))
+	}
+	return nil
+}
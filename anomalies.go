@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// anomalyHistoryDays is how much history new transactions are compared with
+const anomalyHistoryDays = 365
+
+// anomalyMinSamples is how many earlier transactions a partner or category needs
+// before its amounts and weekdays are considered usual
+const anomalyMinSamples = 5
+
+// anomalyMinHistory is how many stored transactions are needed before an unknown
+// merchant stands out, so the first runs do not flag every partner
+const anomalyMinHistory = 20
+
+// Weights of the anomaly signals, a transaction is an outlier when they add up to the minimum score
+const (
+	anomalyAmountWeight   = 1.0 // Amount far above the partner's or category's usual amounts
+	anomalyMerchantWeight = 0.5 // Never seen this merchant
+	anomalyWeekdayWeight  = 0.5 // Never seen this partner on this weekday
+)
+
+// AnomalyConfig sets how unusual a transaction has to be to be reported
+type AnomalyConfig struct {
+	ZScore   float64 // Standard deviations above the mean amount that count as unusual
+	MinScore float64 // Sum of signal weights that makes a transaction an outlier
+}
+
+// loadAnomalyConfig reads ANOMALY_ZSCORE (default 3) and ANOMALY_MIN_SCORE (default 1)
+func loadAnomalyConfig(config AccountConfig) AnomalyConfig {
+	return AnomalyConfig{
+		ZScore:   config.envFloat("ANOMALY_ZSCORE", 3),
+		MinScore: config.envFloat("ANOMALY_MIN_SCORE", 1),
+	}
+}
+
+// Anomaly is a new transaction that stands out from the history, with the reasons why
+type Anomaly struct {
+	Record  StatementRecord
+	Score   float64
+	Reasons []string
+}
+
+// anomalyHistory is the stored history summarized per partner and category
+type anomalyHistory struct {
+	partnerAmounts  map[string][]float64 // Signed amounts by normalized partner
+	partnerWeekdays map[string]map[time.Weekday]int
+	categoryAmounts map[string][]float64 // Signed amounts by lower-case category
+	transactions    int
+}
+
+// newAnomalyHistory summarizes the stored transactions, leaving out removed ones and the given keys
+func newAnomalyHistory(stored []StoredTransaction, exclude map[string]bool) anomalyHistory {
+	h := anomalyHistory{
+		partnerAmounts:  make(map[string][]float64),
+		partnerWeekdays: make(map[string]map[time.Weekday]int),
+		categoryAmounts: make(map[string][]float64),
+	}
+	for _, t := range stored {
+		if t.RemovedAt != nil || exclude[t.Key] {
+			continue
+		}
+		amount := t.Amount.Float()
+		partner := normalizePartnerName(t.PartnerName)
+		h.partnerAmounts[partner] = append(h.partnerAmounts[partner], amount)
+		if h.partnerWeekdays[partner] == nil {
+			h.partnerWeekdays[partner] = make(map[time.Weekday]int)
+		}
+		h.partnerWeekdays[partner][t.BookingDate.Weekday()]++
+		if t.Category != "" && t.Category != uncategorized {
+			category := strings.ToLower(t.Category)
+			h.categoryAmounts[category] = append(h.categoryAmounts[category], amount)
+		}
+		h.transactions++
+	}
+	return h
+}
+
+// scoreTransactions compares new transactions with the stored history and returns the outliers:
+// amounts far above what the partner (or, for rarely seen partners, the category) usually
+// costs, merchants never seen before and partners charging on an unusual weekday.
+// Statements only carry dates, so the time of day cannot be compared.
+func scoreTransactions(records []StatementRecord, stored []StoredTransaction, config AnomalyConfig) []Anomaly {
+	exclude := make(map[string]bool, len(records))
+	for _, record := range records {
+		exclude[record.Key] = true
+	}
+	history := newAnomalyHistory(stored, exclude)
+
+	var anomalies []Anomaly
+	for _, record := range records {
+		amount := record.Amount.Float()
+		partner := normalizePartnerName(record.PartnerName)
+		anomaly := Anomaly{Record: record}
+
+		partnerAmounts := sameSignAmounts(history.partnerAmounts[partner], amount)
+		if len(partnerAmounts) >= anomalyMinSamples {
+			if z, mean := amountZScore(amount, partnerAmounts); z >= config.ZScore {
+				anomaly.Score += anomalyAmountWeight
+				anomaly.Reasons = append(anomaly.Reasons,
+					fmt.Sprintf("%.1fσ above the usual `%s` for this partner", z, moneyFromFloat(mean, record.Amount.Currency)))
+			}
+		} else if category := strings.ToLower(record.Category); category != "" && record.Category != uncategorized {
+			categoryAmounts := sameSignAmounts(history.categoryAmounts[category], amount)
+			if len(categoryAmounts) >= anomalyMinSamples {
+				if z, mean := amountZScore(amount, categoryAmounts); z >= config.ZScore {
+					anomaly.Score += anomalyAmountWeight
+					anomaly.Reasons = append(anomaly.Reasons,
+						fmt.Sprintf("%.1fσ above the usual `%s` for %s", z, moneyFromFloat(mean, record.Amount.Currency), record.Category))
+				}
+			}
+		}
+
+		if len(history.partnerAmounts[partner]) == 0 && history.transactions >= anomalyMinHistory {
+			anomaly.Score += anomalyMerchantWeight
+			anomaly.Reasons = append(anomaly.Reasons, "first transaction with this merchant")
+		}
+
+		if weekdays := history.partnerWeekdays[partner]; len(history.partnerAmounts[partner]) >= anomalyMinSamples {
+			if weekday := record.BookingDate.Weekday(); !record.BookingDate.IsZero() && weekdays[weekday] == 0 {
+				anomaly.Score += anomalyWeekdayWeight
+				anomaly.Reasons = append(anomaly.Reasons, fmt.Sprintf("first transaction with this partner on a %s", weekday))
+			}
+		}
+
+		if anomaly.Score >= config.MinScore && len(anomaly.Reasons) > 0 {
+			anomalies = append(anomalies, anomaly)
+		}
+	}
+	return anomalies
+}
+
+// sameSignAmounts returns the amounts with the same sign as amount, as absolute values
+func sameSignAmounts(amounts []float64, amount float64) []float64 {
+	var same []float64
+	for _, a := range amounts {
+		if math.Signbit(a) == math.Signbit(amount) && a != 0 {
+			same = append(same, math.Abs(a))
+		}
+	}
+	return same
+}
+
+// amountZScore returns how many standard deviations the absolute amount lies above the mean
+// of the samples, and the mean. The deviation is at least 5% of the mean, so a price change
+// of a fixed-price partner stands out without every cent counting as an outlier.
+func amountZScore(amount float64, samples []float64) (float64, float64) {
+	var sum float64
+	for _, s := range samples {
+		sum += s
+	}
+	mean := sum / float64(len(samples))
+
+	var squares float64
+	for _, s := range samples {
+		squares += (s - mean) * (s - mean)
+	}
+	deviation := math.Max(math.Max(math.Sqrt(squares/float64(len(samples))), 0.05*mean), 0.01)
+	return (math.Abs(amount) - mean) / deviation, mean
+}
+
+// anomalyAlertItems formats one alert line per outlier, keyed by its statement
+func anomalyAlertItems(anomalies []Anomaly) []AlertItem {
+	items := make([]AlertItem, len(anomalies))
+	for i, anomaly := range anomalies {
+		items[i] = AlertItem{
+			Key:  "anomaly|" + anomaly.Record.Key,
+			Line: formatDiscordChange(TransactionChange{Kind: ChangeNew, Record: anomaly.Record}) + "\n⚠️ " + strings.Join(anomaly.Reasons, "; "),
+		}
+	}
+	return items
+}
+
+// checkAnomalies scores new transactions against the stored history and alerts every
+// channel about the outliers. Transactions are only scored in the run that finds them,
+// a channel that fails then misses the warning.
+func checkAnomalies(config AccountConfig, storage *Storage, notifiers []Notifier, records []StatementRecord, now time.Time) error {
+	if len(records) == 0 {
+		return nil
+	}
+	stored, err := listSpending(storage, now.AddDate(0, 0, -anomalyHistoryDays), now)
+	if err != nil {
+		return err
+	}
+
+	anomalies := scoreTransactions(records, stored, loadAnomalyConfig(config))
+	if len(anomalies) > 0 {
+		fmt.Printf("Found %d unusual transactions out of %d new transactions\n", len(anomalies), len(records))
+	}
+	return sendAlerts(storage.Alerts, notifiers, AlertAnomaly, "⚠️ N26 Unusual Transactions", anomalyAlertItems(anomalies))
+}
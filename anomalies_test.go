@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// anomalyTestHistory is five weeks of Monday groceries at around 40 EUR, weekly
+// coffee and enough other partners for unknown merchants to stand out
+func anomalyTestHistory() []StoredTransaction {
+	var history []StoredTransaction
+	monday := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	amounts := []string{"-38,00", "-42,50", "-40,10", "-39,90", "-41,00"}
+	for week := range 5 {
+		date := monday.AddDate(0, 0, 7*week)
+		history = append(history,
+			StoredTransaction{Key: "lidl" + date.Format("0102"), Transaction: Transaction{
+				BookingDate: date, PartnerName: "LIDL", Amount: eur(amounts[week]), Category: "Groceries"}},
+			StoredTransaction{Key: "rewe" + date.Format("0102"), Transaction: Transaction{
+				BookingDate: date.AddDate(0, 0, 2), PartnerName: "Rewe", Amount: eur("-2" + amounts[week][2:]), Category: "Groceries"}},
+			StoredTransaction{Key: "cafe" + date.Format("0102"), Transaction: Transaction{
+				BookingDate: date.AddDate(0, 0, 1), PartnerName: "Cafe Central", Amount: eur("-3,20"), Category: "Restaurants"}},
+			StoredTransaction{Key: "misc" + date.Format("0102"), Transaction: Transaction{
+				BookingDate: date.AddDate(0, 0, 3), PartnerName: "Shop " + date.Format("0102"), Amount: eur("-10,00")}},
+		)
+	}
+	return history
+}
+
+func TestScoreTransactions(t *testing.T) {
+	config := AnomalyConfig{ZScore: 3, MinScore: 1}
+	tests := []struct {
+		name    string
+		record  StatementRecord
+		reasons []string // Substrings of the expected reasons, none when not an outlier
+	}{
+		{"usual amount and weekday", StatementRecord{BookingDate: statementDay("13.10.2025"), PartnerName: "Lidl", Amount: eur("-43,00"), Category: "Groceries"}, nil},
+		{"partner amount outlier", StatementRecord{BookingDate: statementDay("13.10.2025"), PartnerName: "LIDL", Amount: eur("-180,00"), Category: "Groceries"},
+			[]string{"above the usual `40,30 EUR` for this partner"}},
+		{"weekday alone is not enough", StatementRecord{BookingDate: statementDay("12.10.2025"), PartnerName: "LIDL", Amount: eur("-40,00"), Category: "Groceries"}, nil},
+		{"amount and weekday", StatementRecord{BookingDate: statementDay("12.10.2025"), PartnerName: "LIDL", Amount: eur("-180,00"), Category: "Groceries"},
+			[]string{"for this partner", "on a Sunday"}},
+		{"new merchant alone is not enough", StatementRecord{BookingDate: statementDay("13.10.2025"), PartnerName: "Bakery", Amount: eur("-3,00"), Category: "Restaurants"}, nil},
+		{"new merchant above its category", StatementRecord{BookingDate: statementDay("13.10.2025"), PartnerName: "Fancy Restaurant", Amount: eur("-95,00"), Category: "Restaurants"},
+			[]string{"above the usual `3,20 EUR` for Restaurants", "first transaction with this merchant"}},
+		{"refunds are compared with refunds", StatementRecord{BookingDate: statementDay("13.10.2025"), PartnerName: "LIDL", Amount: eur("5,00"), Category: "Groceries"}, nil},
+	}
+
+	history := anomalyTestHistory()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.record.Key = "new"
+			anomalies := scoreTransactions([]StatementRecord{tt.record}, history, config)
+			if len(tt.reasons) == 0 {
+				if len(anomalies) != 0 {
+					t.Errorf("scoreTransactions() = %+v, want no outlier", anomalies)
+				}
+				return
+			}
+			if len(anomalies) != 1 {
+				t.Fatalf("scoreTransactions() = %+v, want one outlier", anomalies)
+			}
+			reasons := strings.Join(anomalies[0].Reasons, "; ")
+			for _, want := range tt.reasons {
+				if !strings.Contains(reasons, want) {
+					t.Errorf("reasons = %q, want %q", reasons, want)
+				}
+			}
+		})
+	}
+}
+
+func TestScoreTransactionsFlagsNewMerchantsWithLowerMinimum(t *testing.T) {
+	record := StatementRecord{Key: "new", BookingDate: statementDay("13.10.2025"), PartnerName: "Bakery", Amount: eur("-3,00"), Category: "Restaurants"}
+	anomalies := scoreTransactions([]StatementRecord{record}, anomalyTestHistory(), AnomalyConfig{ZScore: 3, MinScore: 0.5})
+	if len(anomalies) != 1 || anomalies[0].Reasons[0] != "first transaction with this merchant" {
+		t.Errorf("scoreTransactions() = %+v, want the new merchant flagged", anomalies)
+	}
+}
+
+func TestScoreTransactionsNeedsHistory(t *testing.T) {
+	record := StatementRecord{Key: "new", BookingDate: statementDay("13.10.2025"), PartnerName: "Bakery", Amount: eur("-400,00")}
+	if anomalies := scoreTransactions([]StatementRecord{record}, anomalyTestHistory()[:4], AnomalyConfig{ZScore: 3, MinScore: 0.5}); len(anomalies) != 0 {
+		t.Errorf("scoreTransactions() = %+v, want nothing flagged without enough history", anomalies)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// BalanceRepository stores the account balance read from each statement
+type BalanceRepository interface {
+	RecordSnapshot(snapshot BalanceSnapshot) (int64, error)
+	ListSnapshots(account string, from, to time.Time) ([]BalanceSnapshot, error)
+}
+
+// BalanceSnapshot is the balance of an account at the time a statement was downloaded
+type BalanceSnapshot struct {
+	ID          int64
+	Account     string // N26 account ID, empty when not configured
+	RecordedAt  time.Time
+	PeriodStart time.Time // Period of the statement the balance was read from
+	PeriodEnd   time.Time
+	Balance     Money
+	DocumentID  int64 // 0 when the statement document was not stored
+}
+
+// SQLBalanceRepository implements BalanceRepository on both PostgreSQL and SQLite,
+// the queries only use syntax the two have in common
+type SQLBalanceRepository struct {
+	db      *sql.DB
+	account string
+}
+
+// NewSQLBalanceRepository creates a balance repository for an account on a migrated database
+func NewSQLBalanceRepository(db *sql.DB, account string) *SQLBalanceRepository {
+	return &SQLBalanceRepository{db: db, account: account}
+}
+
+// RecordSnapshot stores a balance snapshot and returns its ID
+func (r *SQLBalanceRepository) RecordSnapshot(snapshot BalanceSnapshot) (int64, error) {
+	query := `
+		INSERT INTO balance_snapshots (account, account_id, recorded_at, period_start, period_end, balance, document_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+	var document any
+	if snapshot.DocumentID > 0 {
+		document = snapshot.DocumentID
+	}
+
+	var id int64
+	err := r.db.QueryRow(query, r.account, snapshot.Account, snapshot.RecordedAt.UTC(), sqlDate(snapshot.PeriodStart),
+		sqlDate(snapshot.PeriodEnd), snapshot.Balance.decimal(), document).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record balance snapshot: %w", err)
+	}
+	return id, nil
+}
+
+// ListSnapshots returns the snapshots of an account recorded between from and to (inclusive), oldest first
+func (r *SQLBalanceRepository) ListSnapshots(account string, from, to time.Time) ([]BalanceSnapshot, error) {
+	query := `
+		SELECT id, account_id, recorded_at, period_start, period_end, CAST(balance AS TEXT), document_id
+		FROM balance_snapshots
+		WHERE account = $1 AND account_id = $2 AND recorded_at >= $3 AND recorded_at <= $4
+		ORDER BY recorded_at ASC, id ASC
+	`
+	rows, err := r.db.Query(query, r.account, account, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list balance snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []BalanceSnapshot
+	for rows.Next() {
+		var s BalanceSnapshot
+		var periodStart, periodEnd sql.NullTime
+		var documentID sql.NullInt64
+		var balance string
+		if err := rows.Scan(&s.ID, &s.Account, &s.RecordedAt, &periodStart, &periodEnd, &balance, &documentID); err != nil {
+			return nil, fmt.Errorf("failed to scan balance snapshot: %w", err)
+		}
+		if s.Balance, err = parseMoney(balance, statementCurrency); err != nil {
+			return nil, fmt.Errorf("failed to read balance of snapshot %d: %w", s.ID, err)
+		}
+		s.RecordedAt = s.RecordedAt.UTC()
+		s.PeriodStart = periodStart.Time
+		s.PeriodEnd = periodEnd.Time
+		s.DocumentID = documentID.Int64
+		snapshots = append(snapshots, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list balance snapshots: %w", err)
+	}
+	return snapshots, nil
+}
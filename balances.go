@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// balanceAlertHistoryDays is how far back snapshots are read to find a low-balance streak
+const balanceAlertHistoryDays = 90
+
+// balanceDropWindow is the time within which a falling balance counts as one drop
+const balanceDropWindow = 24 * time.Hour
+
+// BalanceAlertConfig sets when the balance is reported, a nil threshold disables its alert
+type BalanceAlertConfig struct {
+	LowThreshold  *Money // Alert when the balance falls below this
+	DropThreshold *Money // Alert when the balance falls by more than this within a day
+}
+
+// loadBalanceAlertConfig reads BALANCE_LOW_THRESHOLD and BALANCE_DROP_THRESHOLD, both unset by default
+func loadBalanceAlertConfig(config AccountConfig) BalanceAlertConfig {
+	return BalanceAlertConfig{
+		LowThreshold:  config.envOptionalMoney("BALANCE_LOW_THRESHOLD"),
+		DropThreshold: config.envOptionalMoney("BALANCE_DROP_THRESHOLD"),
+	}
+}
+
+// envOptionalMoney reads an amount in the statement currency, returning nil when unset or invalid
+func (c AccountConfig) envOptionalMoney(name string) *Money {
+	value := c.Getenv(name)
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := parseMoney(value, statementCurrency)
+	if err != nil || parsed.currency() != statementCurrency {
+		log.Printf("Warning: Invalid value for %s (%q), ignoring it", name, value)
+		return nil
+	}
+	return &parsed
+}
+
+// recordBalanceSnapshot stores the balance of a recorded statement, if it has one
+func recordBalanceSnapshot(balanceRepo BalanceRepository, account string, statement *ParsedStatement, now time.Time) error {
+	if statement.Balance == nil {
+		return nil
+	}
+	_, err := balanceRepo.RecordSnapshot(BalanceSnapshot{
+		Account:     account,
+		RecordedAt:  now,
+		PeriodStart: statement.Document.PeriodStart,
+		PeriodEnd:   statement.Document.PeriodEnd,
+		Balance:     statement.Balance.Balance,
+		DocumentID:  statement.Document.ID,
+	})
+	return err
+}
+
+// balanceAlertItems reports the latest snapshot being below the low threshold, and the balance
+// dropping by more than the drop threshold within a day. Snapshots are oldest first. A low balance
+// is keyed by the snapshot that started the streak below the threshold, so it is reported once
+// until the balance recovers; a drop is reported at most once per day. It fails when a balance
+// is in another currency than the thresholds.
+func balanceAlertItems(account string, snapshots []BalanceSnapshot, config BalanceAlertConfig) ([]AlertItem, error) {
+	if len(snapshots) == 0 {
+		return nil, nil
+	}
+	latest := snapshots[len(snapshots)-1]
+	prefix := "balance|" + account
+
+	var items []AlertItem
+	if config.LowThreshold != nil {
+		start := len(snapshots)
+		for start > 0 {
+			below, err := snapshots[start-1].Balance.Compare(*config.LowThreshold)
+			if err != nil {
+				return nil, err
+			}
+			if below >= 0 {
+				break
+			}
+			start--
+		}
+		if start < len(snapshots) {
+			items = append(items, AlertItem{
+				Key: fmt.Sprintf("%s|low|%d", prefix, snapshots[start].ID),
+				Line: fmt.Sprintf("**Balance** `%s` is below `%s` since %s",
+					latest.Balance, *config.LowThreshold, snapshots[start].RecordedAt.Format(statementDateLayout)),
+			})
+		}
+	}
+
+	if config.DropThreshold != nil {
+		var peak *Money
+		for _, s := range snapshots[:len(snapshots)-1] {
+			if latest.RecordedAt.Sub(s.RecordedAt) > balanceDropWindow {
+				continue
+			}
+			if peak == nil {
+				peak = &s.Balance
+			} else if higher, err := s.Balance.Compare(*peak); err != nil {
+				return nil, err
+			} else if higher > 0 {
+				peak = &s.Balance
+			}
+		}
+		if peak != nil {
+			drop, err := peak.Add(latest.Balance.Neg())
+			if err != nil {
+				return nil, err
+			}
+			if exceeds, err := drop.Compare(*config.DropThreshold); err != nil {
+				return nil, err
+			} else if exceeds > 0 {
+				items = append(items, AlertItem{
+					Key:  prefix + "|drop|" + latest.RecordedAt.Format("2006-01-02"),
+					Line: fmt.Sprintf("**Balance** dropped `%s` within a day, from `%s` to `%s`", drop, *peak, latest.Balance),
+				})
+			}
+		}
+	}
+	return items, nil
+}
+
+// checkBalance alerts every channel about a low balance or a large drop of the account balance
+func checkBalance(config AccountConfig, storage *Storage, notifiers []Notifier, account string, now time.Time) error {
+	alerts := loadBalanceAlertConfig(config)
+	if alerts.LowThreshold == nil && alerts.DropThreshold == nil {
+		return nil
+	}
+
+	snapshots, err := storage.Balances.ListSnapshots(account, now.AddDate(0, 0, -balanceAlertHistoryDays), now)
+	if err != nil {
+		return err
+	}
+	items, err := balanceAlertItems(account, snapshots, alerts)
+	if err != nil {
+		return err
+	}
+	return sendAlerts(storage.Alerts, notifiers, AlertBalance, "🏦 N26 Balance Alerts", items)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBalanceFromTextThousands(t *testing.T) {
+	tests := map[string]int64{
+		"Tu nuevo saldo\n1.234,56€":       123456,
+		"Your new balance\n+12,345.67 €":  1234567,
+		"Your new balance\n-250,00€":      -25000,
+		"Tu nuevo saldo\nSaldo 1234,56 €": 123456,
+		"Tu nuevo saldo\n1.250,00-€":      -125000,
+	}
+	for text, want := range tests {
+		balance, err := parseBalanceFromText(text)
+		if err != nil {
+			t.Fatalf("parseBalanceFromText(%q) failed: %v", text, err)
+		}
+		if balance.Balance.Minor != want || balance.Balance.Currency != "EUR" {
+			t.Errorf("parseBalanceFromText(%q) = %+v, want %d EUR cents", text, balance.Balance, want)
+		}
+	}
+}
+
+func TestBalanceAlertItems(t *testing.T) {
+	at := func(day, hour int) time.Time { return time.Date(2025, 10, day, hour, 0, 0, 0, time.UTC) }
+	low, drop := eur("200,00"), eur("500,00")
+	config := BalanceAlertConfig{LowThreshold: &low, DropThreshold: &drop}
+	history := []BalanceSnapshot{
+		{ID: 1, RecordedAt: at(1, 8), Balance: eur("1.500,00")},
+		{ID: 2, RecordedAt: at(2, 8), Balance: eur("150,00")},
+		{ID: 3, RecordedAt: at(3, 8), Balance: eur("300,00")},
+		{ID: 4, RecordedAt: at(4, 8), Balance: eur("900,00")},
+		{ID: 5, RecordedAt: at(4, 20), Balance: eur("180,00")},
+		{ID: 6, RecordedAt: at(5, 7), Balance: eur("120,00")},
+	}
+
+	items, err := balanceAlertItems("acc-1", history, config)
+	if err != nil || len(items) != 2 {
+		t.Fatalf("balanceAlertItems() = %+v, %v, want 2 items", items, err)
+	}
+	// The streak below 200 started with snapshot 5, the earlier dip has recovered
+	if items[0].Key != "balance|acc-1|low|5" || !strings.Contains(items[0].Line, "`120,00 EUR` is below `200,00 EUR`") {
+		t.Errorf("low item = %+v, want the streak since snapshot 5", items[0])
+	}
+	// Within 24 hours the balance fell from 900 to 120
+	if items[1].Key != "balance|acc-1|drop|2025-10-05" || !strings.Contains(items[1].Line, "dropped `780,00 EUR` within a day, from `900,00 EUR` to `120,00 EUR`") {
+		t.Errorf("drop item = %+v, want a 780 EUR drop on 2025-10-05", items[1])
+	}
+
+	// A later snapshot still below the threshold keeps the same key
+	later := append(history, BalanceSnapshot{ID: 7, RecordedAt: at(9, 8), Balance: eur("110,00")})
+	if items, err := balanceAlertItems("acc-1", later, config); err != nil || len(items) != 1 || items[0].Key != "balance|acc-1|low|5" {
+		t.Errorf("balanceAlertItems() = %+v, %v, want only the low balance of the same streak", items, err)
+	}
+
+	// Drops older than a day and a balance above the threshold are not reported
+	if items, err := balanceAlertItems("acc-1", history[:4], config); err != nil || len(items) != 0 {
+		t.Errorf("balanceAlertItems() = %+v, %v, want none", items, err)
+	}
+	// Unset thresholds disable the alerts
+	if items, err := balanceAlertItems("acc-1", history, BalanceAlertConfig{}); err != nil || len(items) != 0 {
+		t.Errorf("balanceAlertItems() = %+v, %v, want none without thresholds", items, err)
+	}
+	// A balance in another currency cannot be compared with the thresholds
+	foreign := append(history, BalanceSnapshot{ID: 8, RecordedAt: at(9, 8), Balance: eur("$110.00")})
+	if items, err := balanceAlertItems("acc-1", foreign, config); err == nil {
+		t.Errorf("balanceAlertItems() with a USD balance = %+v, want an error", items)
+	}
+}
+
+func TestLoadBalanceAlertConfig(t *testing.T) {
+	t.Setenv("BALANCE_LOW_THRESHOLD", "1.000,50")
+	t.Setenv("BALANCE_DROP_THRESHOLD", "$500")
+	config := loadBalanceAlertConfig(globalConfig)
+	if config.LowThreshold == nil || *config.LowThreshold != eur("1000,50") {
+		t.Errorf("LowThreshold = %v, want 1.000,50 EUR", config.LowThreshold)
+	}
+	if config.DropThreshold != nil {
+		t.Errorf("DropThreshold = %v, want a threshold in another currency to be ignored", config.DropThreshold)
+	}
+}
+
+func TestCheckBalanceAlertsOnce(t *testing.T) {
+	t.Setenv("BALANCE_LOW_THRESHOLD", "100")
+	storage, err := OpenStorage("memory://")
+	if err != nil {
+		t.Fatalf("OpenStorage() failed: %v", err)
+	}
+	now := time.Date(2025, 10, 20, 9, 0, 0, 0, time.UTC)
+	statement := &ParsedStatement{Balance: &AccountBalance{Balance: eur("-45,10")}}
+	if err := recordBalanceSnapshot(storage.Balances, "acc-1", statement, now); err != nil {
+		t.Fatalf("recordBalanceSnapshot() failed: %v", err)
+	}
+
+	notifier := &fakeNotifier{channel: "discord"}
+	for run := 0; run < 2; run++ {
+		if err := checkBalance(globalConfig, storage, []Notifier{notifier}, "acc-1", now.Add(time.Hour)); err != nil {
+			t.Fatalf("checkBalance() failed: %v", err)
+		}
+	}
+	if len(notifier.alerts) != 1 || notifier.alerts[0].Kind != AlertBalance || !strings.Contains(notifier.alerts[0].Lines[0], "`-45,10 EUR`") {
+		t.Errorf("alerts = %+v, want one balance alert about -45,10 EUR", notifier.alerts)
+	}
+
+	// Another account has no snapshots
+	if err := checkBalance(globalConfig, storage, []Notifier{notifier}, "acc-2", now); err != nil || len(notifier.alerts) != 1 {
+		t.Errorf("checkBalance() for another account = %v with %d alerts, want no new alert", err, len(notifier.alerts))
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// BudgetRepository stores the monthly spending limit of each category
+type BudgetRepository interface {
+	ListBudgets() ([]Budget, error)
+	SetBudget(budget Budget) error
+	DeleteBudget(category string) error
+}
+
+// Budget is the monthly spending limit of one category
+type Budget struct {
+	Category     string
+	MonthlyLimit Money // Positive, in the statement currency
+}
+
+// Validate checks that a budget has a category and a positive limit in the statement currency
+func (b Budget) Validate() error {
+	if strings.TrimSpace(b.Category) == "" {
+		return fmt.Errorf("budget needs a category")
+	}
+	if b.MonthlyLimit.Minor <= 0 {
+		return fmt.Errorf("monthly limit of %s must be positive, got %s", b.Category, b.MonthlyLimit)
+	}
+	if b.MonthlyLimit.currency() != statementCurrency {
+		return fmt.Errorf("monthly limit of %s must be in %s, got %s", b.Category, statementCurrency, b.MonthlyLimit)
+	}
+	return nil
+}
+
+// SQLBudgetRepository implements BudgetRepository on both PostgreSQL and SQLite,
+// the queries only use syntax the two have in common
+type SQLBudgetRepository struct {
+	db      *sql.DB
+	account string
+}
+
+// NewSQLBudgetRepository creates a budget repository for an account on a migrated database
+func NewSQLBudgetRepository(db *sql.DB, account string) *SQLBudgetRepository {
+	return &SQLBudgetRepository{db: db, account: account}
+}
+
+// ListBudgets returns every budget ordered by category
+func (r *SQLBudgetRepository) ListBudgets() ([]Budget, error) {
+	rows, err := r.db.Query(`SELECT category, CAST(monthly_limit AS TEXT) FROM budgets WHERE account = $1 ORDER BY category ASC`, r.account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list budgets: %w", err)
+	}
+	defer rows.Close()
+
+	var budgets []Budget
+	for rows.Next() {
+		var budget Budget
+		var limit string
+		if err := rows.Scan(&budget.Category, &limit); err != nil {
+			return nil, fmt.Errorf("failed to scan budget: %w", err)
+		}
+		if budget.MonthlyLimit, err = parseMoney(limit, statementCurrency); err != nil {
+			return nil, fmt.Errorf("failed to read monthly limit of %s: %w", budget.Category, err)
+		}
+		budgets = append(budgets, budget)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list budgets: %w", err)
+	}
+	return budgets, nil
+}
+
+// SetBudget creates the budget of a category or replaces its limit
+func (r *SQLBudgetRepository) SetBudget(budget Budget) error {
+	if err := budget.Validate(); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO budgets (account, category, monthly_limit, created_at, updated_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT (account, category)
+		DO UPDATE SET monthly_limit = EXCLUDED.monthly_limit, updated_at = CURRENT_TIMESTAMP
+	`
+	if _, err := r.db.Exec(query, r.account, strings.TrimSpace(budget.Category), budget.MonthlyLimit.decimal()); err != nil {
+		return fmt.Errorf("failed to set budget: %w", err)
+	}
+	return nil
+}
+
+// DeleteBudget deletes the budget of a category, failing when there is none
+func (r *SQLBudgetRepository) DeleteBudget(category string) error {
+	result, err := r.db.Exec(`DELETE FROM budgets WHERE account = $1 AND category = $2`, r.account, category)
+	if err != nil {
+		return fmt.Errorf("failed to delete budget: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete budget: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("no budget for category %q", category)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultBudgetThresholds are the percentages of a budget that trigger an alert
+var defaultBudgetThresholds = []int{80, 100}
+
+// BudgetStatus is the spending of a budgeted category in one month
+type BudgetStatus struct {
+	Budget
+	Month time.Time // First day of the month
+	Spent Money     // Net spending to date: debits minus refunds
+}
+
+// Percent returns the share of the monthly limit spent so far
+func (s BudgetStatus) Percent() float64 {
+	return float64(s.Spent.Minor) / float64(s.MonthlyLimit.Minor) * 100
+}
+
+// loadBudgetThresholds reads BUDGET_THRESHOLDS, comma-separated percentages like "80,100"
+func loadBudgetThresholds(config AccountConfig) []int {
+	value := config.Getenv("BUDGET_THRESHOLDS")
+	if value == "" {
+		return defaultBudgetThresholds
+	}
+
+	var thresholds []int
+	for _, part := range strings.Split(value, ",") {
+		threshold, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(part), "%")))
+		if err != nil || threshold <= 0 {
+			log.Printf("Warning: Invalid value for BUDGET_THRESHOLDS (%q), using default %v", value, defaultBudgetThresholds)
+			return defaultBudgetThresholds
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	slices.Sort(thresholds)
+	return slices.Compact(thresholds)
+}
+
+// monthStart returns the first day of the month t falls in, in the account's timezone
+func monthStart(t time.Time) time.Time {
+	loc := statementLocation()
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+}
+
+// periodMonths returns the first day of every month the period touches, oldest first
+func periodMonths(from, to time.Time) []time.Time {
+	var months []time.Time
+	for month := monthStart(from); !month.After(monthStart(to)); month = month.AddDate(0, 1, 0) {
+		months = append(months, month)
+	}
+	return months
+}
+
+// budgetStatuses sums the spending of every budgeted category over the given transactions.
+// Categories are compared case-insensitively and removed transactions are left out. It fails
+// when a budgeted category has spending in another currency than its limit.
+func budgetStatuses(budgets []Budget, transactions []StoredTransaction, month time.Time) ([]BudgetStatus, error) {
+	statuses := make([]BudgetStatus, len(budgets))
+	byCategory := make(map[string][]*BudgetStatus)
+	for i, budget := range budgets {
+		statuses[i] = BudgetStatus{Budget: budget, Month: month, Spent: newMoney(0, budget.MonthlyLimit.Currency)}
+		category := strings.ToLower(budget.Category)
+		byCategory[category] = append(byCategory[category], &statuses[i])
+	}
+
+	for _, t := range transactions {
+		if t.RemovedAt != nil {
+			continue
+		}
+		for _, status := range byCategory[strings.ToLower(t.Category)] {
+			spent, err := status.Spent.Add(t.Amount.Neg())
+			if err != nil {
+				return nil, fmt.Errorf("failed to sum the spending of %s: %w", status.Category, err)
+			}
+			status.Spent = spent
+		}
+	}
+	return statuses, nil
+}
+
+// budgetAlertItems returns an alert for every budget that crossed a threshold, naming the
+// highest one. Lower thresholds count as sent along with it, so a budget overrun at once
+// only alerts once, and a refund dropping below a threshold does not alert again.
+func budgetAlertItems(statuses []BudgetStatus, thresholds []int) []AlertItem {
+	var items []AlertItem
+	for _, status := range statuses {
+		var crossed []string
+		highest := 0
+		for _, threshold := range thresholds {
+			if status.Percent() >= float64(threshold) {
+				crossed = append(crossed, budgetAlertKey(status, threshold))
+				highest = threshold
+			}
+		}
+		if len(crossed) == 0 {
+			continue
+		}
+
+		items = append(items, AlertItem{
+			Key: crossed[len(crossed)-1],
+			Line: fmt.Sprintf("**%s** %s: `%s` of `%s` spent (%.0f%%), crossed %d%%",
+				status.Category, status.Month.Format("January 2006"), status.Spent, status.MonthlyLimit, status.Percent(), highest),
+			Covers: crossed[:len(crossed)-1],
+		})
+	}
+	return items
+}
+
+// budgetAlertKey identifies the alert of a budget threshold in one month, e.g. "budget|groceries|2025-10|80"
+func budgetAlertKey(status BudgetStatus, threshold int) string {
+	return fmt.Sprintf("budget|%s|%s|%d", strings.ToLower(status.Category), status.Month.Format("2006-01"), threshold)
+}
+
+// loadBudgetStatuses computes the spending of every budget in the month
+func loadBudgetStatuses(storage *Storage, month time.Time) ([]BudgetStatus, error) {
+	budgets, err := storage.Budgets.ListBudgets()
+	if err != nil {
+		return nil, err
+	}
+	if len(budgets) == 0 {
+		return nil, nil
+	}
+
+	transactions, err := listSpending(storage, month, month.AddDate(0, 1, -1))
+	if err != nil {
+		return nil, err
+	}
+	return budgetStatuses(budgets, transactions, month)
+}
+
+// checkBudgets alerts every channel about budgets that crossed a threshold in the given months
+func checkBudgets(config AccountConfig, storage *Storage, notifiers []Notifier, months []time.Time) error {
+	thresholds := loadBudgetThresholds(config)
+
+	var items []AlertItem
+	for _, month := range months {
+		statuses, err := loadBudgetStatuses(storage, month)
+		if err != nil {
+			return err
+		}
+		items = append(items, budgetAlertItems(statuses, thresholds)...)
+	}
+	return sendAlerts(storage.Alerts, notifiers, AlertBudget, "💰 N26 Budget Alerts", items)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBudgetAlertItems(t *testing.T) {
+	october := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	transactions := []StoredTransaction{
+		{Transaction: Transaction{PartnerName: "Lidl", Amount: eur("-320,00"), Category: "Groceries"}},
+		{Transaction: Transaction{PartnerName: "Rewe", Amount: eur("-100,00"), Category: "groceries"}},
+		{Transaction: Transaction{PartnerName: "Rewe", Amount: eur("20,00"), Category: "Groceries"}},
+		{Transaction: Transaction{PartnerName: "Pizza", Amount: eur("-130,00"), Category: "Restaurants"}},
+		{Transaction: Transaction{PartnerName: "Pizza", Amount: eur("-90,00"), Category: "Restaurants"}, RemovedAt: &october},
+		{Transaction: Transaction{PartnerName: "Bolt", Amount: eur("-10,00"), Category: "Transport"}},
+	}
+	budgets := []Budget{{"Groceries", eur("400,00")}, {"Restaurants", eur("150,00")}, {"Transport", eur("100,00")}}
+
+	statuses, err := budgetStatuses(budgets, transactions, october)
+	if err != nil {
+		t.Fatalf("budgetStatuses() failed: %v", err)
+	}
+	if statuses[0].Spent != eur("400,00") || statuses[1].Spent != eur("130,00") || statuses[2].Spent != eur("10,00") {
+		t.Fatalf("spent = %s, %s, %s, want 400, 130, 10", statuses[0].Spent, statuses[1].Spent, statuses[2].Spent)
+	}
+
+	items := budgetAlertItems(statuses, []int{80, 100})
+	if len(items) != 2 {
+		t.Fatalf("budgetAlertItems() returned %d items, want 2: %+v", len(items), items)
+	}
+	if items[0].Key != "budget|groceries|2025-10|100" || len(items[0].Covers) != 1 || items[0].Covers[0] != "budget|groceries|2025-10|80" {
+		t.Errorf("groceries item = %+v, want the 100%% alert covering 80%%", items[0])
+	}
+	if items[1].Key != "budget|restaurants|2025-10|80" || len(items[1].Covers) != 0 {
+		t.Errorf("restaurants item = %+v, want the 80%% alert", items[1])
+	}
+	if !strings.Contains(items[1].Line, "October 2025: `130,00 EUR` of `150,00 EUR` spent (87%)") {
+		t.Errorf("restaurants line = %q, want the month and the share spent", items[1].Line)
+	}
+
+	transactions = append(transactions, StoredTransaction{Transaction: Transaction{PartnerName: "Bolt", Amount: eur("$-5.00"), Category: "Transport"}})
+	if statuses, err := budgetStatuses(budgets, transactions, october); err == nil {
+		t.Errorf("budgetStatuses() with spending in USD = %+v, want an error", statuses)
+	}
+}
+
+func TestLoadBudgetThresholds(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []int
+	}{
+		{"", defaultBudgetThresholds},
+		{"100, 50%,75,50", []int{50, 75, 100}},
+		{"80,abc", defaultBudgetThresholds},
+	}
+	for _, tt := range tests {
+		t.Setenv("BUDGET_THRESHOLDS", tt.value)
+		got := loadBudgetThresholds(globalConfig)
+		if len(got) != len(tt.want) {
+			t.Errorf("loadBudgetThresholds(%q) = %v, want %v", tt.value, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("loadBudgetThresholds(%q) = %v, want %v", tt.value, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestCheckBudgetsAlertsEachThresholdOnce(t *testing.T) {
+	storage, err := OpenStorage("memory://")
+	if err != nil {
+		t.Fatalf("failed to open memory storage: %v", err)
+	}
+	october := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	if err := storage.Budgets.SetBudget(Budget{"Groceries", eur("100,00")}); err != nil {
+		t.Fatalf("SetBudget() failed: %v", err)
+	}
+	record := func(transactions ...Transaction) {
+		t.Helper()
+		if err := storage.Transactions.RecordTransactions(0, transactions); err != nil {
+			t.Fatalf("RecordTransactions() failed: %v", err)
+		}
+	}
+
+	discord := &fakeNotifier{channel: "discord"}
+	email := &fakeNotifier{channel: "email", err: errors.New("smtp unavailable")}
+	notifiers := []Notifier{discord, email}
+
+	record(Transaction{BookingDate: statementDay("02.10.2025"), PartnerName: "Lidl", Amount: eur("-85,00"), Category: "Groceries"})
+	if err := checkBudgets(globalConfig, storage, notifiers, []time.Time{october}); err == nil {
+		t.Fatal("checkBudgets() with a failing channel succeeded, want error")
+	}
+	if len(discord.alerts) != 1 || !strings.Contains(discord.alerts[0].Lines[0], "crossed 80%") {
+		t.Fatalf("discord alerts = %+v, want the 80%% alert", discord.alerts)
+	}
+
+	// Nothing new for discord, the failed channel is retried
+	email.err = nil
+	if err := checkBudgets(globalConfig, storage, notifiers, []time.Time{october}); err != nil {
+		t.Fatalf("checkBudgets() failed: %v", err)
+	}
+	if len(discord.alerts) != 1 || len(email.alerts) != 1 {
+		t.Fatalf("alerts = %d discord, %d email, want 1 each", len(discord.alerts), len(email.alerts))
+	}
+
+	record(Transaction{BookingDate: statementDay("03.10.2025"), PartnerName: "Rewe", Amount: eur("-20,00"), Category: "Groceries"})
+	if err := checkBudgets(globalConfig, storage, notifiers, []time.Time{october}); err != nil {
+		t.Fatalf("checkBudgets() failed: %v", err)
+	}
+	if len(discord.alerts) != 2 || !strings.Contains(discord.alerts[1].Lines[0], "crossed 100%") {
+		t.Fatalf("discord alerts = %+v, want the 100%% alert", discord.alerts)
+	}
+	if err := checkBudgets(globalConfig, storage, notifiers, []time.Time{october}); err != nil {
+		t.Fatalf("checkBudgets() failed: %v", err)
+	}
+	if len(discord.alerts) != 2 || len(email.alerts) != 2 {
+		t.Errorf("alerts = %d discord, %d email after a repeated run, want 2 each", len(discord.alerts), len(email.alerts))
+	}
+}
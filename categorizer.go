@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// uncategorized is the category of transactions no rule matches
+const uncategorized = "Uncategorized"
+
+// Rule signs, matched against the sign of the amount
+const (
+	signDebit  = "debit"  // Money going out (negative amounts)
+	signCredit = "credit" // Money coming in (positive amounts)
+)
+
+// CategoryRule assigns a category to the transactions matching all of its conditions.
+// Empty conditions match everything, but a rule needs at least one condition.
+type CategoryRule struct {
+	ID             int64 // 0 for the built-in default rules
+	Category       string
+	Priority       int      // Lower priorities are evaluated first
+	PartnerPattern string   // Regular expression matched case-insensitively against the partner name
+	MinAmount      *Money   // Lower bound of the absolute amount, inclusive, in the statement currency
+	MaxAmount      *Money   // Upper bound of the absolute amount, inclusive, in the statement currency
+	Sign           string   // signDebit, signCredit or empty for both
+	Keywords       []string // Any of them in the partner name or statement text, case-insensitive
+}
+
+// defaultCategoryRules are evaluated in this order after the user-defined rules,
+// unless CATEGORY_DEFAULT_RULES=false
+var defaultCategoryRules = []CategoryRule{
+	{Category: "Income", Sign: signCredit, Keywords: []string{"salary", "payroll", "nómina", "nomina", "gehalt", "lohn"}},
+	{Category: "Cash", Keywords: []string{"atm", "cash withdrawal", "cajero", "retirada de efectivo", "bargeld"}},
+	{Category: "Groceries", PartnerPattern: `rewe|lidl|aldi|edeka|penny|netto|kaufland|mercadona|carrefour|\bdia\b|eroski|consum|supermar`},
+	{Category: "Restaurants", PartnerPattern: `restaur|caf[eé]|coffee|starbucks|mcdonald|burger|pizza|sushi|\bbar\b|glovo|uber ?eats|deliveroo|lieferando|just ?eat|wolt`},
+	{Category: "Transport", PartnerPattern: `\buber\b|\bbolt\b|cabify|taxi|renfe|\bbvg\b|deutsche bahn|db vertrieb|\bmetro\b|ryanair|vueling|iberia|lufthansa|shell|repsol|\baral\b|\besso\b|cepsa|gas station|tankstelle`},
+	{Category: "Shopping", PartnerPattern: `amazon|zalando|ikea|decathlon|media ?markt|saturn|el corte ingl|\bzara\b|primark|h&m|aliexpress`},
+	{Category: "Subscriptions", PartnerPattern: `spotify|netflix|disney|apple\.com|itunes|google|youtube|\bhbo\b|prime video|audible|patreon|chatgpt|openai`},
+	{Category: "Utilities", PartnerPattern: `vodafone|telekom|movistar|orange|\bo2\b|iberdrola|endesa|naturgy|vattenfall|e\.on|stadtwerke`},
+	{Category: "Transfers", Keywords: []string{"transfer", "transferencia", "überweisung", "bizum"}},
+}
+
+// Validate checks that a rule has a category, at least one condition and valid values
+func (r CategoryRule) Validate() error {
+	if strings.TrimSpace(r.Category) == "" {
+		return fmt.Errorf("rule needs a category")
+	}
+	if r.PartnerPattern == "" && r.MinAmount == nil && r.MaxAmount == nil && r.Sign == "" && len(r.Keywords) == 0 {
+		return fmt.Errorf("rule for %q needs at least one condition", r.Category)
+	}
+	if r.PartnerPattern != "" {
+		if _, err := regexp.Compile(r.PartnerPattern); err != nil {
+			return fmt.Errorf("invalid partner pattern %q: %w", r.PartnerPattern, err)
+		}
+	}
+	for _, bound := range []*Money{r.MinAmount, r.MaxAmount} {
+		if bound != nil && bound.currency() != statementCurrency {
+			return fmt.Errorf("amount %s must be in %s", bound, statementCurrency)
+		}
+	}
+	if r.MinAmount != nil && r.MaxAmount != nil && r.MinAmount.Minor > r.MaxAmount.Minor {
+		return fmt.Errorf("minimum amount %s is above maximum amount %s", r.MinAmount, r.MaxAmount)
+	}
+	if r.Sign != "" && r.Sign != signDebit && r.Sign != signCredit {
+		return fmt.Errorf("invalid sign %q (expected %s or %s)", r.Sign, signDebit, signCredit)
+	}
+	return nil
+}
+
+// Categorizer assigns categories to transactions using the first matching rule
+type Categorizer struct {
+	rules []compiledRule
+}
+
+// compiledRule is a rule with its partner pattern compiled
+type compiledRule struct {
+	CategoryRule
+	partner *regexp.Regexp // nil when the rule has no partner pattern
+}
+
+// NewCategorizer compiles the rules in evaluation order: by priority, then by ID.
+// The default rules follow the given ones when withDefaults is set.
+func NewCategorizer(rules []CategoryRule, withDefaults bool) (*Categorizer, error) {
+	ordered := slices.Clone(rules)
+	slices.SortStableFunc(ordered, func(a, b CategoryRule) int {
+		if a.Priority != b.Priority {
+			return a.Priority - b.Priority
+		}
+		return int(a.ID - b.ID)
+	})
+	if withDefaults {
+		ordered = append(ordered, defaultCategoryRules...)
+	}
+
+	c := &Categorizer{}
+	for _, rule := range ordered {
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid category rule %d: %w", rule.ID, err)
+		}
+		compiled := compiledRule{CategoryRule: rule}
+		if rule.PartnerPattern != "" {
+			compiled.partner = regexp.MustCompile("(?i)" + rule.PartnerPattern)
+		}
+		c.rules = append(c.rules, compiled)
+	}
+	return c, nil
+}
+
+// loadCategorizer builds the categorizer from the stored rules and, unless
+// CATEGORY_DEFAULT_RULES=false, the default rules
+func loadCategorizer(config AccountConfig, ruleRepo CategoryRuleRepository) (*Categorizer, error) {
+	rules, err := ruleRepo.ListRules()
+	if err != nil {
+		return nil, err
+	}
+	return NewCategorizer(rules, config.envBool("CATEGORY_DEFAULT_RULES", true))
+}
+
+// Rules returns the rules in evaluation order
+func (c *Categorizer) Rules() []CategoryRule {
+	rules := make([]CategoryRule, len(c.rules))
+	for i, rule := range c.rules {
+		rules[i] = rule.CategoryRule
+	}
+	return rules
+}
+
+// Categorize returns the category of the first rule matching the transaction
+func (c *Categorizer) Categorize(t Transaction) string {
+	for _, rule := range c.rules {
+		if rule.matches(t) {
+			return rule.Category
+		}
+	}
+	return uncategorized
+}
+
+// CategorizeAll sets the category of every transaction
+func (c *Categorizer) CategorizeAll(transactions []Transaction) {
+	for i := range transactions {
+		transactions[i].Category = c.Categorize(transactions[i])
+	}
+}
+
+// matches reports whether the transaction meets every condition of the rule
+func (r compiledRule) matches(t Transaction) bool {
+	if r.partner != nil && !r.partner.MatchString(t.PartnerName) {
+		return false
+	}
+
+	if r.MinAmount != nil || r.MaxAmount != nil || r.Sign != "" {
+		switch r.Sign {
+		case signDebit:
+			if t.Amount.Minor >= 0 {
+				return false
+			}
+		case signCredit:
+			if t.Amount.Minor <= 0 {
+				return false
+			}
+		}
+		// An amount in another currency is outside the bounds
+		amount := t.Amount.Abs()
+		if r.MinAmount != nil {
+			if below, err := amount.Compare(*r.MinAmount); err != nil || below < 0 {
+				return false
+			}
+		}
+		if r.MaxAmount != nil {
+			if above, err := amount.Compare(*r.MaxAmount); err != nil || above > 0 {
+				return false
+			}
+		}
+	}
+
+	if len(r.Keywords) > 0 {
+		text := strings.ToLower(t.PartnerName + "\n" + t.RawText)
+		found := false
+		for _, keyword := range r.Keywords {
+			if keyword != "" && strings.Contains(text, strings.ToLower(keyword)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// recategorizeTransactions applies the categorizer to the stored transactions booked between
+// from and to, returning how many changed category out of how many were checked
+func recategorizeTransactions(transactionRepo TransactionRepository, categorizer *Categorizer, from, to time.Time) (int, int, error) {
+	stored, err := transactionRepo.ListTransactions(from, to)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	changed := make(map[string]string)
+	for _, t := range stored {
+		if category := categorizer.Categorize(t.Transaction); category != t.Category {
+			changed[t.Key] = category
+		}
+	}
+	if err := transactionRepo.UpdateCategories(changed); err != nil {
+		return 0, 0, err
+	}
+	return len(changed), len(stored), nil
+}
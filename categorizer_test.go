@@ -0,0 +1,93 @@
+package main
+
+import "testing"
+
+func TestCategorizer(t *testing.T) {
+	maxAmount := eur("20,00")
+	rules := []CategoryRule{
+		{ID: 1, Category: "Lunch", Priority: 100, PartnerPattern: `restaurant`, Sign: signDebit, MaxAmount: &maxAmount},
+		{ID: 2, Category: "Gifts", Priority: 100, Keywords: []string{"birthday"}},
+		{ID: 3, Category: "Side Job", Priority: 50, PartnerPattern: `^acme`, Sign: signCredit},
+	}
+	categorizer, err := NewCategorizer(rules, true)
+	if err != nil {
+		t.Fatalf("NewCategorizer() failed: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		transaction Transaction
+		want        string
+	}{
+		{"user rule within amount range", Transaction{PartnerName: "Restaurant Sol", Amount: eur("-12,50")}, "Lunch"},
+		{"user rule amount too high falls back to defaults", Transaction{PartnerName: "Restaurant Sol", Amount: eur("-45,00")}, "Restaurants"},
+		{"keyword in statement text", Transaction{PartnerName: "Anna", Amount: eur("-30,00"), RawText: "Anna\nHappy Birthday"}, "Gifts"},
+		{"lower priority wins", Transaction{PartnerName: "ACME Corp", Amount: eur("500,00"), RawText: "salary"}, "Side Job"},
+		{"sign must match", Transaction{PartnerName: "ACME Corp", Amount: eur("-5,00")}, uncategorized},
+		{"default partner pattern is case-insensitive", Transaction{PartnerName: "LIDL SAGT DANKE", Amount: eur("-23,10")}, "Groceries"},
+		{"default income keyword", Transaction{PartnerName: "Employer GmbH", Amount: eur("2.500,00"), RawText: "Gehalt Oktober"}, "Income"},
+		{"nothing matches", Transaction{PartnerName: "Jane Doe", Amount: eur("-7,00")}, uncategorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := categorizer.Categorize(tt.transaction); got != tt.want {
+				t.Errorf("Categorize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCategorizerWithoutDefaults(t *testing.T) {
+	categorizer, err := NewCategorizer(nil, false)
+	if err != nil {
+		t.Fatalf("NewCategorizer() failed: %v", err)
+	}
+	if got := categorizer.Categorize(Transaction{PartnerName: "Lidl", Amount: eur("-10,00")}); got != uncategorized {
+		t.Errorf("Categorize() = %q, want %q", got, uncategorized)
+	}
+}
+
+func TestNewCategorizerRejectsInvalidRules(t *testing.T) {
+	if _, err := NewCategorizer([]CategoryRule{{ID: 7, Category: "Broken", PartnerPattern: `[`}}, true); err == nil {
+		t.Error("NewCategorizer() with an invalid pattern succeeded, want error")
+	}
+}
+
+func TestRecategorizeTransactions(t *testing.T) {
+	storage, err := OpenStorage("memory://")
+	if err != nil {
+		t.Fatalf("OpenStorage() failed: %v", err)
+	}
+	transactions := []Transaction{
+		{BookingDate: statementDay("02.10.2025"), PartnerName: "Bookstore", Amount: eur("-15,00"), Category: uncategorized},
+		{BookingDate: statementDay("03.10.2025"), PartnerName: "Lidl", Amount: eur("-20,00"), Category: "Groceries"},
+	}
+	if err := storage.Transactions.RecordTransactions(0, transactions); err != nil {
+		t.Fatalf("RecordTransactions() failed: %v", err)
+	}
+	if _, err := storage.Rules.AddRule(CategoryRule{Category: "Books", PartnerPattern: `book`}); err != nil {
+		t.Fatalf("AddRule() failed: %v", err)
+	}
+
+	categorizer, err := loadCategorizer(globalConfig, storage.Rules)
+	if err != nil {
+		t.Fatalf("loadCategorizer() failed: %v", err)
+	}
+	from, _ := parseStatementDate("01.10.2025")
+	to, _ := parseStatementDate("31.10.2025")
+	changed, total, err := recategorizeTransactions(storage.Transactions, categorizer, from, to)
+	if err != nil {
+		t.Fatalf("recategorizeTransactions() failed: %v", err)
+	}
+	if changed != 1 || total != 2 {
+		t.Errorf("recategorizeTransactions() = %d of %d, want 1 of 2", changed, total)
+	}
+
+	stored, err := storage.Transactions.ListTransactions(from, to)
+	if err != nil || len(stored) != 2 {
+		t.Fatalf("ListTransactions() = %d transactions, %v, want 2", len(stored), err)
+	}
+	if stored[0].Category != "Books" || stored[1].Category != "Groceries" {
+		t.Errorf("categories = %q, %q, want Books, Groceries", stored[0].Category, stored[1].Category)
+	}
+}
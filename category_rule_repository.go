@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// CategoryRuleRepository stores the user-defined categorization rules
+type CategoryRuleRepository interface {
+	ListRules() ([]CategoryRule, error)
+	AddRule(rule CategoryRule) (int64, error)
+	DeleteRule(id int64) error
+}
+
+// SQLCategoryRuleRepository implements CategoryRuleRepository on both PostgreSQL and SQLite,
+// the queries only use syntax the two have in common
+type SQLCategoryRuleRepository struct {
+	db      *sql.DB
+	account string
+}
+
+// NewSQLCategoryRuleRepository creates a category rule repository for an account on a migrated database
+func NewSQLCategoryRuleRepository(db *sql.DB, account string) *SQLCategoryRuleRepository {
+	return &SQLCategoryRuleRepository{db: db, account: account}
+}
+
+// ListRules returns the stored rules in evaluation order: by priority, then by ID
+func (r *SQLCategoryRuleRepository) ListRules() ([]CategoryRule, error) {
+	query := `
+		SELECT id, category, priority, partner_pattern, CAST(min_amount AS TEXT), CAST(max_amount AS TEXT), sign, keywords
+		FROM category_rules
+		WHERE account = $1
+		ORDER BY priority ASC, id ASC
+	`
+	rows, err := r.db.Query(query, r.account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list category rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []CategoryRule
+	for rows.Next() {
+		var rule CategoryRule
+		var partner, sign, keywords, minAmount, maxAmount sql.NullString
+		err := rows.Scan(&rule.ID, &rule.Category, &rule.Priority, &partner, &minAmount, &maxAmount, &sign, &keywords)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan category rule: %w", err)
+		}
+		rule.PartnerPattern = partner.String
+		if rule.MinAmount, err = nullMoneyPtr(minAmount); err != nil {
+			return nil, fmt.Errorf("failed to read minimum amount of category rule %d: %w", rule.ID, err)
+		}
+		if rule.MaxAmount, err = nullMoneyPtr(maxAmount); err != nil {
+			return nil, fmt.Errorf("failed to read maximum amount of category rule %d: %w", rule.ID, err)
+		}
+		rule.Sign = sign.String
+		rule.Keywords = splitKeywords(keywords.String)
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list category rules: %w", err)
+	}
+	return rules, nil
+}
+
+// AddRule validates and stores a rule, returning its ID
+func (r *SQLCategoryRuleRepository) AddRule(rule CategoryRule) (int64, error) {
+	if err := rule.Validate(); err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO category_rules (account, category, priority, partner_pattern, min_amount, max_amount, sign, keywords, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CURRENT_TIMESTAMP)
+		RETURNING id
+	`
+	var minAmount, maxAmount any
+	if rule.MinAmount != nil {
+		minAmount = rule.MinAmount.decimal()
+	}
+	if rule.MaxAmount != nil {
+		maxAmount = rule.MaxAmount.decimal()
+	}
+
+	var id int64
+	err := r.db.QueryRow(query, r.account, strings.TrimSpace(rule.Category), rule.Priority, nullString(rule.PartnerPattern),
+		minAmount, maxAmount, nullString(rule.Sign), nullString(strings.Join(rule.Keywords, ","))).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add category rule: %w", err)
+	}
+	return id, nil
+}
+
+// DeleteRule deletes a rule, failing when no rule has the ID
+func (r *SQLCategoryRuleRepository) DeleteRule(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM category_rules WHERE account = $1 AND id = $2`, r.account, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete category rule: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete category rule: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("category rule %d not found", id)
+	}
+	return nil
+}
+
+// splitKeywords parses a comma-separated keyword list, dropping empty entries
+func splitKeywords(value string) []string {
+	var keywords []string
+	for _, keyword := range strings.Split(value, ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// nullString maps an empty string to NULL
+func nullString(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// nullMoneyPtr parses a nullable amount in the statement currency, nil when NULL
+func nullMoneyPtr(value sql.NullString) (*Money, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	amount, err := parseMoney(value.String, statementCurrency)
+	if err != nil {
+		return nil, err
+	}
+	return &amount, nil
+}
@@ -0,0 +1,914 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+// runCommand dispatches a CLI subcommand such as "sessions report". Commands other than accounts,
+// purge and migrate, which work on the whole database, run with the settings of the account
+// selected by ACCOUNT.
+func runCommand(args []string) error {
+	switch args[0] {
+	case "accounts":
+		return runAccountsCommand(args[1:])
+	case "purge":
+		return runPurgeCommand(args[1:])
+	case "migrate":
+		return runMigrateCommand(args[1:])
+	}
+
+	account, err := selectedAccount()
+	if err != nil {
+		return err
+	}
+	return runAccountCommand(loadAccountConfig(account), args)
+}
+
+// runAccountCommand dispatches a subcommand working on the data of one account, with its settings
+func runAccountCommand(config AccountConfig, args []string) error {
+	switch args[0] {
+	case "sessions":
+		return runSessionsCommand(args[1:])
+	case "transactions":
+		return runTransactionsCommand(args[1:])
+	case "deliveries":
+		return runDeliveriesCommand(args[1:])
+	case "rules":
+		return runRulesCommand(config, args[1:])
+	case "budgets":
+		return runBudgetsCommand(args[1:])
+	case "subscriptions":
+		return runSubscriptionsCommand(args[1:])
+	case "balance":
+		return runBalanceCommand(config, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q (available: sessions, transactions, deliveries, rules, budgets, subscriptions, balance, accounts, purge, migrate)", args[0])
+	}
+}
+
+// runAccountsCommand handles "accounts list", which prints the N26 account and space IDs
+// discovered for every configured account
+func runAccountsCommand(args []string) error {
+	if len(args) != 1 || args[0] != "list" {
+		return fmt.Errorf("usage: accounts list")
+	}
+
+	names, err := loadAccountNames()
+	if err != nil {
+		return err
+	}
+	storage, err := openSharedStorageFromEnv()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := storage.Close(); err != nil {
+			log.Printf("Warning: Failed to close storage: %v", err)
+		}
+	}()
+
+	return listAccounts(os.Stdout, storage, names)
+}
+
+// listAccounts prints the spaces stored for every account, the main account first. An account
+// whose N26_ACCOUNT_ID differs from the discovered main account gets an extra override row.
+func listAccounts(out io.Writer, storage *Storage, names []string) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCOUNT\tSPACE\tID\tMAIN")
+	for _, name := range names {
+		if err := listAccount(tw, storage, loadAccountConfig(name)); err != nil {
+			return fmt.Errorf("failed to list account %s: %w", name, err)
+		}
+	}
+	return tw.Flush()
+}
+
+// listAccount prints the rows of one account for listAccounts
+func listAccount(tw io.Writer, storage *Storage, config AccountConfig) error {
+	accountStorage, err := storage.ForAccount(config.Name)
+	if err != nil {
+		return err
+	}
+	spaces, err := accountStorage.Spaces.ListSpaces()
+	if err != nil {
+		return err
+	}
+
+	name := config.Name
+	override := config.Getenv("N26_ACCOUNT_ID")
+	if len(spaces) == 0 && override == "" {
+		fmt.Fprintf(tw, "%s\t-\t-\tnot discovered yet, run once to log in\n", name)
+		return nil
+	}
+	labels := spaceNames(spaces)
+	for _, space := range spaces {
+		main := "-"
+		if space.Primary {
+			main = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, spaceName(labels, space.scope()), space.ID, main)
+	}
+	if discovered := primarySpace(spaces, ""); override != "" && discovered.ID != override {
+		fmt.Fprintf(tw, "%s\t%s\t%s\tyes (N26_ACCOUNT_ID)\n", name, mainSpaceName, override)
+	}
+	return nil
+}
+
+// runSessionsCommand handles "sessions report"
+func runSessionsCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: sessions report")
+	}
+
+	storage, err := openStorageFromEnv()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := storage.Close(); err != nil {
+			log.Printf("Warning: Failed to close storage: %v", err)
+		}
+	}()
+	cookieRepo := storage.Cookies
+
+	switch args[0] {
+	case "report":
+		sessions, err := cookieRepo.Sessions()
+		if err != nil {
+			return err
+		}
+		printSessionReport(os.Stdout, sessions, time.Now())
+		return nil
+	default:
+		return fmt.Errorf("unknown sessions command %q (available: report)", args[0])
+	}
+}
+
+// runTransactionsCommand handles "transactions list [-from YYYY-MM-DD] [-to YYYY-MM-DD] [-format table|csv]"
+func runTransactionsCommand(args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("usage: transactions list [-from YYYY-MM-DD] [-to YYYY-MM-DD] [-format table|csv]")
+	}
+
+	now := time.Now()
+	flags := flag.NewFlagSet("transactions list", flag.ContinueOnError)
+	from := flags.String("from", now.AddDate(0, 0, -30).Format("2006-01-02"), "first booking date to list")
+	to := flags.String("to", now.Format("2006-01-02"), "last booking date to list")
+	format := flags.String("format", "table", "output format, table or csv for exports")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *format != "table" && *format != "csv" {
+		return fmt.Errorf("invalid -format %q (expected table or csv)", *format)
+	}
+
+	fromDate, toDate, err := parseDateRange(*from, *to)
+	if err != nil {
+		return err
+	}
+
+	storage, err := openStorageFromEnv()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := storage.Close(); err != nil {
+			log.Printf("Warning: Failed to close storage: %v", err)
+		}
+	}()
+
+	transactions, err := storage.Transactions.ListTransactions(fromDate, toDate)
+	if err != nil {
+		return err
+	}
+	spaces, err := storage.Spaces.ListSpaces()
+	if err != nil {
+		return err
+	}
+	names := spaceNames(spaces)
+
+	if *format == "csv" {
+		return writeTransactionsCSV(os.Stdout, transactions, names)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BOOKED\tVALUE DATE\tPARTNER\tAMOUNT\tCATEGORY\tSPACE\tFIRST SEEN\tREMOVED")
+	for _, t := range transactions {
+		removed := "-"
+		if t.RemovedAt != nil {
+			removed = t.RemovedAt.UTC().Format(time.RFC3339)
+		}
+		category := t.Category
+		if category == "" {
+			category = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			formatStatementDate(t.BookingDate), formatStatementDate(t.ValueDate), t.PartnerName, t.Amount, category, spaceName(names, t.Space), t.FirstSeenAt.UTC().Format(time.RFC3339), removed)
+	}
+	tw.Flush()
+	fmt.Printf("\n%d transactions\n", len(transactions))
+	return nil
+}
+
+// writeTransactionsCSV exports transactions as CSV with ISO dates and dot-decimal amounts, labelled
+// with the names of their spaces
+func writeTransactionsCSV(out io.Writer, transactions []StoredTransaction, spaces map[string]string) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"booking_date", "value_date", "partner", "amount", "currency", "category", "first_seen_at", "removed_at", "space"}); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	for _, t := range transactions {
+		removed := ""
+		if t.RemovedAt != nil {
+			removed = t.RemovedAt.UTC().Format(time.RFC3339)
+		}
+		record := []string{t.BookingDate.Format("2006-01-02"), t.ValueDate.Format("2006-01-02"), t.PartnerName, t.Amount.decimal(), t.Amount.currency(),
+			t.Category, t.FirstSeenAt.UTC().Format(time.RFC3339), removed, spaceName(spaces, t.Space)}
+		if err := w.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+// runRulesCommand handles "rules list", "rules add", "rules delete ID" and "rules apply"
+func runRulesCommand(config AccountConfig, args []string) error {
+	usage := "usage: rules list | add -category NAME [-partner REGEX] [-min N] [-max N] [-sign debit|credit] [-keywords a,b] [-priority N] | delete ID | apply [-from YYYY-MM-DD] [-to YYYY-MM-DD]"
+	if len(args) == 0 {
+		return fmt.Errorf("%s", usage)
+	}
+
+	storage, err := openStorageFromEnv()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := storage.Close(); err != nil {
+			log.Printf("Warning: Failed to close storage: %v", err)
+		}
+	}()
+
+	switch args[0] {
+	case "list":
+		categorizer, err := loadCategorizer(config, storage.Rules)
+		if err != nil {
+			return err
+		}
+		printCategoryRules(os.Stdout, categorizer.Rules())
+		return nil
+	case "add":
+		rule, err := parseCategoryRuleFlags(args[1:])
+		if err != nil {
+			return err
+		}
+		id, err := storage.Rules.AddRule(rule)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Added rule %d for %s, run \"rules apply\" to re-categorize the history\n", id, rule.Category)
+		return nil
+	case "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: rules delete ID")
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid rule ID %q: %w", args[1], err)
+		}
+		if err := storage.Rules.DeleteRule(id); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted rule %d\n", id)
+		return nil
+	case "apply":
+		flags := flag.NewFlagSet("rules apply", flag.ContinueOnError)
+		from := flags.String("from", "", "first booking date to re-categorize (default: the whole history)")
+		to := flags.String("to", time.Now().Format("2006-01-02"), "last booking date to re-categorize")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *from == "" {
+			*from = "1970-01-01"
+		}
+		fromDate, toDate, err := parseDateRange(*from, *to)
+		if err != nil {
+			return err
+		}
+
+		categorizer, err := loadCategorizer(config, storage.Rules)
+		if err != nil {
+			return err
+		}
+		changed, total, err := recategorizeTransactions(storage.Transactions, categorizer, fromDate, toDate)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Re-categorized %d of %d transactions\n", changed, total)
+		return nil
+	default:
+		return fmt.Errorf("unknown rules command %q (available: list, add, delete, apply)", args[0])
+	}
+}
+
+// parseCategoryRuleFlags builds a rule from the flags of "rules add"
+func parseCategoryRuleFlags(args []string) (CategoryRule, error) {
+	flags := flag.NewFlagSet("rules add", flag.ContinueOnError)
+	category := flags.String("category", "", "category to assign")
+	partner := flags.String("partner", "", "regular expression matched against the partner name")
+	minAmount := flags.String("min", "", "minimum absolute amount")
+	maxAmount := flags.String("max", "", "maximum absolute amount")
+	sign := flags.String("sign", "", "debit or credit")
+	keywords := flags.String("keywords", "", "comma-separated keywords searched in the partner name and statement text")
+	priority := flags.Int("priority", 100, "rules with lower priorities are evaluated first")
+	if err := flags.Parse(args); err != nil {
+		return CategoryRule{}, err
+	}
+
+	rule := CategoryRule{
+		Category:       *category,
+		Priority:       *priority,
+		PartnerPattern: *partner,
+		Sign:           strings.ToLower(*sign),
+		Keywords:       splitKeywords(*keywords),
+	}
+	for _, bound := range []struct {
+		name  string
+		value string
+		dest  **Money
+	}{{"-min", *minAmount, &rule.MinAmount}, {"-max", *maxAmount, &rule.MaxAmount}} {
+		if bound.value == "" {
+			continue
+		}
+		amount, err := parseMoney(bound.value, statementCurrency)
+		if err != nil {
+			return CategoryRule{}, fmt.Errorf("invalid %s amount: %w", bound.name, err)
+		}
+		*bound.dest = &amount
+	}
+	if err := rule.Validate(); err != nil {
+		return CategoryRule{}, err
+	}
+	return rule, nil
+}
+
+// printCategoryRules prints the rules in evaluation order, marking the built-in defaults
+func printCategoryRules(out io.Writer, rules []CategoryRule) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tPRIORITY\tCATEGORY\tPARTNER\tAMOUNT\tSIGN\tKEYWORDS")
+	for _, rule := range rules {
+		id, priority := "default", "-"
+		if rule.ID > 0 {
+			id, priority = strconv.FormatInt(rule.ID, 10), strconv.Itoa(rule.Priority)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", id, priority, rule.Category, orDash(rule.PartnerPattern),
+			formatAmountRange(rule.MinAmount, rule.MaxAmount), orDash(rule.Sign), orDash(strings.Join(rule.Keywords, ",")))
+	}
+	tw.Flush()
+}
+
+// formatAmountRange formats the amount bounds of a rule, e.g. "10,00-50,00 EUR" or ">=10,00 EUR"
+func formatAmountRange(minAmount, maxAmount *Money) string {
+	switch {
+	case minAmount != nil && maxAmount != nil:
+		return minAmount.format() + "-" + maxAmount.String()
+	case minAmount != nil:
+		return ">=" + minAmount.String()
+	case maxAmount != nil:
+		return "<=" + maxAmount.String()
+	default:
+		return "-"
+	}
+}
+
+// orDash returns the value, or "-" when it is empty
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// runBudgetsCommand handles "budgets list [-month YYYY-MM]", "budgets set CATEGORY AMOUNT"
+// and "budgets delete CATEGORY"
+func runBudgetsCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: budgets list [-month YYYY-MM] | set CATEGORY AMOUNT | delete CATEGORY")
+	}
+
+	storage, err := openStorageFromEnv()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := storage.Close(); err != nil {
+			log.Printf("Warning: Failed to close storage: %v", err)
+		}
+	}()
+
+	switch args[0] {
+	case "list":
+		flags := flag.NewFlagSet("budgets list", flag.ContinueOnError)
+		month := flags.String("month", time.Now().Format("2006-01"), "month to show the spending of")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		monthDate, err := time.Parse("2006-01", *month)
+		if err != nil {
+			return fmt.Errorf("invalid -month: %w", err)
+		}
+
+		statuses, err := loadBudgetStatuses(storage, monthDate)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "CATEGORY\tLIMIT\tSPENT\tUSED")
+		for _, status := range statuses {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%.0f%%\n", status.Category, status.MonthlyLimit, status.Spent, status.Percent())
+		}
+		tw.Flush()
+		fmt.Printf("\n%d budgets for %s\n", len(statuses), monthDate.Format("January 2006"))
+		return nil
+	case "set":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: budgets set CATEGORY AMOUNT")
+		}
+		limit, err := parseMoney(args[2], statementCurrency)
+		if err != nil {
+			return err
+		}
+		if err := storage.Budgets.SetBudget(Budget{Category: args[1], MonthlyLimit: limit}); err != nil {
+			return err
+		}
+		fmt.Printf("Set the monthly budget of %s to %s\n", args[1], limit)
+		return nil
+	case "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: budgets delete CATEGORY")
+		}
+		if err := storage.Budgets.DeleteBudget(args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted the budget of %s\n", args[1])
+		return nil
+	default:
+		return fmt.Errorf("unknown budgets command %q (available: list, set, delete)", args[0])
+	}
+}
+
+// runSubscriptionsCommand handles "subscriptions list [-refresh]"
+func runSubscriptionsCommand(args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("usage: subscriptions list [-refresh]")
+	}
+
+	flags := flag.NewFlagSet("subscriptions list", flag.ContinueOnError)
+	refresh := flags.Bool("refresh", false, "detect the subscriptions in the stored history again before listing them")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	storage, err := openStorageFromEnv()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := storage.Close(); err != nil {
+			log.Printf("Warning: Failed to close storage: %v", err)
+		}
+	}()
+
+	var subscriptions []Subscription
+	if *refresh {
+		subscriptions, err = refreshSubscriptions(storage, time.Now())
+	} else {
+		subscriptions, err = storage.Subscriptions.ListSubscriptions()
+	}
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PARTNER\tCADENCE\tAMOUNT\tCHARGES\tLAST CHARGE\tNEXT CHARGE\tSTATUS")
+	for _, s := range subscriptions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n", s.PartnerName, s.Cadence, s.Amount, s.Occurrences,
+			s.LastChargeDate.Format("2006-01-02"), s.NextChargeDate.Format("2006-01-02"), s.Status)
+	}
+	tw.Flush()
+	fmt.Printf("\n%d subscriptions\n", len(subscriptions))
+	return nil
+}
+
+// balanceBarWidth is the width of the longest bar of the balance curve
+const balanceBarWidth = 40
+
+// runBalanceCommand handles "balance history [-from YYYY-MM-DD] [-to YYYY-MM-DD] [-space NAME] [-format table|csv]"
+// for the main account (N26_ACCOUNT_ID or the discovered one) or one of its spaces
+func runBalanceCommand(config AccountConfig, args []string) error {
+	if len(args) == 0 || args[0] != "history" {
+		return fmt.Errorf("usage: balance history [-from YYYY-MM-DD] [-to YYYY-MM-DD] [-space NAME] [-format table|csv]")
+	}
+
+	now := time.Now()
+	flags := flag.NewFlagSet("balance history", flag.ContinueOnError)
+	from := flags.String("from", now.AddDate(0, 0, -90).Format("2006-01-02"), "first day to list")
+	to := flags.String("to", now.Format("2006-01-02"), "last day to list")
+	format := flags.String("format", "table", "output format, table or csv for exports")
+	spaceFlag := flags.String("space", "", "name or ID of the space to list (default: the main account)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *format != "table" && *format != "csv" {
+		return fmt.Errorf("invalid -format %q (expected table or csv)", *format)
+	}
+
+	fromDate, toDate, err := parseDateRange(*from, *to)
+	if err != nil {
+		return err
+	}
+
+	storage, err := openStorageFromEnv()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := storage.Close(); err != nil {
+			log.Printf("Warning: Failed to close storage: %v", err)
+		}
+	}()
+
+	account, err := resolveAccountID(config, storage)
+	if err != nil {
+		return err
+	}
+	if *spaceFlag != "" {
+		spaces, err := storage.Spaces.ListSpaces()
+		if err != nil {
+			return err
+		}
+		space, ok := findSpace(spaces, *spaceFlag)
+		if !ok {
+			return fmt.Errorf("unknown space %q, spaces are stored by the first run that lists them", *spaceFlag)
+		}
+		if !space.Primary {
+			account = space.ID
+		}
+	}
+
+	// Snapshots carry a time of day, include the whole last day
+	snapshots, err := storage.Balances.ListSnapshots(account, fromDate, toDate.AddDate(0, 0, 1).Add(-time.Nanosecond))
+	if err != nil {
+		return err
+	}
+
+	if *format == "csv" {
+		return writeBalanceCSV(os.Stdout, snapshots)
+	}
+
+	maxAbs := 0.0
+	for _, s := range snapshots {
+		maxAbs = math.Max(maxAbs, math.Abs(s.Balance.Float()))
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RECORDED\tPERIOD\tBALANCE\t")
+	for _, s := range snapshots {
+		fmt.Fprintf(tw, "%s\t%s – %s\t%s\t%s\n", s.RecordedAt.Local().Format("2006-01-02 15:04"),
+			s.PeriodStart.Format("2006-01-02"), s.PeriodEnd.Format("2006-01-02"), s.Balance, balanceBar(s.Balance.Float(), maxAbs))
+	}
+	tw.Flush()
+	fmt.Printf("\n%d snapshots\n", len(snapshots))
+	return nil
+}
+
+// balanceBar draws a balance as a bar scaled to the largest balance shown, negative balances with "-"
+func balanceBar(balance, maxAbs float64) string {
+	if maxAbs == 0 {
+		return ""
+	}
+	width := int(math.Round(math.Abs(balance) / maxAbs * balanceBarWidth))
+	if balance < 0 {
+		return strings.Repeat("-", width)
+	}
+	return strings.Repeat("#", width)
+}
+
+// writeBalanceCSV exports balance snapshots as CSV with RFC 3339 timestamps and dot-decimal balances
+func writeBalanceCSV(out io.Writer, snapshots []BalanceSnapshot) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"recorded_at", "period_start", "period_end", "balance"}); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	for _, s := range snapshots {
+		record := []string{s.RecordedAt.UTC().Format(time.RFC3339), s.PeriodStart.Format("2006-01-02"),
+			s.PeriodEnd.Format("2006-01-02"), s.Balance.decimal()}
+		if err := w.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+// runPurgeCommand handles "purge [-before YYYY-MM-DD] [-dry-run]", which applies the retention
+// policy (or one cutoff for every data type), and "purge audit [-limit N]"
+func runPurgeCommand(args []string) error {
+	if len(args) > 0 && args[0] == "audit" {
+		return runPurgeAuditCommand(args[1:])
+	}
+
+	flags := flag.NewFlagSet("purge", flag.ContinueOnError)
+	before := flags.String("before", "", "purge every data type older than this date instead of its configured retention")
+	dryRun := flags.Bool("dry-run", false, "only report what would be purged")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var cutoff time.Time
+	if *before != "" {
+		var err error
+		if cutoff, err = time.Parse("2006-01-02", *before); err != nil {
+			return fmt.Errorf("invalid -before date: %w", err)
+		}
+		if err := validatePurgeCutoff(cutoff, now); err != nil {
+			return err
+		}
+	}
+	rules := loadRetentionPolicy().rules(now, cutoff)
+	if len(rules) == 0 {
+		fmt.Println("No retention configured, nothing to purge (set RETENTION_<TYPE>_DAYS or use -before)")
+		return nil
+	}
+
+	storage, err := openSharedStorageFromEnv()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := storage.Close(); err != nil {
+			log.Printf("Warning: Failed to close storage: %v", err)
+		}
+	}()
+
+	entries, err := purgeExpiredData(storage.Retention, rules, purgeSourceCommand, *dryRun, now)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATA\tOLDER THAN\tACTION\tROWS")
+	for _, entry := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", entry.DataType, entry.Cutoff.Format("2006-01-02"), entry.Action, entry.Affected)
+	}
+	tw.Flush()
+
+	fmt.Println("\nNever purged:")
+	tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, exempt := range retentionExemptTables {
+		fmt.Fprintf(tw, "  %s\t%s\n", exempt.table, exempt.reason)
+	}
+	tw.Flush()
+	if *dryRun {
+		fmt.Println("\nDry run, nothing was changed")
+	}
+	return nil
+}
+
+// runPurgeAuditCommand handles "purge audit [-limit N]"
+func runPurgeAuditCommand(args []string) error {
+	flags := flag.NewFlagSet("purge audit", flag.ContinueOnError)
+	limit := flags.Int("limit", 50, "number of most recent purge records to list")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	storage, err := openStorageFromEnv()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := storage.Close(); err != nil {
+			log.Printf("Warning: Failed to close storage: %v", err)
+		}
+	}()
+
+	entries, err := storage.Retention.ListPurges(*limit)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PURGED AT\tSOURCE\tDATA\tOLDER THAN\tACTION\tROWS")
+	for _, entry := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\n", entry.PurgedAt.Format(time.RFC3339), entry.Source, entry.DataType,
+			entry.Cutoff.Format("2006-01-02"), entry.Action, entry.Affected)
+	}
+	tw.Flush()
+	return nil
+}
+
+// migrateUsage lists the migrate subcommands
+const migrateUsage = "usage: migrate up | down N | goto VERSION | version | force VERSION"
+
+// runMigrateCommand handles "migrate up | down N | goto VERSION | version | force VERSION". It opens
+// the database without the startup schema check, so it also works on a dirty or newer schema.
+func runMigrateCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	dbConn, err := loadSecretString(globalConfig, "DB_CONN")
+	if err != nil {
+		return err
+	}
+	backend, target, err := storageBackend(dbConn)
+	if err != nil {
+		return err
+	}
+	if backend == backendMemory {
+		return fmt.Errorf("the in-memory backend has no schema to migrate")
+	}
+	db, err := openDatabase(backend, target)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return migrateSchema(os.Stdout, db, backend, args)
+}
+
+// migrateSchema runs one migrate subcommand against an open database and reports the resulting version
+func migrateSchema(out io.Writer, db *sql.DB, backend string, args []string) error {
+	m, err := newMigrate(db, backend)
+	if err != nil {
+		return err
+	}
+
+	// Every subcommand but up and version takes one number
+	var n int
+	switch args[0] {
+	case "up", "version":
+		if len(args) != 1 {
+			return errors.New(migrateUsage)
+		}
+	case "down", "goto", "force":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		if n, err = strconv.Atoi(args[1]); err != nil || n < 0 {
+			return fmt.Errorf("invalid %s argument %q, expected a non-negative number", args[0], args[1])
+		}
+	default:
+		return fmt.Errorf("unknown migrate subcommand %q (%s)", args[0], migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		err = m.Up()
+	case "down":
+		if n == 0 {
+			return fmt.Errorf("down needs the number of migrations to revert")
+		}
+		err = m.Steps(-n)
+	case "goto":
+		err = m.Migrate(uint(n))
+	case "force":
+		err = m.Force(n)
+	}
+	if errors.Is(err, migrate.ErrNoChange) {
+		fmt.Fprintln(out, "No change")
+	} else if err != nil {
+		return fmt.Errorf("failed to migrate %s: %w", args[0], err)
+	}
+
+	version, dirty, err := schemaVersion(m)
+	if err != nil {
+		return err
+	}
+	latest, err := latestMigrationVersion(backend)
+	if err != nil {
+		return err
+	}
+	status := "clean"
+	switch {
+	case dirty:
+		status = "dirty, fix the failed migration and run 'migrate force VERSION'"
+	case version > latest:
+		status = "newer than this binary"
+	case version < latest:
+		status = "pending migrations, run 'migrate up'"
+	}
+	fmt.Fprintf(out, "Schema version %d of %d (%s)\n", version, latest, status)
+	return nil
+}
+
+// parseDateRange parses the YYYY-MM-DD -from and -to flags of a command
+func parseDateRange(from, to string) (time.Time, time.Time, error) {
+	fromDate, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid -from date: %w", err)
+	}
+	toDate, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid -to date: %w", err)
+	}
+	return fromDate, toDate, nil
+}
+
+// runDeliveriesCommand handles "deliveries list [-status pending|sent|failed]"
+func runDeliveriesCommand(args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("usage: deliveries list [-status pending|sent|failed]")
+	}
+
+	flags := flag.NewFlagSet("deliveries list", flag.ContinueOnError)
+	status := flags.String("status", "", "only list deliveries with this status")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	switch DeliveryStatus(*status) {
+	case "", DeliveryPending, DeliverySent, DeliveryFailed:
+	default:
+		return fmt.Errorf("invalid -status %q (expected pending, sent or failed)", *status)
+	}
+
+	storage, err := openStorageFromEnv()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := storage.Close(); err != nil {
+			log.Printf("Warning: Failed to close storage: %v", err)
+		}
+	}()
+
+	deliveries, err := storage.Deliveries.ListDeliveries(DeliveryStatus(*status))
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATEMENT\tCHANNEL\tKIND\tSTATUS\tATTEMPTS\tUPDATED\tLAST ERROR")
+	for _, d := range deliveries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			d.Key[:min(len(d.Key), 12)], d.Channel, d.Kind, d.Status, d.Attempts, d.UpdatedAt.UTC().Format(time.RFC3339), d.LastError)
+	}
+	tw.Flush()
+	fmt.Printf("\n%d deliveries\n", len(deliveries))
+	return nil
+}
+
+// openStorageFromEnv opens the storage of the account selected by ACCOUNT in the backend
+// configured in DB_CONN (or DB_CONN_FILE / DB_CONN_COMMAND)
+func openStorageFromEnv() (*Storage, error) {
+	account, err := selectedAccount()
+	if err != nil {
+		return nil, err
+	}
+	storage, err := openSharedStorageFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	accountStorage, err := storage.ForAccount(account)
+	if err != nil {
+		storage.Close()
+		return nil, err
+	}
+	return accountStorage, nil
+}
+
+// openSharedStorageFromEnv opens the storage backend configured in DB_CONN for the data shared by
+// every account, such as the retention repository
+func openSharedStorageFromEnv() (*Storage, error) {
+	dbConn, err := loadSecretString(globalConfig, "DB_CONN")
+	if err != nil {
+		return nil, err
+	}
+
+	storage, err := OpenStorage(dbConn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	return storage, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+	_ "time/tzdata" // Statement dates need the timezone database, also where the system has none
+)
+
+// defaultTimezone is the timezone of statement dates when N26_TIMEZONE is not set
+const defaultTimezone = "Europe/Berlin"
+
+// timezones caches the location of every N26_TIMEZONE value seen, invalid ones map to the default
+var timezones sync.Map
+
+// statementLocation returns the timezone statement dates are in, as configured in N26_TIMEZONE (an
+// IANA name such as "Europe/Madrid", default Europe/Berlin). It is shared by every account, dates
+// are read back from the shared database in it. An invalid name falls back to the default with a
+// warning.
+func statementLocation() *time.Location {
+	name := globalConfig.Getenv("N26_TIMEZONE")
+	if name == "" {
+		name = defaultTimezone
+	}
+	if loc, ok := timezones.Load(name); ok {
+		return loc.(*time.Location)
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc, _ = time.LoadLocation(defaultTimezone)
+	}
+	actual, loaded := timezones.LoadOrStore(name, loc)
+	if err != nil && !loaded {
+		log.Printf("Warning: Invalid value for N26_TIMEZONE (%q), using default %s", name, defaultTimezone)
+	}
+	return actual.(*time.Location)
+}
+
+// envInt reads an integer setting, falling back to def when unset or invalid
+func (c AccountConfig) envInt(name string, def int) int {
+	value := c.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: Invalid value for %s (%q), using default %d", name, value, def)
+		return def
+	}
+	return parsed
+}
+
+// envBool reads a boolean setting (1/0, true/false), falling back to def when unset or invalid
+func (c AccountConfig) envBool(name string, def bool) bool {
+	value := c.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Warning: Invalid value for %s (%q), using default %t", name, value, def)
+		return def
+	}
+	return parsed
+}
+
+// envFloat reads a decimal setting, falling back to def when unset or invalid
+func (c AccountConfig) envFloat(name string, def float64) float64 {
+	value := c.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Warning: Invalid value for %s (%q), using default %g", name, value, def)
+		return def
+	}
+	return parsed
+}
@@ -14,11 +14,24 @@ import (
 type CookieRepository interface {
 	Get() (string, error)
 	Save(cookie string) error
+	MarkValidated() error
+	MarkInvalid() error
+	Sessions() ([]CookieSession, error)
+}
+
+// CookieSession describes the lifecycle of a single stored cookie
+type CookieSession struct {
+	ID              int64
+	CreatedAt       time.Time
+	FirstUsedAt     *time.Time // First time the cookie was used against the endpoint
+	LastValidatedAt *time.Time // Last time the endpoint accepted the cookie
+	InvalidatedAt   *time.Time // Time the endpoint first rejected the cookie
 }
 
 // PostgresCookieRepository implements CookieRepository using PostgreSQL storage
 type PostgresCookieRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	account string
 }
 
 // GetDB returns the underlying database connection (for sharing with other repositories)
@@ -26,8 +39,8 @@ func (r *PostgresCookieRepository) GetDB() *sql.DB {
 	return r.db
 }
 
-// NewPostgresCookieRepository creates a new PostgreSQL-based cookie repository
-func NewPostgresCookieRepository(connString string) (*PostgresCookieRepository, error) {
+// openPostgresDB opens a PostgreSQL connection pool, the schema is prepared by OpenStorage
+func openPostgresDB(connString string) (*sql.DB, error) {
 	// Parse connection string and register driver
 	config, err := pgx.ParseConfig(connString)
 	if err != nil {
@@ -35,17 +48,12 @@ func NewPostgresCookieRepository(connString string) (*PostgresCookieRepository,
 	}
 
 	// Use stdlib to register pgx driver
-	db := stdlib.OpenDB(*config)
-
-	repo := &PostgresCookieRepository{db: db}
-
-	// Run migrations
-	if err := runMigrations(db); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
-	}
+	return stdlib.OpenDB(*config), nil
+}
 
-	return repo, nil
+// NewPostgresCookieRepository creates a PostgreSQL-based cookie repository for an account on a migrated database
+func NewPostgresCookieRepository(db *sql.DB, account string) (*PostgresCookieRepository, error) {
+	return &PostgresCookieRepository{db: db, account: account}, nil
 }
 
 // Get retrieves the most recent cookie from PostgreSQL
@@ -53,8 +61,8 @@ func (r *PostgresCookieRepository) Get() (string, error) {
 	var cookieValue string
 	var updatedAt time.Time
 
-	query := `SELECT cookie_value, updated_at FROM cookies ORDER BY updated_at DESC LIMIT 1`
-	err := r.db.QueryRow(query).Scan(&cookieValue, &updatedAt)
+	query := `SELECT cookie_value, updated_at FROM cookies WHERE account = $1 ORDER BY updated_at DESC, id DESC LIMIT 1`
+	err := r.db.QueryRow(query, r.account).Scan(&cookieValue, &updatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -73,7 +81,91 @@ func (r *PostgresCookieRepository) Get() (string, error) {
 
 // Save stores the cookie to PostgreSQL with a timestamp
 func (r *PostgresCookieRepository) Save(cookie string) error {
-	// Trim the cookie before saving
+	cookie = normalizeCookie(cookie)
+
+	// Insert or update the cookie (we'll always insert a new row to keep history)
+	query := `INSERT INTO cookies (account, cookie_value, updated_at) VALUES ($1, $2, CURRENT_TIMESTAMP)`
+	_, err := r.db.Exec(query, r.account, cookie)
+	if err != nil {
+		return fmt.Errorf("failed to save cookie: %w", err)
+	}
+
+	fmt.Println("Cookie saved to PostgreSQL")
+	return nil
+}
+
+// MarkValidated records that the most recent cookie was accepted by the endpoint
+func (r *PostgresCookieRepository) MarkValidated() error {
+	query := `
+		UPDATE cookies
+		SET first_used_at = COALESCE(first_used_at, CURRENT_TIMESTAMP),
+			last_validated_at = CURRENT_TIMESTAMP
+		WHERE id = (SELECT id FROM cookies WHERE account = $1 ORDER BY updated_at DESC, id DESC LIMIT 1)
+	`
+	if _, err := r.db.Exec(query, r.account); err != nil {
+		return fmt.Errorf("failed to mark cookie as validated: %w", err)
+	}
+	return nil
+}
+
+// MarkInvalid records that the most recent cookie was rejected by the endpoint
+func (r *PostgresCookieRepository) MarkInvalid() error {
+	query := `
+		UPDATE cookies
+		SET first_used_at = COALESCE(first_used_at, CURRENT_TIMESTAMP),
+			invalidated_at = COALESCE(invalidated_at, CURRENT_TIMESTAMP)
+		WHERE id = (SELECT id FROM cookies WHERE account = $1 ORDER BY updated_at DESC, id DESC LIMIT 1)
+	`
+	if _, err := r.db.Exec(query, r.account); err != nil {
+		return fmt.Errorf("failed to mark cookie as invalid: %w", err)
+	}
+	return nil
+}
+
+// Sessions returns the lifecycle of every stored cookie, oldest first
+func (r *PostgresCookieRepository) Sessions() ([]CookieSession, error) {
+	query := `
+		SELECT id, COALESCE(created_at, updated_at), first_used_at, last_validated_at, invalidated_at
+		FROM cookies
+		WHERE account = $1
+		ORDER BY created_at ASC, id ASC
+	`
+	rows, err := r.db.Query(query, r.account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cookie sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []CookieSession
+	for rows.Next() {
+		var session CookieSession
+		var firstUsed, lastValidated, invalidated sql.NullTime
+		if err := rows.Scan(&session.ID, &session.CreatedAt, &firstUsed, &lastValidated, &invalidated); err != nil {
+			return nil, fmt.Errorf("failed to scan cookie session: %w", err)
+		}
+		session.FirstUsedAt = nullTimePtr(firstUsed)
+		session.LastValidatedAt = nullTimePtr(lastValidated)
+		session.InvalidatedAt = nullTimePtr(invalidated)
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list cookie sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// nullTimePtr converts a nullable timestamp into a pointer (nil when NULL)
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	value := t.Time
+	return &value
+}
+
+// normalizeCookie trims the cookie and strips the TIMESTAMP prefix added by Get
+func normalizeCookie(cookie string) string {
 	cookie = strings.TrimSpace(cookie)
 	cookie = strings.Trim(cookie, " \t\n\r\u0000\u200b\ufeff")
 
@@ -84,16 +176,7 @@ func (r *PostgresCookieRepository) Save(cookie string) error {
 			cookie = parts[1]
 		}
 	}
-
-	// Insert or update the cookie (we'll always insert a new row to keep history)
-	query := `INSERT INTO cookies (cookie_value, updated_at) VALUES ($1, CURRENT_TIMESTAMP)`
-	_, err := r.db.Exec(query, cookie)
-	if err != nil {
-		return fmt.Errorf("failed to save cookie: %w", err)
-	}
-
-	fmt.Println("Cookie saved to PostgreSQL")
-	return nil
+	return cookie
 }
 
 // Close closes the database connection
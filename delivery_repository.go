@@ -0,0 +1,280 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DeliveryStatus is the state of a statement's notification on one channel
+type DeliveryStatus string
+
+const (
+	DeliveryPending DeliveryStatus = "pending" // Queued, not attempted yet
+	DeliverySent    DeliveryStatus = "sent"    // Delivered, never sent again
+	DeliveryFailed  DeliveryStatus = "failed"  // Last attempt failed, retried on the next run
+)
+
+// DeliveryRepository tracks the delivery of transaction changes to each notification channel.
+// A statement is delivered at most once per channel and change kind.
+type DeliveryRepository interface {
+	Enqueue(channels []string, changes []TransactionChange) error
+	Pending(channel string) ([]TransactionChange, error)
+	MarkSent(channel string, kind ChangeKind, keys []string) error
+	MarkFailed(channel string, kind ChangeKind, keys []string, cause error) error
+	ListDeliveries(status DeliveryStatus) ([]Delivery, error)
+	RekeyDeliveries(oldKey, newKey string) error
+}
+
+// Delivery is the delivery state of one change of a statement on one channel
+type Delivery struct {
+	Key       string
+	Channel   string
+	Kind      ChangeKind
+	Status    DeliveryStatus
+	Attempts  int
+	LastError string
+	UpdatedAt time.Time
+}
+
+// SQLDeliveryRepository implements DeliveryRepository on both PostgreSQL and SQLite,
+// the queries only use syntax the two have in common
+type SQLDeliveryRepository struct {
+	db      *sql.DB
+	account string
+}
+
+// NewSQLDeliveryRepository creates a delivery repository for an account on a migrated database
+func NewSQLDeliveryRepository(db *sql.DB, account string) *SQLDeliveryRepository {
+	return &SQLDeliveryRepository{db: db, account: account}
+}
+
+// Enqueue adds a pending delivery of every change to every channel.
+// Deliveries that already exist keep their state.
+func (r *SQLDeliveryRepository) Enqueue(channels []string, changes []TransactionChange) error {
+	if len(channels) == 0 || len(changes) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO deliveries (account, statement_key, channel, kind, status, attempts, previous_booking_date, previous_amount, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, 'pending', 0, $5, $6, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT (account, statement_key, channel, kind) DO NOTHING
+	`
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare delivery insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, channel := range channels {
+		for _, change := range changes {
+			var previousDate, previousAmount any
+			if change.Previous != nil {
+				previousDate, previousAmount = statementRecordColumns(*change.Previous)
+			}
+			if _, err := stmt.Exec(r.account, change.Record.Key, channel, string(change.Kind), previousDate, previousAmount); err != nil {
+				return fmt.Errorf("failed to enqueue delivery: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit deliveries: %w", err)
+	}
+	return nil
+}
+
+// Pending returns the changes still to be delivered to a channel (pending or failed),
+// oldest booking first. A statement that was never notified, such as a removed transaction
+// the first delivery failed for, is described by its stored transaction.
+func (r *SQLDeliveryRepository) Pending(channel string) ([]TransactionChange, error) {
+	query := `
+		SELECT d.statement_key, d.kind, d.previous_booking_date, CAST(d.previous_amount AS TEXT),
+			s.key_version, s.booking_date, s.partner_name, CAST(s.amount AS TEXT), s.space,
+			t.booking_date, t.partner_name, CAST(t.amount AS TEXT), t.space, t.category
+		FROM deliveries d
+		LEFT JOIN statements s ON s.account = d.account AND s.statement_key = d.statement_key
+		LEFT JOIN transactions t ON t.account = d.account AND t.statement_key = d.statement_key
+		WHERE d.account = $1 AND d.channel = $2 AND d.status IN ('pending', 'failed')
+		ORDER BY COALESCE(s.booking_date, t.booking_date) ASC, d.id ASC
+	`
+	rows, err := r.db.Query(query, r.account, channel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []TransactionChange
+	for rows.Next() {
+		var change TransactionChange
+		record := &change.Record
+		var bookingDate, storedDate, previousDate sql.NullTime
+		var partner, storedPartner, space, storedSpace, category sql.NullString
+		var amount, storedAmount, previousAmount sql.NullString
+		var keyVersion sql.NullInt64
+		err := rows.Scan(&record.Key, &change.Kind, &previousDate, &previousAmount,
+			&keyVersion, &bookingDate, &partner, &amount, &space,
+			&storedDate, &storedPartner, &storedAmount, &storedSpace, &category)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan pending delivery: %w", err)
+		}
+		if keyVersion.Valid {
+			record.KeyVersion = int(keyVersion.Int64)
+		} else {
+			// Not notified yet, the stored transaction is keyed with the current version
+			record.KeyVersion = statementKeyVersion
+			bookingDate, partner, amount, space = storedDate, storedPartner, storedAmount, storedSpace
+		}
+		if bookingDate.Valid {
+			record.BookingDate = statementDate(bookingDate.Time)
+		}
+		record.PartnerName = partner.String
+		record.Space = space.String
+		record.Category = category.String
+		if amount.Valid {
+			if record.Amount, err = parseMoney(amount.String, statementCurrency); err != nil {
+				return nil, fmt.Errorf("failed to read amount of delivery %s: %w", record.Key, err)
+			}
+		}
+		if previousDate.Valid || previousAmount.Valid {
+			change.Previous = &StatementRecord{PartnerName: record.PartnerName}
+			if previousDate.Valid {
+				change.Previous.BookingDate = statementDate(previousDate.Time)
+			}
+			if previousAmount.Valid {
+				if change.Previous.Amount, err = parseMoney(previousAmount.String, statementCurrency); err != nil {
+					return nil, fmt.Errorf("failed to read previous amount of delivery %s: %w", record.Key, err)
+				}
+			}
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list pending deliveries: %w", err)
+	}
+	return changes, nil
+}
+
+// MarkSent records a successful delivery of one kind of change of the statements to a channel
+func (r *SQLDeliveryRepository) MarkSent(channel string, kind ChangeKind, keys []string) error {
+	query := `
+		UPDATE deliveries
+		SET status = 'sent', attempts = attempts + 1, last_error = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE account = $1 AND channel = $2 AND kind = $3 AND statement_key = $4
+	`
+	return r.updateDeliveries(query, keys, channel, kind)
+}
+
+// MarkFailed records a failed delivery attempt of one kind of change of the statements to a channel
+func (r *SQLDeliveryRepository) MarkFailed(channel string, kind ChangeKind, keys []string, cause error) error {
+	query := `
+		UPDATE deliveries
+		SET status = 'failed', attempts = attempts + 1, last_error = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE account = $1 AND channel = $2 AND kind = $3 AND statement_key = $4
+	`
+	return r.updateDeliveries(query, keys, channel, kind, cause.Error())
+}
+
+// updateDeliveries runs an update for every key in one transaction.
+// The query takes the account as $1, the channel as $2, the kind as $3, the key as $4 and extra arguments after that.
+func (r *SQLDeliveryRepository) updateDeliveries(query string, keys []string, channel string, kind ChangeKind, extra ...any) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare delivery update: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, key := range keys {
+		args := append([]any{r.account, channel, string(kind), key}, extra...)
+		if _, err := stmt.Exec(args...); err != nil {
+			return fmt.Errorf("failed to update delivery: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit delivery update: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries returns every delivery with the given status (all of them when empty), oldest first
+func (r *SQLDeliveryRepository) ListDeliveries(status DeliveryStatus) ([]Delivery, error) {
+	query := `
+		SELECT statement_key, channel, kind, status, attempts, last_error, updated_at
+		FROM deliveries
+		WHERE account = $1 AND ($2 = '' OR status = $2)
+		ORDER BY id ASC
+	`
+	rows, err := r.db.Query(query, r.account, string(status))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+	for rows.Next() {
+		var delivery Delivery
+		var lastError sql.NullString
+		err := rows.Scan(&delivery.Key, &delivery.Channel, &delivery.Kind, &delivery.Status, &delivery.Attempts, &lastError, &delivery.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan delivery: %w", err)
+		}
+		delivery.LastError = lastError.String
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// RekeyDeliveries moves the deliveries of a statement to its new key
+func (r *SQLDeliveryRepository) RekeyDeliveries(oldKey, newKey string) error {
+	if oldKey == newKey {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin rekey: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Deliveries under the old key carry the state of what was already sent
+	if _, err := tx.Exec(`
+		DELETE FROM deliveries
+		WHERE account = $1 AND statement_key = $2
+		AND EXISTS (
+			SELECT 1 FROM deliveries AS old
+			WHERE old.account = $1 AND old.statement_key = $3 AND old.channel = deliveries.channel AND old.kind = deliveries.kind
+		)
+	`, r.account, newKey, oldKey); err != nil {
+		return fmt.Errorf("failed to clear new delivery key: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE deliveries SET statement_key = $1 WHERE account = $2 AND statement_key = $3`, newKey, r.account, oldKey); err != nil {
+		return fmt.Errorf("failed to rekey deliveries: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rekey: %w", err)
+	}
+	return nil
+}
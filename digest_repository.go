@@ -0,0 +1,59 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DigestRepository remembers which digest periods were sent to which notification channel,
+// so each week or month is sent once per channel however irregularly the program runs
+type DigestRepository interface {
+	LastSentDigest(kind DigestKind, channel string) (time.Time, bool, error)
+	MarkDigestSent(kind DigestKind, channel string, start, end time.Time) error
+}
+
+// SQLDigestRepository implements DigestRepository on both PostgreSQL and SQLite,
+// the queries only use syntax the two have in common
+type SQLDigestRepository struct {
+	db      *sql.DB
+	account string
+}
+
+// NewSQLDigestRepository creates a digest repository for an account on a migrated database
+func NewSQLDigestRepository(db *sql.DB, account string) *SQLDigestRepository {
+	return &SQLDigestRepository{db: db, account: account}
+}
+
+// LastSentDigest returns the start of the latest period of the kind sent to the channel,
+// false when none was sent yet
+func (r *SQLDigestRepository) LastSentDigest(kind DigestKind, channel string) (time.Time, bool, error) {
+	query := `
+		SELECT period_start FROM sent_digests
+		WHERE account = $1 AND kind = $2 AND channel = $3
+		ORDER BY period_start DESC
+		LIMIT 1
+	`
+	var start time.Time
+	err := r.db.QueryRow(query, r.account, string(kind), channel).Scan(&start)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to read last sent digest: %w", err)
+	}
+	return statementDate(start), true, nil
+}
+
+// MarkDigestSent records that the digest of a period was sent to the channel
+func (r *SQLDigestRepository) MarkDigestSent(kind DigestKind, channel string, start, end time.Time) error {
+	query := `
+		INSERT INTO sent_digests (account, kind, channel, period_start, period_end, sent_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+		ON CONFLICT (account, kind, channel, period_start) DO NOTHING
+	`
+	if _, err := r.db.Exec(query, r.account, string(kind), channel, sqlDate(start), sqlDate(end)); err != nil {
+		return fmt.Errorf("failed to mark digest as sent: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DigestKind is how long the period of a spending digest is
+type DigestKind string
+
+const (
+	DigestWeekly  DigestKind = "weekly"  // Monday to Sunday
+	DigestMonthly DigestKind = "monthly" // Calendar month
+)
+
+// digestTopCount is how many partners and transactions a digest lists
+const digestTopCount = 3
+
+// digestMaxCatchUp is how many missed periods are sent when the program did not run for a while,
+// the oldest are skipped beyond that
+const digestMaxCatchUp = 4
+
+// periodStart returns the first day of the period of the kind that t falls in, in the account's timezone
+func (k DigestKind) periodStart(t time.Time) time.Time {
+	loc := statementLocation()
+	t = t.In(loc)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	if k == DigestWeekly {
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return monthStart(day)
+}
+
+// nextPeriod returns the first day of the period after the one starting at start
+func (k DigestKind) nextPeriod(start time.Time) time.Time {
+	if k == DigestWeekly {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// previousPeriod returns the first day of the period before the one starting at start
+func (k DigestKind) previousPeriod(start time.Time) time.Time {
+	if k == DigestWeekly {
+		return start.AddDate(0, 0, -7)
+	}
+	return start.AddDate(0, -1, 0)
+}
+
+// unit names the period in text, "week" or "month"
+func (k DigestKind) unit() string {
+	if k == DigestWeekly {
+		return "week"
+	}
+	return "month"
+}
+
+// loadDigestKinds reads DIGESTS, a comma-separated list of weekly and monthly (default both, "off" disables)
+func loadDigestKinds(config AccountConfig) []DigestKind {
+	value := strings.ToLower(strings.TrimSpace(config.Getenv("DIGESTS")))
+	if value == "" {
+		return []DigestKind{DigestWeekly, DigestMonthly}
+	}
+	if value == "off" || value == "none" {
+		return nil
+	}
+
+	var kinds []DigestKind
+	for _, part := range strings.Split(value, ",") {
+		kind := DigestKind(strings.TrimSpace(part))
+		if kind != DigestWeekly && kind != DigestMonthly {
+			log.Printf("Warning: Unknown digest %q in DIGESTS, expected weekly or monthly", part)
+			continue
+		}
+		if !slices.Contains(kinds, kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// pendingDigestPeriods returns the starts of the completed periods a channel has not received,
+// oldest first. A channel that never received a digest of the kind only gets the latest period.
+func pendingDigestPeriods(kind DigestKind, lastSent time.Time, sent bool, now time.Time) []time.Time {
+	latest := kind.previousPeriod(kind.periodStart(now))
+	if !sent {
+		return []time.Time{latest}
+	}
+
+	var starts []time.Time
+	for start := kind.nextPeriod(lastSent); !start.After(latest); start = kind.nextPeriod(start) {
+		starts = append(starts, start)
+	}
+	if len(starts) > digestMaxCatchUp {
+		starts = starts[len(starts)-digestMaxCatchUp:]
+	}
+	return starts
+}
+
+// PartnerTotal is the spending with one partner in a digest period
+type PartnerTotal struct {
+	PartnerName string
+	Spent       Money // Debits minus refunds, positive when money went out
+	Count       int
+}
+
+// DigestFlows is the money that came in and went out in a period
+type DigestFlows struct {
+	In    Money // Sum of credits, positive
+	Out   Money // Sum of debits, negative
+	Net   Money // The change of the balance the flows made
+	Count int
+}
+
+// Digest summarizes the transactions of one week or month
+type Digest struct {
+	Kind        DigestKind
+	Start       time.Time
+	End         time.Time // Last day, inclusive
+	Flows       DigestFlows
+	Previous    DigestFlows // The period before, for comparison
+	TopPartners []PartnerTotal
+	Biggest     []StoredTransaction
+	Closing     *BalanceSnapshot // Latest balance recorded up to the end of the period, nil when none
+}
+
+// digestFlows sums the credits and debits of transactions, leaving out removed ones. It fails
+// when the transactions are in more than one currency.
+func digestFlows(transactions []StoredTransaction) (DigestFlows, error) {
+	var flows DigestFlows
+	for _, t := range transactions {
+		if t.RemovedAt != nil {
+			continue
+		}
+		var err error
+		if t.Amount.Minor >= 0 {
+			flows.In, err = flows.In.Add(t.Amount)
+		} else {
+			flows.Out, err = flows.Out.Add(t.Amount)
+		}
+		if err != nil {
+			return DigestFlows{}, err
+		}
+		flows.Count++
+	}
+
+	net, err := flows.In.Add(flows.Out)
+	if err != nil {
+		return DigestFlows{}, err
+	}
+	flows.Net = net
+	return flows, nil
+}
+
+// buildDigest summarizes the transactions of a period against those of the period before
+func buildDigest(kind DigestKind, start time.Time, transactions, previous []StoredTransaction, closing *BalanceSnapshot) (Digest, error) {
+	flows, err := digestFlows(transactions)
+	if err != nil {
+		return Digest{}, err
+	}
+	previousFlows, err := digestFlows(previous)
+	if err != nil {
+		return Digest{}, err
+	}
+	digest := Digest{
+		Kind:     kind,
+		Start:    start,
+		End:      kind.nextPeriod(start).AddDate(0, 0, -1),
+		Flows:    flows,
+		Previous: previousFlows,
+		Closing:  closing,
+	}
+
+	totals := make(map[string]*PartnerTotal)
+	var partners []*PartnerTotal
+	var active []StoredTransaction
+	for _, t := range transactions {
+		if t.RemovedAt != nil {
+			continue
+		}
+		active = append(active, t)
+
+		key := normalizePartnerName(t.PartnerName)
+		total, seen := totals[key]
+		if !seen {
+			total = &PartnerTotal{PartnerName: t.PartnerName}
+			totals[key] = total
+			partners = append(partners, total)
+		}
+		if total.Spent, err = total.Spent.Add(t.Amount.Neg()); err != nil {
+			return Digest{}, err
+		}
+		total.Count++
+	}
+
+	// The sums above checked that every amount is in the same currency
+	slices.SortStableFunc(partners, func(a, b *PartnerTotal) int { return cmp.Compare(b.Spent.Minor, a.Spent.Minor) })
+	for _, total := range partners {
+		if total.Spent.Minor <= 0 || len(digest.TopPartners) == digestTopCount {
+			break
+		}
+		digest.TopPartners = append(digest.TopPartners, *total)
+	}
+
+	slices.SortStableFunc(active, func(a, b StoredTransaction) int {
+		return cmp.Compare(b.Amount.Abs().Minor, a.Amount.Abs().Minor)
+	})
+	digest.Biggest = active[:min(len(active), digestTopCount)]
+	return digest, nil
+}
+
+// digestTitle names the digest and its period, e.g. "📊 N26 Monthly Digest: October 2025"
+func digestTitle(d Digest) string {
+	if d.Kind == DigestWeekly {
+		return fmt.Sprintf("📊 N26 Weekly Digest: %s – %s", d.Start.Format(statementDateLayout), d.End.Format(statementDateLayout))
+	}
+	return "📊 N26 Monthly Digest: " + d.Start.Format("January 2006")
+}
+
+// digestLines formats a digest, one line per fact
+func digestLines(d Digest) []string {
+	lines := []string{
+		fmt.Sprintf("**In** `%s` · **Out** `%s` · **Net** `%s` (%d transactions)",
+			d.Flows.In, d.Flows.Out, d.Flows.Net, d.Flows.Count),
+		fmt.Sprintf("**Previous %s**: in `%s` (this %s: %s), out `%s` (this %s: %s), net `%s`", d.Kind.unit(),
+			d.Previous.In, d.Kind.unit(), percentChange(d.Previous.In, d.Flows.In),
+			d.Previous.Out, d.Kind.unit(), percentChange(d.Previous.Out.Neg(), d.Flows.Out.Neg()), d.Previous.Net),
+	}
+
+	if len(d.TopPartners) > 0 {
+		lines = append(lines, "**Top partners**:")
+		for i, p := range d.TopPartners {
+			lines = append(lines, fmt.Sprintf("%d. %s `%s` (%d×)", i+1, p.PartnerName, p.Spent.Neg(), p.Count))
+		}
+	}
+	if len(d.Biggest) > 0 {
+		lines = append(lines, "**Biggest transactions**:")
+		for _, t := range d.Biggest {
+			lines = append(lines, fmt.Sprintf("%s | %s | `%s`", formatStatementDate(t.BookingDate), t.PartnerName, t.Amount))
+		}
+	}
+
+	if d.Closing != nil {
+		lines = append(lines, fmt.Sprintf("**Closing balance** `%s` (recorded %s)", d.Closing.Balance, d.Closing.RecordedAt.Format(statementDateLayout)))
+	} else {
+		lines = append(lines, "**Closing balance** N/A")
+	}
+	return lines
+}
+
+// percentChange describes how current compares with previous, e.g. "+16%". Compare outflows
+// as positive amounts, so spending more shows as an increase.
+func percentChange(previous, current Money) string {
+	if previous.IsZero() {
+		if current.IsZero() {
+			return "±0%"
+		}
+		return "new"
+	}
+	return fmt.Sprintf("%+.0f%%", float64(current.Minor-previous.Minor)/math.Abs(float64(previous.Minor))*100)
+}
+
+// loadDigest builds the digest of a period from the stored history and balance snapshots
+func loadDigest(storage *Storage, account string, kind DigestKind, start time.Time) (Digest, error) {
+	previousStart := kind.previousPeriod(start)
+	transactions, err := listSpending(storage, previousStart, kind.nextPeriod(start).AddDate(0, 0, -1))
+	if err != nil {
+		return Digest{}, err
+	}
+	var current, previous []StoredTransaction
+	for _, t := range transactions {
+		if t.BookingDate.Before(start) {
+			previous = append(previous, t)
+		} else {
+			current = append(current, t)
+		}
+	}
+
+	// Snapshots carry a time of day, include the whole last day
+	snapshots, err := storage.Balances.ListSnapshots(account, time.Time{}, kind.nextPeriod(start).Add(-time.Nanosecond))
+	if err != nil {
+		return Digest{}, err
+	}
+	var closing *BalanceSnapshot
+	if len(snapshots) > 0 {
+		closing = &snapshots[len(snapshots)-1]
+	}
+	return buildDigest(kind, start, current, previous, closing)
+}
+
+// checkDigests sends every channel the weekly and monthly digests of the completed periods it
+// has not received. Periods are sent oldest first; a failed channel stops at the failed period
+// and resumes from it on the next run, so every period is sent to every channel once.
+func checkDigests(config AccountConfig, storage *Storage, notifiers []Notifier, account string, now time.Time) error {
+	var failed []string
+	for _, kind := range loadDigestKinds(config) {
+		digests := make(map[time.Time]Digest)
+		for _, notifier := range notifiers {
+			channel := notifier.Channel()
+			lastSent, sent, err := storage.Digests.LastSentDigest(kind, channel)
+			if err != nil {
+				return err
+			}
+
+			for _, start := range pendingDigestPeriods(kind, lastSent, sent, now) {
+				digest, ok := digests[start]
+				if !ok {
+					if digest, err = loadDigest(storage, account, kind, start); err != nil {
+						return err
+					}
+					digests[start] = digest
+				}
+
+				if err := notifier.SendAlert(Alert{Kind: AlertDigest, Title: digestTitle(digest), Lines: digestLines(digest)}); err != nil {
+					log.Printf("Warning: Failed to send %s digest to %s: %v", kind, channel, err)
+					failed = append(failed, channel)
+					break
+				}
+				fmt.Printf("Sent %s digest of %s to %s\n", kind, start.Format("2006-01-02"), channel)
+				if err := storage.Digests.MarkDigestSent(kind, channel, digest.Start, digest.End); err != nil {
+					// Not fatal, but the digest will be sent to this channel again
+					log.Printf("Warning: Failed to record %s digest: %v", channel, err)
+				}
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("digest delivery to %s failed, it will be retried on the next run", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigestPeriods(t *testing.T) {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2025, month, d, 0, 0, 0, 0, statementLocation())
+	}
+	// Wednesday 22 October 2025
+	now := time.Date(2025, 10, 22, 9, 30, 0, 0, time.UTC)
+
+	if start := DigestWeekly.periodStart(now); !start.Equal(day(10, 20)) {
+		t.Errorf("weekly periodStart() = %v, want Monday 2025-10-20", start)
+	}
+	if start := DigestWeekly.periodStart(day(10, 26)); !start.Equal(day(10, 20)) {
+		t.Errorf("weekly periodStart(Sunday) = %v, want Monday 2025-10-20", start)
+	}
+
+	// Nothing sent yet: only the latest completed period
+	starts := pendingDigestPeriods(DigestWeekly, time.Time{}, false, now)
+	if len(starts) != 1 || !starts[0].Equal(day(10, 13)) {
+		t.Errorf("pendingDigestPeriods(weekly, none sent) = %v, want [2025-10-13]", starts)
+	}
+	// Runs stopped for three weeks: every missed week, oldest first
+	starts = pendingDigestPeriods(DigestWeekly, day(9, 22), true, now)
+	if len(starts) != 3 || !starts[0].Equal(day(9, 29)) || !starts[2].Equal(day(10, 13)) {
+		t.Errorf("pendingDigestPeriods(weekly, sent 2025-09-22) = %v, want 2025-09-29 to 2025-10-13", starts)
+	}
+	// Up to date
+	if starts := pendingDigestPeriods(DigestMonthly, day(9, 1), true, now); len(starts) != 0 {
+		t.Errorf("pendingDigestPeriods(monthly, sent September) = %v, want none", starts)
+	}
+	// Long gaps only catch up on the latest periods
+	starts = pendingDigestPeriods(DigestMonthly, time.Date(2024, 1, 1, 0, 0, 0, 0, statementLocation()), true, now)
+	if len(starts) != digestMaxCatchUp || !starts[len(starts)-1].Equal(day(9, 1)) {
+		t.Errorf("pendingDigestPeriods(monthly, sent January 2024) = %v, want the last %d months up to September", starts, digestMaxCatchUp)
+	}
+}
+
+func TestBuildDigest(t *testing.T) {
+	current := chargeHistory("Supermarket", []string{"13.10.2025", "15.10.2025"}, []string{"-40,00", "-25,50"})
+	current = append(current, chargeHistory("Employer", []string{"14.10.2025"}, []string{"2000,00"})...)
+	current = append(current, chargeHistory("Landlord", []string{"15.10.2025"}, []string{"-900,00"})...)
+	current = append(current, chargeHistory("Cafe", []string{"16.10.2025"}, []string{"-3,20"})...)
+	removed := time.Now()
+	current = append(current, StoredTransaction{Transaction: Transaction{BookingDate: statementDay("17.10.2025"), PartnerName: "Ghost", Amount: eur("-5000,00")}, RemovedAt: &removed})
+	previous := chargeHistory("Supermarket", []string{"08.10.2025"}, []string{"-100,00"})
+
+	start := time.Date(2025, 10, 13, 0, 0, 0, 0, time.UTC)
+	closing := &BalanceSnapshot{RecordedAt: time.Date(2025, 10, 19, 8, 0, 0, 0, time.UTC), Balance: eur("1.531,30")}
+	digest, err := buildDigest(DigestWeekly, start, current, previous, closing)
+	if err != nil {
+		t.Fatalf("buildDigest() failed: %v", err)
+	}
+
+	if digest.Flows.In != eur("2000,00") || digest.Flows.Out != eur("-968,70") || digest.Flows.Count != 5 {
+		t.Errorf("Flows = %+v, want in 2000, out -968.70 over 5 transactions", digest.Flows)
+	}
+	if !digest.End.Equal(time.Date(2025, 10, 19, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("End = %v, want Sunday 2025-10-19", digest.End)
+	}
+	if len(digest.TopPartners) != 3 || digest.TopPartners[0].PartnerName != "Landlord" || digest.TopPartners[1].Spent != eur("65,50") || digest.TopPartners[1].Count != 2 {
+		t.Errorf("TopPartners = %+v, want Landlord, then Supermarket with 65.50 over 2", digest.TopPartners)
+	}
+	if len(digest.Biggest) != 3 || digest.Biggest[0].PartnerName != "Employer" || digest.Biggest[1].PartnerName != "Landlord" {
+		t.Errorf("Biggest = %+v, want Employer then Landlord", digest.Biggest)
+	}
+
+	text := strings.Join(digestLines(digest), "\n")
+	for _, want := range []string{"**Net** `1.031,30 EUR`", "out `-100,00 EUR` (this week: +869%)", "in `0,00 EUR` (this week: new)", "1. Landlord `-900,00 EUR` (1×)", "**Closing balance** `1.531,30 EUR`"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("digestLines() missing %q in:\n%s", want, text)
+		}
+	}
+	if title := digestTitle(digest); title != "📊 N26 Weekly Digest: 13.10.2025 – 19.10.2025" {
+		t.Errorf("digestTitle() = %q", title)
+	}
+}
+
+func TestCheckDigestsSendsEachPeriodOnce(t *testing.T) {
+	t.Setenv("DIGESTS", "weekly")
+	storage, err := OpenStorage("memory://")
+	if err != nil {
+		t.Fatalf("OpenStorage() failed: %v", err)
+	}
+
+	discord := &fakeNotifier{channel: "discord"}
+	email := &fakeNotifier{channel: "email", err: errors.New("smtp down")}
+	notifiers := []Notifier{discord, email}
+	wednesday := time.Date(2025, 10, 22, 9, 0, 0, 0, time.UTC)
+
+	if err := checkDigests(globalConfig, storage, notifiers, "", wednesday); err == nil {
+		t.Error("checkDigests() with a failing channel succeeded, want an error")
+	}
+	if err := checkDigests(globalConfig, storage, []Notifier{discord}, "", wednesday.Add(time.Hour)); err != nil {
+		t.Fatalf("checkDigests() failed: %v", err)
+	}
+	if len(discord.alerts) != 1 || discord.alerts[0].Kind != AlertDigest || !strings.Contains(discord.alerts[0].Title, "13.10.2025") {
+		t.Fatalf("discord alerts = %+v, want the digest of the week of 13.10.2025 once", discord.alerts)
+	}
+
+	// Two weeks later both channels catch up, email from the week it missed
+	email.err = nil
+	if err := checkDigests(globalConfig, storage, notifiers, "", wednesday.AddDate(0, 0, 14)); err != nil {
+		t.Fatalf("checkDigests() failed: %v", err)
+	}
+	if len(discord.alerts) != 3 || !strings.Contains(discord.alerts[2].Title, "27.10.2025") {
+		t.Errorf("discord got %d digests, want 3 ending with the week of 27.10.2025", len(discord.alerts))
+	}
+	if len(email.alerts) != 1 || !strings.Contains(email.alerts[0].Title, "27.10.2025") {
+		t.Errorf("email got %+v, want only the latest week as it never received one", email.alerts)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// spaceURLPattern finds the account or space ID in the web app URLs that carry one, such as
+// app.n26.com/spaces/details/{id} and the statement download of an account
+var spaceURLPattern = regexp.MustCompile(`/(?:spaces/details|account-activity/period)/([A-Za-z0-9-]+)`)
+
+// pageLink is a link on a web app page, with the text it shows
+type pageLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+// resolveAccountID returns the N26 account ID of the main account: N26_ACCOUNT_ID when set,
+// otherwise the one discovered after an earlier login. Empty when neither is known.
+func resolveAccountID(config AccountConfig, storage *Storage) (string, error) {
+	if accountID := config.Getenv("N26_ACCOUNT_ID"); accountID != "" {
+		return accountID, nil
+	}
+	spaces, err := storage.Spaces.ListSpaces()
+	if err != nil {
+		return "", err
+	}
+	for _, space := range spaces {
+		if space.Primary {
+			return space.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// discoverAccountID lists the spaces with a stored cookie and returns the ID of the main account.
+// The spaces are stored, so later runs find the ID without asking N26.
+func discoverAccountID(cookieHeader string, storage *Storage) (string, error) {
+	spaces, err := fetchSpaces(cookieHeader)
+	if err != nil {
+		return "", err
+	}
+	main := primarySpace(spaces, "")
+	if main.ID == "" {
+		return "", fmt.Errorf("none of the %d spaces is the main account", len(spaces))
+	}
+	if err := storage.Spaces.ReplaceSpaces(spaces); err != nil {
+		log.Printf("Warning: Failed to store spaces: %v", err)
+	}
+	fmt.Printf("Discovered account ID %s\n", main.ID)
+	return main.ID, nil
+}
+
+// recordDiscoveredSpaces stores the account and space IDs after a login. The spaces API is asked
+// first, the IDs found in the web app while logging in are the fallback.
+func recordDiscoveredSpaces(cookieHeader string, pageSpaces []Space, storage *Storage) {
+	spaces, err := fetchSpaces(cookieHeader)
+	if err != nil {
+		log.Printf("Warning: Failed to list spaces, using the IDs found in the web app: %v", err)
+		spaces = pageSpaces
+	}
+	if len(spaces) == 0 {
+		log.Println("Warning: No account ID found after login, set N26_ACCOUNT_ID")
+		return
+	}
+	if err := storage.Spaces.ReplaceSpaces(spaces); err != nil {
+		log.Printf("Warning: Failed to store spaces: %v", err)
+		return
+	}
+	fmt.Printf("Discovered account %s with %d spaces (see 'accounts list')\n", primarySpace(spaces, "").ID, len(spaces))
+}
+
+// discoverSpacesInBrowser opens the spaces overview of the logged-in web app and collects the
+// account and space IDs from its links and from the URLs of the requests it makes
+func discoverSpacesInBrowser(ctx context.Context) []Space {
+	var mu sync.Mutex
+	var urls []string
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		if ev, ok := ev.(*network.EventRequestWillBeSent); ok {
+			mu.Lock()
+			urls = append(urls, ev.Request.URL)
+			mu.Unlock()
+		}
+	})
+
+	var links []pageLink
+	var currentURL string
+	err := chromedp.Run(ctx,
+		chromedp.Navigate("https://app.n26.com/spaces"),
+		chromedp.WaitVisible("body", chromedp.ByQuery),
+		waitForNetworkIdle(ctx, 0),
+		chromedp.Location(&currentURL),
+		chromedp.Evaluate(`Array.from(document.querySelectorAll('a[href*="/spaces/details/"]')).map(a => ({href: a.href, text: a.innerText.trim()}))`, &links),
+	)
+	if err != nil {
+		log.Printf("Warning: Failed to open the spaces overview: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return spacesFromPage(links, append(urls, currentURL))
+}
+
+// spacesFromPage builds the spaces from the links of the spaces overview, in page order, and adds
+// the IDs only seen in request URLs. The overview lists the main account first.
+func spacesFromPage(links []pageLink, urls []string) []Space {
+	var spaces []Space
+	seen := make(map[string]bool)
+	add := func(url, name string) {
+		match := spaceURLPattern.FindStringSubmatch(url)
+		if match == nil || seen[match[1]] {
+			return
+		}
+		seen[match[1]] = true
+		// Link texts also show the balance, the name is the first line
+		name, _, _ = strings.Cut(strings.TrimSpace(name), "\n")
+		spaces = append(spaces, Space{ID: match[1], Name: strings.TrimSpace(name), Primary: len(spaces) == 0})
+	}
+
+	for _, link := range links {
+		add(link.Href, link.Text)
+	}
+	for _, url := range urls {
+		add(url, "")
+	}
+	return spaces
+}
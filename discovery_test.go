@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSpacesFromPage(t *testing.T) {
+	links := []pageLink{
+		{Href: "https://app.n26.com/spaces/details/main-id", Text: "Main Account\n1.234,56 €"},
+		{Href: "https://app.n26.com/spaces/details/savings-id", Text: " Savings "},
+		{Href: "https://app.n26.com/spaces/details/main-id", Text: "Main Account"},
+		{Href: "https://app.n26.com/feed", Text: "Home"},
+	}
+	urls := []string{
+		"https://app.n26.com/account-activity/period/savings-id?format=pdf",
+		"https://app.n26.com/spaces/details/travel-id",
+		"https://app.n26.com/api/me",
+	}
+
+	want := []Space{
+		{ID: "main-id", Name: "Main Account", Primary: true},
+		{ID: "savings-id", Name: "Savings"},
+		{ID: "travel-id"},
+	}
+	if got := spacesFromPage(links, urls); !slices.Equal(got, want) {
+		t.Errorf("spacesFromPage() = %+v, want %+v", got, want)
+	}
+	if got := spacesFromPage(nil, []string{"https://app.n26.com/login"}); len(got) != 0 {
+		t.Errorf("spacesFromPage(login page) = %+v, want none", got)
+	}
+}
+
+func TestResolveAccountIDPrefersOverride(t *testing.T) {
+	storage, err := OpenStorage("memory://")
+	if err != nil {
+		t.Fatalf("failed to open memory storage: %v", err)
+	}
+
+	t.Setenv("N26_ACCOUNT_ID", "")
+	if id, err := resolveAccountID(globalConfig, storage); err != nil || id != "" {
+		t.Errorf("resolveAccountID() before discovery = %q, %v, want empty", id, err)
+	}
+
+	spaces := []Space{{ID: "savings-id", Name: "Savings"}, {ID: "main-id", Name: "Main Account", Primary: true}}
+	if err := storage.Spaces.ReplaceSpaces(spaces); err != nil {
+		t.Fatalf("ReplaceSpaces() failed: %v", err)
+	}
+	if id, err := resolveAccountID(globalConfig, storage); err != nil || id != "main-id" {
+		t.Errorf("resolveAccountID() = %q, %v, want the discovered main account", id, err)
+	}
+
+	t.Setenv("N26_ACCOUNT_ID", "override-id")
+	if id, err := resolveAccountID(globalConfig, storage); err != nil || id != "override-id" {
+		t.Errorf("resolveAccountID() = %q, %v, want the N26_ACCOUNT_ID override", id, err)
+	}
+}
+
+func TestListAccounts(t *testing.T) {
+	storage, err := OpenStorage("memory://")
+	if err != nil {
+		t.Fatalf("failed to open memory storage: %v", err)
+	}
+	alice, err := storage.ForAccount("alice")
+	if err != nil {
+		t.Fatalf("ForAccount(alice) error: %v", err)
+	}
+	if err := alice.Spaces.ReplaceSpaces([]Space{{ID: "main-id", Name: "Main Account", Primary: true}, {ID: "savings-id", Name: "Savings"}}); err != nil {
+		t.Fatalf("ReplaceSpaces() failed: %v", err)
+	}
+	t.Setenv("N26_ACCOUNT_ID", "")
+	t.Setenv("ACCOUNT_CAROL_N26_ACCOUNT_ID", "carol-id")
+
+	var out bytes.Buffer
+	if err := listAccounts(&out, storage, []string{"alice", "bob", "carol"}); err != nil {
+		t.Fatalf("listAccounts() error: %v", err)
+	}
+	for _, want := range []string{"main-id", "savings-id", "Savings", "bob", "not discovered yet", "carol-id", "N26_ACCOUNT_ID"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("listAccounts() output misses %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -109,38 +110,94 @@ func main() {
 		log.Println("No .env file found, using environment variables")
 	}
 
-	// Get credentials
-	email := os.Getenv("N26_EMAIL")
-	password := os.Getenv("N26_PASSWORD")
-
-	if email == "" || password == "" {
-		log.Fatal("N26_EMAIL and N26_PASSWORD must be set in environment variables or .env file")
+	// Run a subcommand instead of the scraper when one is given
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	// Initialize repositories - PostgreSQL is required
-	dbConn := os.Getenv("DB_CONN")
-	if dbConn == "" {
-		log.Fatal("DB_CONN environment variable is required. Please set it with your PostgreSQL connection string.")
+	// Initialize storage - the backend is selected from the DB_CONN scheme and shared by every account
+	dbConn, err := loadSecretString(globalConfig, "DB_CONN")
+	if err != nil {
+		log.Fatalf("DB_CONN is required. Please set it with your PostgreSQL (postgres://) or SQLite (sqlite://) connection string: %v", err)
+	}
+	accounts, err := loadAccountNames()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	// Initialize PostgreSQL cookie repository
-	cookieRepo, err := NewPostgresCookieRepository(dbConn)
+	storage, err := OpenStorage(dbConn)
 	if err != nil {
-		log.Fatalf("Failed to initialize PostgreSQL cookie repository: %v", err)
+		log.Fatalf("Failed to initialize storage: %v", err)
 	}
-	defer func() {
-		if err := cookieRepo.Close(); err != nil {
-			log.Printf("Warning: Failed to close cookie repository: %v", err)
+	fmt.Printf("Using %s storage for cookies and statements\n", storage.Name())
+
+	// Purge cookies and stored data that fall outside the retention policy
+	now := time.Now()
+	if _, err := purgeExpiredData(storage.Retention, loadRetentionPolicy().rules(now, time.Time{}), purgeSourceRun, false, now); err != nil {
+		log.Printf("Warning: Failed to apply retention policy: %v", err)
+	}
+
+	// Every account runs with its own settings and storage; a failed account does not stop the others
+	var failed []string
+	for _, account := range accounts {
+		if len(accounts) > 1 {
+			fmt.Printf("\n=== Account %s ===\n", account)
 		}
-	}()
-	fmt.Println("Using PostgreSQL storage for cookies")
+		accountStorage, err := storage.ForAccount(account)
+		if err == nil {
+			err = runAccount(loadAccountConfig(account), accountStorage)
+		}
+		if err != nil {
+			log.Printf("Account %s failed: %v", account, err)
+			failed = append(failed, account)
+		}
+	}
 
-	// Initialize PostgreSQL statement repository (reuse the same DB connection)
-	statementRepo, err := NewPostgresStatementRepository(cookieRepo.db)
+	if err := storage.Close(); err != nil {
+		log.Printf("Warning: Failed to close storage: %v", err)
+	}
+	if len(failed) > 0 {
+		log.Fatalf("%d of %d accounts failed: %s", len(failed), len(accounts), strings.Join(failed, ", "))
+	}
+}
+
+// runAccount downloads and processes the statement of one account with its settings, logging in
+// first when its stored cookie is missing or expired
+func runAccount(config AccountConfig, storage *Storage) error {
+	// Get credentials - each can come from NAME, NAME_FILE or NAME_COMMAND.
+	// The password is only loaded when a login is actually needed.
+	email, err := loadSecretString(config, "N26_EMAIL")
+	if err != nil {
+		return fmt.Errorf("N26_EMAIL and N26_PASSWORD must be set in environment variables or .env file: %w", err)
+	}
+	if !secretConfigured(config, "N26_PASSWORD") {
+		return fmt.Errorf("N26_EMAIL and N26_PASSWORD must be set in environment variables or .env file (or via N26_PASSWORD_FILE / N26_PASSWORD_COMMAND)")
+	}
+	cookieRepo := storage.Cookies
+
+	// Hold a per-account lock for the whole run so overlapping runs don't both log in or notify
+	lockAccount := config.Getenv("N26_ACCOUNT_ID")
+	if lockAccount == "" {
+		lockAccount = email
+	}
+	lockMode, lockTimeout := loadRunLockConfig(config)
+	runLock, err := AcquireRunLock(context.Background(), storage, lockAccount, lockMode, lockTimeout)
 	if err != nil {
-		log.Fatalf("Failed to initialize PostgreSQL statement repository: %v", err)
+		// Only skip mode skips, a wait that timed out fails the run
+		if errors.Is(err, ErrRunLockHeld) {
+			fmt.Println("Another run is already in progress for this account. Skipping it.")
+			return nil
+		}
+		return fmt.Errorf("failed to acquire run lock: %w", err)
 	}
-	fmt.Println("Using PostgreSQL storage for statements")
+	defer func() {
+		if err := runLock.Release(); err != nil {
+			log.Printf("Warning: Failed to release run lock: %v", err)
+		}
+	}()
 
 	// Try to read cookie from repository
 	cookieHeader, err := cookieRepo.Get()
@@ -149,59 +206,102 @@ func main() {
 		log.Println("Will perform login to get new cookie...")
 	}
 
+	// N26_ACCOUNT_ID overrides the account ID discovered after an earlier login
+	accountID, err := resolveAccountID(config, storage)
+	if err != nil {
+		log.Printf("Warning: Failed to read the discovered account ID: %v", err)
+	}
+	if cookieHeader != "" && accountID == "" {
+		fmt.Println("No account ID known yet, discovering it with the stored cookie...")
+		if accountID, err = discoverAccountID(cookieHeader, storage); err != nil {
+			if !isUnauthorizedError(err) {
+				return fmt.Errorf("N26_ACCOUNT_ID is not set and the account could not be discovered: %w", err)
+			}
+			log.Println("Cookie expired or invalid. Performing login...")
+			if err := cookieRepo.MarkInvalid(); err != nil {
+				log.Printf("Warning: Failed to record cookie invalidation: %v", err)
+			}
+			cookieHeader = ""
+		}
+	}
+
 	// Try to call endpoint with cookie
 	if cookieHeader != "" {
 		fmt.Println("Attempting to call endpoint with stored cookie...")
-		pdfData, err := callEndpointWithCookie(cookieHeader)
+		period := lastDaysPeriod(30, time.Now())
+		pdfData, err := callEndpointWithCookie(cookieHeader, accountID, period)
 		if err != nil {
-			if isUnauthorizedError(err) {
-				log.Println("Cookie expired or invalid. Performing login...")
-				cookieHeader = ""
-			} else {
-				log.Fatalf("Failed to call endpoint: %v", err)
+			if !isUnauthorizedError(err) {
+				return fmt.Errorf("failed to call endpoint: %w", err)
 			}
+			log.Println("Cookie expired or invalid. Performing login...")
+			if err := cookieRepo.MarkInvalid(); err != nil {
+				log.Printf("Warning: Failed to record cookie invalidation: %v", err)
+			}
+			cookieHeader = ""
 		} else {
 			fmt.Println("Successfully called endpoint with stored cookie")
-
-			// Send Discord notification
-			if err := sendDiscordNotification(pdfData, statementRepo); err != nil {
-				log.Printf("Warning: Failed to send Discord notification: %v", err)
+			if err := cookieRepo.MarkValidated(); err != nil {
+				log.Printf("Warning: Failed to record cookie validation: %v", err)
 			}
 
-			return
+			// The main account comes first, then the statement of every other space
+			mainSpace, spaces := listSpaces(config, cookieHeader, accountID, storage)
+			if err := processStatement(config, pdfData, period, mainSpace, storage); err != nil {
+				log.Printf("Warning: Failed to process statement: %v", err)
+			}
+			processSpaces(config, cookieHeader, period, spaces, storage)
+			return nil
 		}
 	}
 
 	// If we get here, we need to login
-	if cookieHeader == "" {
-		fmt.Println("Performing login to get fresh cookie...")
-		newCookie, err := performLoginAndGetCookie(email, password)
-		if err != nil {
-			log.Fatalf("Login failed: %v", err)
-		}
-
-		// Save cookie to repository
-
-		if err := cookieRepo.Save(newCookie); err != nil {
-			log.Printf("Warning: Failed to save cookie to repository: %v", err)
-		} else {
-			fmt.Println("Cookie saved successfully")
-		}
+	fmt.Println("Performing login to get fresh cookie...")
+	password, err := loadSecret(config, "N26_PASSWORD")
+	if err != nil {
+		return fmt.Errorf("failed to load N26 password: %w", err)
+	}
+	newCookie, pageSpaces, err := performLoginAndGetCookie(storage.Account, email, password)
+	zeroSecret(password)
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
 
-		fmt.Println("Login completed and cookie saved. Exiting.")
+	// Save cookie to repository
+	if err := cookieRepo.Save(newCookie); err != nil {
+		log.Printf("Warning: Failed to save cookie to repository: %v", err)
+	} else {
+		fmt.Println("Cookie saved successfully")
 	}
+	recordDiscoveredSpaces(newCookie, pageSpaces, storage)
+
+	fmt.Println("Login completed and cookie saved. Exiting.")
+	return nil
 }
 
-// callEndpointWithCookie makes a GET request to the endpoint with the cookie header
+// callEndpointWithCookie makes a GET request to the endpoint of an account with the cookie header
 // Returns the PDF data on success
-func callEndpointWithCookie(cookieHeader string) ([]byte, error) {
-	endUnix := time.Now().Unix() * 1000
-	startUnix := time.Now().AddDate(0, 0, -30).Unix() * 1000
+func callEndpointWithCookie(cookieHeader, accountID string, period StatementPeriod) ([]byte, error) {
+	endUnix := period.To.Unix() * 1000
+	startUnix := period.From.Unix() * 1000
 
 	endpointWithUnix := strings.Replace(endpoint, "$END_UNIX", fmt.Sprintf("%d", endUnix), 1)
 	endpointWithUnix = strings.Replace(endpointWithUnix, "$START_UNIX", fmt.Sprintf("%d", startUnix), 1)
-	endpointWithAccountId := strings.Replace(endpointWithUnix, "$ACCOUNT_ID", os.Getenv("N26_ACCOUNT_ID"), 1)
-	req, err := http.NewRequest("GET", endpointWithAccountId, nil)
+	endpointWithAccountId := strings.Replace(endpointWithUnix, "$ACCOUNT_ID", accountID, 1)
+	body, err := fetchWithCookie(endpointWithAccountId, cookieHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Success - we have PDF data
+	fmt.Printf("Successfully retrieved PDF data (%d bytes)\n", len(body))
+	return body, nil
+}
+
+// fetchWithCookie makes a GET request to an N26 web app URL with the cookie header and returns
+// the response body. An expired session is reported as a 401 error, see isUnauthorizedError.
+func fetchWithCookie(url, cookieHeader string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -252,246 +352,9 @@ func callEndpointWithCookie(cookieHeader string) ([]byte, error) {
 		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
-	// Success - we have PDF data
-	fmt.Printf("Successfully retrieved PDF data (%d bytes)\n", len(body))
 	return body, nil
 }
 
-// DiscordWebhookPayload represents the JSON structure for Discord webhook
-type DiscordWebhookPayload struct {
-	Content string `json:"content,omitempty"`
-	Embeds  []struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
-		Color       int    `json:"color"` // 0x00FF00 for green (success)
-		Fields      []struct {
-			Name   string `json:"name"`
-			Value  string `json:"value"`
-			Inline bool   `json:"inline,omitempty"`
-		} `json:"fields,omitempty"`
-		Timestamp string `json:"timestamp,omitempty"`
-	} `json:"embeds,omitempty"`
-}
-
-// sendDiscordNotification sends a notification to Discord webhook when PDF is successfully downloaded
-// Only notifies about statements that haven't been notified before
-func sendDiscordNotification(pdfData []byte, statementRepo StatementRepository) error {
-	webhookURL := os.Getenv("WEBHOOK_URL")
-	if webhookURL == "" {
-		return fmt.Errorf("WEBHOOK_URL environment variable is not set")
-	}
-
-	// Parse PDF data
-	parser, err := NewPDFParserFromBytes(pdfData)
-	if err != nil {
-		return fmt.Errorf("failed to create PDF parser: %w", err)
-	}
-	defer parser.Close()
-
-	// Extract and log small pa
-	extractedText, err := parser.ExtractText()
-	if err != nil {
-		return fmt.Errorf("failed to extract PDF text: %w", err)
-	}
-	detectedLanguage := "en"
-	if strings.Contains(extractedText, "Actividad de la cuenta") {
-		detectedLanguage = "es"
-	}
-	log.Printf("Extracted PDF text (%d characters)\n", len(extractedText))
-	log.Printf("Detected language: %s", detectedLanguage)
-
-	transactions, err := parser.ParseTransactions()
-	if err != nil {
-		return fmt.Errorf("failed to parse PDF transactions: %w", err)
-	}
-
-	if len(transactions) == 0 {
-		return fmt.Errorf("PDF has no transaction data")
-	}
-
-	// Parse account balance
-	var accountBalance string
-	balance, err := parser.ParseBalance()
-	if err != nil {
-		log.Printf("Warning: Failed to parse account balance: %v", err)
-		accountBalance = "N/A"
-	} else {
-		accountBalance = balance.Balance
-		log.Printf("Account balance: %s EUR", accountBalance)
-	}
-
-	// Collect all statements and filter out already notified ones
-	type Statement struct {
-		Date    string
-		Partner string
-		Amount  string
-		Key     string
-	}
-
-	var newStatements []Statement
-
-	for _, tx := range transactions {
-		// Convert Transaction to Statement format
-		bookingDate := tx.BookingDate
-		partnerName := tx.PartnerName
-		amount := tx.Amount
-
-		key := generateStatementKey(bookingDate, partnerName, amount)
-
-		// Check if already notified
-		notified, err := statementRepo.IsNotified(key)
-		if err != nil {
-			log.Printf("Warning: Failed to check if statement is notified: %v", err)
-			// Assume not notified if we can't check
-			notified = false
-		}
-
-		if !notified {
-			newStatements = append(newStatements, Statement{
-				Date:    bookingDate,
-				Partner: partnerName,
-				Amount:  amount,
-				Key:     key,
-			})
-		}
-	}
-
-	// If no new statements, skip notification
-	if len(newStatements) == 0 {
-		fmt.Println("No new statements to notify. All statements have already been notified.")
-		return nil
-	}
-
-	fmt.Printf("Found %d new statements out of %d total statements\n", len(newStatements), len(transactions))
-
-	// Format transactions (limit to first 10 for Discord embed)
-	var transactionsText strings.Builder
-	maxTransactions := 10
-	if len(newStatements) < maxTransactions {
-		maxTransactions = len(newStatements)
-	}
-
-	for i := 0; i < maxTransactions; i++ {
-		stmt := newStatements[i]
-		// Format: Date | Partner Name | Amount
-		transactionsText.WriteString(fmt.Sprintf("**%s** | %s | `%s EUR`\n", stmt.Date, stmt.Partner, stmt.Amount))
-	}
-
-	if len(newStatements) > maxTransactions {
-		transactionsText.WriteString(fmt.Sprintf("\n_... and %d more new transactions_", len(newStatements)-maxTransactions))
-	}
-
-	// Create Discord embed
-	fields := []struct {
-		Name   string `json:"name"`
-		Value  string `json:"value"`
-		Inline bool   `json:"inline,omitempty"`
-	}{
-		{
-			Name:   "New Transactions",
-			Value:  fmt.Sprintf("%d", len(newStatements)),
-			Inline: true,
-		},
-		{
-			Name:   "Total Transactions",
-			Value:  fmt.Sprintf("%d", len(transactions)),
-			Inline: true,
-		},
-		{
-			Name:   "Account Balance",
-			Value:  fmt.Sprintf("%s EUR", accountBalance),
-			Inline: true,
-		},
-	}
-
-	fields = append(fields, struct {
-		Name   string `json:"name"`
-		Value  string `json:"value"`
-		Inline bool   `json:"inline,omitempty"`
-	}{
-		Name:   "Transactions",
-		Value:  transactionsText.String(),
-		Inline: false,
-	})
-
-	// Create content message for notification preview with all new transactions
-	var contentBuilder strings.Builder
-	for _, stmt := range newStatements {
-		contentBuilder.WriteString(fmt.Sprintf("**%s** | %s | `%s EUR`\n\n", stmt.Date, stmt.Partner, stmt.Amount))
-	}
-
-	contentMsg := strings.TrimSpace(contentBuilder.String())
-
-	payload := DiscordWebhookPayload{
-		Content: contentMsg,
-		Embeds: []struct {
-			Title       string `json:"title"`
-			Description string `json:"description"`
-			Color       int    `json:"color"`
-			Fields      []struct {
-				Name   string `json:"name"`
-				Value  string `json:"value"`
-				Inline bool   `json:"inline,omitempty"`
-			} `json:"fields,omitempty"`
-			Timestamp string `json:"timestamp,omitempty"`
-		}{
-			{
-				Title:       "✅ N26 PDF Movements",
-				Description: "",
-				Color:       0x00FF00, // Green color
-				Fields:      fields,
-				Timestamp:   time.Now().Format(time.RFC3339),
-			},
-		},
-	}
-
-	// Marshal JSON
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal Discord payload: %w", err)
-	}
-
-	// Send HTTP POST request
-	req, err := http.NewRequest("POST", webhookURL, strings.NewReader(string(jsonData)))
-	if err != nil {
-		return fmt.Errorf("failed to create Discord webhook request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send Discord webhook: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("discord webhook returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	fmt.Println("Discord notification sent successfully!")
-
-	// Mark all statements as notified after successful webhook
-	var notifiedKeys []string
-	for _, stmt := range newStatements {
-		notifiedKeys = append(notifiedKeys, stmt.Key)
-	}
-
-	if err := statementRepo.MarkMultipleAsNotified(notifiedKeys); err != nil {
-		log.Printf("Warning: Failed to mark statements as notified: %v", err)
-		// Don't return error, notification was sent successfully
-	} else {
-		fmt.Printf("Marked %d statements as notified\n", len(notifiedKeys))
-	}
-
-	return nil
-}
-
 // isUnauthorizedError checks if the error is a 401 unauthorized error
 func isUnauthorizedError(err error) bool {
 	if err == nil {
@@ -518,37 +381,47 @@ func isUnauthorizedError(err error) bool {
 	return false
 }
 
-// performLoginAndGetCookie performs login with 2FA and extracts the cookie
-func performLoginAndGetCookie(email, password string) (string, error) {
+// performLoginAndGetCookie performs login with 2FA and extracts the cookie, along with the
+// account and space IDs found in the web app
+func performLoginAndGetCookie(account, email string, password []byte) (string, []Space, error) {
 	// Setup Chrome context (headless)
-	ctx, cancel := setupChromeContext()
+	ctx, cancel := setupChromeContext(account)
 	defer cancel()
 
 	// Login to N26
 	if err := loginToN26(ctx, email, password); err != nil {
-		return "", fmt.Errorf("login failed: %w", err)
+		return "", nil, fmt.Errorf("login failed: %w", err)
 	}
 
 	// Wait a bit for session to be established
 	time.Sleep(2 * time.Second)
 
+	// Find the account ID while the web app is open, so N26_ACCOUNT_ID is optional
+	spaces := discoverSpacesInBrowser(ctx)
+
 	// Extract cookies
 	cookies, err := extractCookies(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to extract cookies: %w", err)
+		return "", nil, fmt.Errorf("failed to extract cookies: %w", err)
 	}
 
 	// Convert cookies to Cookie header format
 	cookieHeader := formatCookiesAsHeader(cookies)
 	if cookieHeader == "" {
-		return "", fmt.Errorf("no cookies found after login")
+		return "", nil, fmt.Errorf("no cookies found after login")
 	}
 
-	return cookieHeader, nil
+	return cookieHeader, spaces, nil
 }
 
-// setupChromeContext creates and configures the Chrome context (headless)
-func setupChromeContext() (context.Context, context.CancelFunc) {
+// setupChromeContext creates and configures the Chrome context (headless). Every account gets its
+// own browser profile, a shared one would still be logged in as the previous account.
+func setupChromeContext(account string) (context.Context, context.CancelFunc) {
+	profile := "chromedp-n26-cookie"
+	if account != defaultAccount {
+		profile += "-" + account
+	}
+
 	ctx, _ := chromedp.NewExecAllocator(
 		context.Background(),
 		chromedp.Flag("headless", true),
@@ -560,7 +433,7 @@ func setupChromeContext() (context.Context, context.CancelFunc) {
 		chromedp.Flag("disable-features", "TranslateUI"),
 		//chromedp.Flag("lang", "es-ES"),                 // Set browser language to Spanish (Spain)
 		//chromedp.Flag("accept-lang", "es-ES,es;q=0.9"), // Set Accept-Language header to Spanish
-		chromedp.UserDataDir(filepath.Join(os.TempDir(), "chromedp-n26-cookie")),
+		chromedp.UserDataDir(filepath.Join(os.TempDir(), profile)),
 		chromedp.ExecPath(""),
 	)
 
@@ -570,7 +443,7 @@ func setupChromeContext() (context.Context, context.CancelFunc) {
 }
 
 // loginToN26 handles the login process including 2FA
-func loginToN26(ctx context.Context, email, password string) error {
+func loginToN26(ctx context.Context, email string, password []byte) error {
 	fmt.Println("Opening N26 website...")
 	var currentURL string
 	err := chromedp.Run(ctx,
@@ -634,7 +507,7 @@ func loginToN26(ctx context.Context, email, password string) error {
 }
 
 // fillLoginForm fills in the email and password fields
-func fillLoginForm(ctx context.Context, email, password string) error {
+func fillLoginForm(ctx context.Context, email string, password []byte) error {
 	fmt.Println("Filling login form...")
 	err := chromedp.Run(ctx,
 		chromedp.WaitVisible("input[type='email'], input[name='email'], input[id='email'], input[placeholder*='email' i]", chromedp.ByQuery),
@@ -646,7 +519,7 @@ func fillLoginForm(ctx context.Context, email, password string) error {
 	}
 
 	err = chromedp.Run(ctx,
-		chromedp.SendKeys("input[type='password'], input[name='password'], input[id='password']", password, chromedp.ByQuery),
+		chromedp.SendKeys("input[type='password'], input[name='password'], input[id='password']", string(password), chromedp.ByQuery),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to fill password: %w", err)
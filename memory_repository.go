@@ -0,0 +1,794 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryCookieRepository implements CookieRepository in memory, for tests and dry runs
+type MemoryCookieRepository struct {
+	mu      sync.Mutex
+	nextID  int64
+	cookies []memoryCookie
+}
+
+// memoryCookie is a stored cookie together with its session lifecycle
+type memoryCookie struct {
+	session   CookieSession
+	value     string
+	updatedAt time.Time
+}
+
+// MemoryStatementRepository implements StatementRepository in memory, for tests and dry runs
+type MemoryStatementRepository struct {
+	mu         sync.Mutex
+	statements map[string]StatementRecord
+}
+
+// NewMemoryCookieRepository creates an empty in-memory cookie repository
+func NewMemoryCookieRepository() *MemoryCookieRepository {
+	return &MemoryCookieRepository{}
+}
+
+// NewMemoryStatementRepository creates an empty in-memory statement repository
+func NewMemoryStatementRepository() *MemoryStatementRepository {
+	return &MemoryStatementRepository{statements: make(map[string]StatementRecord)}
+}
+
+// latest returns the most recently saved cookie, or nil when there is none
+func (r *MemoryCookieRepository) latest() *memoryCookie {
+	if len(r.cookies) == 0 {
+		return nil
+	}
+	return &r.cookies[len(r.cookies)-1]
+}
+
+// Get retrieves the most recent cookie
+func (r *MemoryCookieRepository) Get() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cookie := r.latest()
+	if cookie == nil {
+		return "", fmt.Errorf("no cookie found in memory")
+	}
+
+	timestamp := cookie.updatedAt.UTC().Format(time.RFC3339)
+	return fmt.Sprintf("TIMESTAMP=%s; %s", timestamp, cookie.value), nil
+}
+
+// Save stores the cookie in memory
+func (r *MemoryCookieRepository) Save(cookie string) error {
+	cookie = normalizeCookie(cookie)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	now := time.Now()
+	r.cookies = append(r.cookies, memoryCookie{
+		session:   CookieSession{ID: r.nextID, CreatedAt: now},
+		value:     cookie,
+		updatedAt: now,
+	})
+	return nil
+}
+
+// MarkValidated records that the most recent cookie was accepted by the endpoint
+func (r *MemoryCookieRepository) MarkValidated() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cookie := r.latest(); cookie != nil {
+		now := time.Now()
+		if cookie.session.FirstUsedAt == nil {
+			cookie.session.FirstUsedAt = &now
+		}
+		cookie.session.LastValidatedAt = &now
+	}
+	return nil
+}
+
+// MarkInvalid records that the most recent cookie was rejected by the endpoint
+func (r *MemoryCookieRepository) MarkInvalid() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cookie := r.latest(); cookie != nil {
+		now := time.Now()
+		if cookie.session.FirstUsedAt == nil {
+			cookie.session.FirstUsedAt = &now
+		}
+		if cookie.session.InvalidatedAt == nil {
+			cookie.session.InvalidatedAt = &now
+		}
+	}
+	return nil
+}
+
+// Sessions returns the lifecycle of every stored cookie, oldest first
+func (r *MemoryCookieRepository) Sessions() ([]CookieSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sessions := make([]CookieSession, 0, len(r.cookies))
+	for _, cookie := range r.cookies {
+		sessions = append(sessions, cookie.session)
+	}
+	return sessions, nil
+}
+
+// IsNotified checks if a statement has already been notified
+func (r *MemoryStatementRepository) IsNotified(key string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, notified := r.statements[key]
+	return notified, nil
+}
+
+// FilterUnnotified returns the keys that have not been notified yet, in their original order
+func (r *MemoryStatementRepository) FilterUnnotified(keys []string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unnotified []string
+	for _, key := range keys {
+		if _, notified := r.statements[key]; !notified {
+			unnotified = append(unnotified, key)
+		}
+	}
+	return unnotified, nil
+}
+
+// MarkMultipleAsNotified marks multiple statements as notified
+func (r *MemoryStatementRepository) MarkMultipleAsNotified(records []StatementRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, record := range records {
+		if _, exists := r.statements[record.Key]; !exists {
+			r.statements[record.Key] = record
+		}
+	}
+	return nil
+}
+
+// FindOutdated returns notified statements of a space with the given booking date and amount
+// whose key was generated by a key version older than version
+func (r *MemoryStatementRepository) FindOutdated(space string, bookingDate time.Time, amount Money, version int) ([]StatementRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var records []StatementRecord
+	for _, record := range r.statements {
+		if record.Space != space || record.Amount.Minor != amount.Minor {
+			continue
+		}
+		if record.BookingDate.Equal(bookingDate) && record.KeyVersion < version {
+			records = append(records, record)
+		}
+	}
+	slices.SortFunc(records, func(a, b StatementRecord) int { return strings.Compare(a.Key, b.Key) })
+	return records, nil
+}
+
+// Rekey moves a notified statement to the key of the current key version
+func (r *MemoryStatementRepository) Rekey(oldKey string, record StatementRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.statements[oldKey]; !exists {
+		return nil
+	}
+	delete(r.statements, oldKey)
+	r.statements[record.Key] = record
+	return nil
+}
+
+// MemoryTransactionRepository implements TransactionRepository in memory, for tests and dry runs
+type MemoryTransactionRepository struct {
+	mu           sync.Mutex
+	documents    []StatementDocument
+	transactions map[string]StoredTransaction
+	order        []string // Keys in insertion order
+}
+
+// NewMemoryTransactionRepository creates an empty in-memory transaction repository
+func NewMemoryTransactionRepository() *MemoryTransactionRepository {
+	return &MemoryTransactionRepository{transactions: make(map[string]StoredTransaction)}
+}
+
+// SaveDocument stores the statement document, or refreshes it when the same PDF was seen before
+func (r *MemoryTransactionRepository) SaveDocument(doc StatementDocument) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.documents {
+		if r.documents[i].SHA256 == doc.SHA256 {
+			r.documents[i].FetchedAt = time.Now()
+			return r.documents[i].ID, nil
+		}
+	}
+
+	doc.ID = int64(len(r.documents) + 1)
+	doc.FetchedAt = time.Now()
+	r.documents = append(r.documents, doc)
+	return doc.ID, nil
+}
+
+// RecordTransactions upserts the transactions parsed from a document
+func (r *MemoryTransactionRepository) RecordTransactions(documentID int64, transactions []Transaction) error {
+	// Validate everything first so a bad transaction leaves the history untouched
+	for _, t := range transactions {
+		if t.BookingDate.IsZero() {
+			return fmt.Errorf("transaction %q has no booking date", t.PartnerName)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for i, key := range transactionKeys(transactions) {
+		t := transactions[i]
+		t.Amount.Currency = t.Amount.currency()
+		if t.ValueDate.IsZero() {
+			t.ValueDate = t.BookingDate
+		}
+
+		stored, exists := r.transactions[key]
+		if !exists {
+			stored = StoredTransaction{Key: key, FirstSeenAt: now}
+			r.order = append(r.order, key)
+		}
+		if t.Category == "" {
+			t.Category = stored.Category
+		}
+		stored.Transaction = t
+		stored.DocumentID = documentID
+		stored.LastSeenAt = now
+		stored.RemovedAt = nil
+		r.transactions[key] = stored
+	}
+	return nil
+}
+
+// ListTransactions returns the stored transactions booked between from and to (inclusive), oldest first
+func (r *MemoryTransactionRepository) ListTransactions(from, to time.Time) ([]StoredTransaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fromDay := from.Format("2006-01-02")
+	toDay := to.Format("2006-01-02")
+
+	var transactions []StoredTransaction
+	for _, key := range r.order {
+		stored := r.transactions[key]
+		if day := stored.BookingDate.Format("2006-01-02"); day < fromDay || day > toDay {
+			continue
+		}
+		transactions = append(transactions, stored)
+	}
+
+	slices.SortStableFunc(transactions, func(a, b StoredTransaction) int {
+		return a.BookingDate.Compare(b.BookingDate)
+	})
+	return transactions, nil
+}
+
+// RekeyTransaction moves a stored transaction to a new statement key, keeping its history
+func (r *MemoryTransactionRepository) RekeyTransaction(oldKey, newKey string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, exists := r.transactions[oldKey]
+	if !exists || oldKey == newKey {
+		return nil
+	}
+
+	delete(r.transactions, oldKey)
+	r.order = slices.DeleteFunc(r.order, func(key string) bool { return key == newKey })
+	r.order[slices.Index(r.order, oldKey)] = newKey
+	stored.Key = newKey
+	r.transactions[newKey] = stored
+	return nil
+}
+
+// MarkRemoved flags transactions that a later statement no longer contained
+func (r *MemoryTransactionRepository) MarkRemoved(keys []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, key := range keys {
+		if stored, exists := r.transactions[key]; exists && stored.RemovedAt == nil {
+			stored.RemovedAt = &now
+			r.transactions[key] = stored
+		}
+	}
+	return nil
+}
+
+// UpdateCategories sets the category of stored transactions by statement key
+func (r *MemoryTransactionRepository) UpdateCategories(categories map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, category := range categories {
+		if stored, exists := r.transactions[key]; exists {
+			stored.Category = category
+			r.transactions[key] = stored
+		}
+	}
+	return nil
+}
+
+// MemoryDeliveryRepository implements DeliveryRepository in memory, for tests and dry runs
+type MemoryDeliveryRepository struct {
+	mu         sync.Mutex
+	deliveries []memoryDelivery // In enqueue order
+}
+
+// memoryDelivery is a delivery together with the change it delivers
+type memoryDelivery struct {
+	Delivery
+	change TransactionChange
+}
+
+// NewMemoryDeliveryRepository creates an empty in-memory delivery repository
+func NewMemoryDeliveryRepository() *MemoryDeliveryRepository {
+	return &MemoryDeliveryRepository{}
+}
+
+// Enqueue adds a pending delivery of every change to every channel.
+// Deliveries that already exist keep their state.
+func (r *MemoryDeliveryRepository) Enqueue(channels []string, changes []TransactionChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, channel := range channels {
+		for _, change := range changes {
+			if r.find(channel, change.Kind, change.Record.Key) != nil {
+				continue
+			}
+			r.deliveries = append(r.deliveries, memoryDelivery{
+				Delivery: Delivery{Key: change.Record.Key, Channel: channel, Kind: change.Kind, Status: DeliveryPending, UpdatedAt: time.Now()},
+				change:   change,
+			})
+		}
+	}
+	return nil
+}
+
+// Pending returns the changes still to be delivered to a channel (pending or failed),
+// oldest booking first
+func (r *MemoryDeliveryRepository) Pending(channel string) ([]TransactionChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var changes []TransactionChange
+	for _, delivery := range r.deliveries {
+		if delivery.Channel == channel && delivery.Status != DeliverySent {
+			changes = append(changes, delivery.change)
+		}
+	}
+
+	slices.SortStableFunc(changes, func(a, b TransactionChange) int {
+		return a.Record.BookingDate.Compare(b.Record.BookingDate)
+	})
+	return changes, nil
+}
+
+// MarkSent records a successful delivery of one kind of change of the statements to a channel
+func (r *MemoryDeliveryRepository) MarkSent(channel string, kind ChangeKind, keys []string) error {
+	r.update(channel, kind, keys, DeliverySent, "")
+	return nil
+}
+
+// MarkFailed records a failed delivery attempt of one kind of change of the statements to a channel
+func (r *MemoryDeliveryRepository) MarkFailed(channel string, kind ChangeKind, keys []string, cause error) error {
+	r.update(channel, kind, keys, DeliveryFailed, cause.Error())
+	return nil
+}
+
+// update records a delivery attempt with the given outcome
+func (r *MemoryDeliveryRepository) update(channel string, kind ChangeKind, keys []string, status DeliveryStatus, lastError string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range keys {
+		if delivery := r.find(channel, kind, key); delivery != nil {
+			delivery.Status = status
+			delivery.Attempts++
+			delivery.LastError = lastError
+			delivery.UpdatedAt = time.Now()
+		}
+	}
+}
+
+// ListDeliveries returns every delivery with the given status (all of them when empty), oldest first
+func (r *MemoryDeliveryRepository) ListDeliveries(status DeliveryStatus) ([]Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deliveries []Delivery
+	for _, delivery := range r.deliveries {
+		if status == "" || delivery.Status == status {
+			deliveries = append(deliveries, delivery.Delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+// RekeyDeliveries moves the deliveries of a statement to its new key
+func (r *MemoryDeliveryRepository) RekeyDeliveries(oldKey, newKey string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if oldKey == newKey {
+		return nil
+	}
+
+	// Deliveries under the old key carry the state of what was already sent
+	type channelKind struct {
+		channel string
+		kind    ChangeKind
+	}
+	oldDeliveries := make(map[channelKind]bool)
+	for _, delivery := range r.deliveries {
+		if delivery.Key == oldKey {
+			oldDeliveries[channelKind{delivery.Channel, delivery.Kind}] = true
+		}
+	}
+	r.deliveries = slices.DeleteFunc(r.deliveries, func(delivery memoryDelivery) bool {
+		return delivery.Key == newKey && oldDeliveries[channelKind{delivery.Channel, delivery.Kind}]
+	})
+	for i := range r.deliveries {
+		if r.deliveries[i].Key == oldKey {
+			r.deliveries[i].Key = newKey
+			r.deliveries[i].change.Record.Key = newKey
+		}
+	}
+	return nil
+}
+
+// find returns the delivery of a change of a statement to a channel, nil when there is none.
+// The caller must hold the lock.
+func (r *MemoryDeliveryRepository) find(channel string, kind ChangeKind, key string) *memoryDelivery {
+	for i := range r.deliveries {
+		d := &r.deliveries[i]
+		if d.Channel == channel && d.Kind == kind && d.Key == key {
+			return d
+		}
+	}
+	return nil
+}
+
+// MemoryCategoryRuleRepository implements CategoryRuleRepository in memory, for tests and dry runs
+type MemoryCategoryRuleRepository struct {
+	mu     sync.Mutex
+	rules  []CategoryRule
+	nextID int64
+}
+
+// NewMemoryCategoryRuleRepository creates an empty in-memory category rule repository
+func NewMemoryCategoryRuleRepository() *MemoryCategoryRuleRepository {
+	return &MemoryCategoryRuleRepository{}
+}
+
+// ListRules returns the stored rules in evaluation order: by priority, then by ID
+func (r *MemoryCategoryRuleRepository) ListRules() ([]CategoryRule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rules := slices.Clone(r.rules)
+	slices.SortStableFunc(rules, func(a, b CategoryRule) int {
+		if a.Priority != b.Priority {
+			return a.Priority - b.Priority
+		}
+		return int(a.ID - b.ID)
+	})
+	return rules, nil
+}
+
+// AddRule validates and stores a rule, returning its ID
+func (r *MemoryCategoryRuleRepository) AddRule(rule CategoryRule) (int64, error) {
+	if err := rule.Validate(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	rule.ID = r.nextID
+	rule.Category = strings.TrimSpace(rule.Category)
+	rule.Keywords = slices.Clone(rule.Keywords)
+	r.rules = append(r.rules, rule)
+	return rule.ID, nil
+}
+
+// DeleteRule deletes a rule, failing when no rule has the ID
+func (r *MemoryCategoryRuleRepository) DeleteRule(id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	index := slices.IndexFunc(r.rules, func(rule CategoryRule) bool { return rule.ID == id })
+	if index < 0 {
+		return fmt.Errorf("category rule %d not found", id)
+	}
+	r.rules = slices.Delete(r.rules, index, index+1)
+	return nil
+}
+
+// MemoryBudgetRepository implements BudgetRepository in memory, for tests and dry runs
+type MemoryBudgetRepository struct {
+	mu      sync.Mutex
+	budgets map[string]Money
+}
+
+// NewMemoryBudgetRepository creates an empty in-memory budget repository
+func NewMemoryBudgetRepository() *MemoryBudgetRepository {
+	return &MemoryBudgetRepository{budgets: make(map[string]Money)}
+}
+
+// ListBudgets returns every budget ordered by category
+func (r *MemoryBudgetRepository) ListBudgets() ([]Budget, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var budgets []Budget
+	for category, limit := range r.budgets {
+		budgets = append(budgets, Budget{Category: category, MonthlyLimit: limit})
+	}
+	slices.SortFunc(budgets, func(a, b Budget) int { return strings.Compare(a.Category, b.Category) })
+	return budgets, nil
+}
+
+// SetBudget creates the budget of a category or replaces its limit
+func (r *MemoryBudgetRepository) SetBudget(budget Budget) error {
+	if err := budget.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.budgets[strings.TrimSpace(budget.Category)] = budget.MonthlyLimit
+	return nil
+}
+
+// DeleteBudget deletes the budget of a category, failing when there is none
+func (r *MemoryBudgetRepository) DeleteBudget(category string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.budgets[category]; !exists {
+		return fmt.Errorf("no budget for category %q", category)
+	}
+	delete(r.budgets, category)
+	return nil
+}
+
+// MemoryAlertRepository implements AlertRepository in memory, for tests and dry runs
+type MemoryAlertRepository struct {
+	mu   sync.Mutex
+	sent map[string]bool // channel + "\x00" + alert key
+}
+
+// NewMemoryAlertRepository creates an empty in-memory alert repository
+func NewMemoryAlertRepository() *MemoryAlertRepository {
+	return &MemoryAlertRepository{sent: make(map[string]bool)}
+}
+
+// FilterUnsent returns the alert keys not sent to the channel yet, in their original order
+func (r *MemoryAlertRepository) FilterUnsent(channel string, keys []string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unsent []string
+	for _, key := range keys {
+		if !r.sent[channel+"\x00"+key] {
+			unsent = append(unsent, key)
+		}
+	}
+	return unsent, nil
+}
+
+// MarkAlertsSent records that the alerts were sent to the channel
+func (r *MemoryAlertRepository) MarkAlertsSent(channel string, keys []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range keys {
+		r.sent[channel+"\x00"+key] = true
+	}
+	return nil
+}
+
+// MemorySubscriptionRepository implements SubscriptionRepository in memory, for tests and dry runs
+type MemorySubscriptionRepository struct {
+	mu            sync.Mutex
+	subscriptions []Subscription
+}
+
+// NewMemorySubscriptionRepository creates an empty in-memory subscription repository
+func NewMemorySubscriptionRepository() *MemorySubscriptionRepository {
+	return &MemorySubscriptionRepository{}
+}
+
+// ListSubscriptions returns the stored subscriptions, next charge first
+func (r *MemorySubscriptionRepository) ListSubscriptions() ([]Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	subscriptions := slices.Clone(r.subscriptions)
+	slices.SortStableFunc(subscriptions, func(a, b Subscription) int { return a.NextChargeDate.Compare(b.NextChargeDate) })
+	return subscriptions, nil
+}
+
+// ReplaceSubscriptions replaces the stored subscriptions with a freshly detected list
+func (r *MemorySubscriptionRepository) ReplaceSubscriptions(subscriptions []Subscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subscriptions = slices.Clone(subscriptions)
+	return nil
+}
+
+// MemorySpaceRepository implements SpaceRepository in memory, for tests and dry runs
+type MemorySpaceRepository struct {
+	mu     sync.Mutex
+	spaces []Space
+}
+
+// NewMemorySpaceRepository creates an empty in-memory space repository
+func NewMemorySpaceRepository() *MemorySpaceRepository {
+	return &MemorySpaceRepository{}
+}
+
+// ListSpaces returns the stored spaces, the main account first and the others by name
+func (r *MemorySpaceRepository) ListSpaces() ([]Space, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	spaces := slices.Clone(r.spaces)
+	slices.SortStableFunc(spaces, func(a, b Space) int {
+		if a.Primary != b.Primary {
+			if a.Primary {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(a.ID, b.ID))
+	})
+	return spaces, nil
+}
+
+// ReplaceSpaces replaces the stored spaces with a freshly listed set
+func (r *MemorySpaceRepository) ReplaceSpaces(spaces []Space) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.spaces = nil
+	for _, space := range spaces {
+		if i := slices.IndexFunc(r.spaces, func(s Space) bool { return s.ID == space.ID }); i >= 0 {
+			r.spaces[i] = space
+		} else {
+			r.spaces = append(r.spaces, space)
+		}
+	}
+	return nil
+}
+
+// MemoryBalanceRepository implements BalanceRepository in memory, for tests and dry runs
+type MemoryBalanceRepository struct {
+	mu        sync.Mutex
+	snapshots []BalanceSnapshot
+}
+
+// NewMemoryBalanceRepository creates an empty in-memory balance repository
+func NewMemoryBalanceRepository() *MemoryBalanceRepository {
+	return &MemoryBalanceRepository{}
+}
+
+// RecordSnapshot stores a balance snapshot and returns its ID
+func (r *MemoryBalanceRepository) RecordSnapshot(snapshot BalanceSnapshot) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot.ID = int64(len(r.snapshots) + 1)
+	snapshot.RecordedAt = snapshot.RecordedAt.UTC()
+	r.snapshots = append(r.snapshots, snapshot)
+	return snapshot.ID, nil
+}
+
+// ListSnapshots returns the snapshots of an account recorded between from and to (inclusive), oldest first
+func (r *MemoryBalanceRepository) ListSnapshots(account string, from, to time.Time) ([]BalanceSnapshot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var snapshots []BalanceSnapshot
+	for _, s := range r.snapshots {
+		if s.Account == account && !s.RecordedAt.Before(from) && !s.RecordedAt.After(to) {
+			snapshots = append(snapshots, s)
+		}
+	}
+	slices.SortStableFunc(snapshots, func(a, b BalanceSnapshot) int { return a.RecordedAt.Compare(b.RecordedAt) })
+	return snapshots, nil
+}
+
+// MemoryDigestRepository implements DigestRepository in memory, for tests and dry runs
+type MemoryDigestRepository struct {
+	mu   sync.Mutex
+	last map[string]time.Time // kind + "\x00" + channel -> latest period start sent
+}
+
+// NewMemoryDigestRepository creates an empty in-memory digest repository
+func NewMemoryDigestRepository() *MemoryDigestRepository {
+	return &MemoryDigestRepository{last: make(map[string]time.Time)}
+}
+
+// LastSentDigest returns the start of the latest period of the kind sent to the channel,
+// false when none was sent yet
+func (r *MemoryDigestRepository) LastSentDigest(kind DigestKind, channel string) (time.Time, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	start, ok := r.last[string(kind)+"\x00"+channel]
+	return start, ok, nil
+}
+
+// MarkDigestSent records that the digest of a period was sent to the channel
+func (r *MemoryDigestRepository) MarkDigestSent(kind DigestKind, channel string, start, end time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := string(kind) + "\x00" + channel
+	if last, ok := r.last[key]; !ok || start.After(last) {
+		r.last[key] = start
+	}
+	return nil
+}
+
+// MemoryRetentionRepository implements RetentionRepository in memory, for tests and dry runs.
+// Memory storage is gone when the process exits, so purges find nothing to remove.
+type MemoryRetentionRepository struct {
+	mu      sync.Mutex
+	entries []PurgeAuditEntry
+}
+
+// NewMemoryRetentionRepository creates an empty in-memory retention repository
+func NewMemoryRetentionRepository() *MemoryRetentionRepository {
+	return &MemoryRetentionRepository{}
+}
+
+// Purge reports nothing to delete or anonymize
+func (r *MemoryRetentionRepository) Purge(rule RetentionRule, dryRun bool) (int64, error) {
+	return 0, nil
+}
+
+// RecordPurge adds a purge to the audit trail
+func (r *MemoryRetentionRepository) RecordPurge(entry PurgeAuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry.ID = int64(len(r.entries) + 1)
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+// ListPurges returns the most recent purges first, at most limit of them
+func (r *MemoryRetentionRepository) ListPurges(limit int) ([]PurgeAuditEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := slices.Clone(r.entries)
+	slices.Reverse(entries)
+	return entries[:min(len(entries), limit)], nil
+}
@@ -2,37 +2,119 @@ package main
 
 import (
 	"database/sql"
+	"embed"
+	"errors"
 	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
 
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
-// runMigrations runs database migrations from the migrations directory
-func runMigrations(db *sql.DB) error {
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+// migrationFiles holds the SQL migrations of every backend, so the binary runs from any directory
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// newMigrate creates a migrator for the embedded migrations of the backend. It is never closed:
+// closing it would also close the database shared with the repositories.
+func newMigrate(db *sql.DB, backend string) (*migrate.Migrate, error) {
+	var driver database.Driver
+	var err error
+	switch backend {
+	case backendPostgres:
+		driver, err = postgres.WithInstance(db, &postgres.Config{})
+	case backendSQLite:
+		driver, err = sqlite.WithInstance(db, &sqlite.Config{})
+	default:
+		return nil, fmt.Errorf("no migrations for storage backend %q", backend)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration driver: %w", err)
+	}
+
+	source, err := iofs.New(migrationFiles, "migrations/"+backend)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embedded migrations: %w", err)
+	}
+	m, err := migrate.NewWithInstance("iofs", source, backend, driver)
 	if err != nil {
-		return fmt.Errorf("failed to create migration driver: %w", err)
+		return nil, fmt.Errorf("failed to create migrate instance: %w", err)
 	}
+	return m, nil
+}
 
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://migrations",
-		"postgres",
-		driver,
-	)
+// latestMigrationVersion returns the version of the newest embedded migration of the backend
+func latestMigrationVersion(backend string) (uint, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations/"+backend)
 	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
+		return 0, fmt.Errorf("failed to list embedded migrations: %w", err)
 	}
 
-	if err := m.Up(); err != nil {
-		if err == migrate.ErrNoChange {
-			// No new migrations to run, which is fine
-			return nil
+	var latest uint
+	for _, entry := range entries {
+		prefix, _, found := strings.Cut(entry.Name(), "_")
+		if !found {
+			continue
+		}
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			continue
+		}
+		if uint(version) > latest {
+			latest = uint(version)
 		}
-		return fmt.Errorf("failed to run migrations: %w", err)
 	}
+	return latest, nil
+}
 
-	return nil
+// schemaVersion returns the migration version of the database, 0 when it was never migrated
+func schemaVersion(m *migrate.Migrate) (uint, bool, error) {
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, dirty, nil
 }
 
+// prepareSchema makes sure the database schema is the one this binary was built for. An older
+// schema is migrated up when autoMigrate is set and refused otherwise; a newer or half-migrated
+// (dirty) schema is always refused, as running against it could corrupt data.
+func prepareSchema(db *sql.DB, backend string, autoMigrate bool) error {
+	m, err := newMigrate(db, backend)
+	if err != nil {
+		return err
+	}
+	latest, err := latestMigrationVersion(backend)
+	if err != nil {
+		return err
+	}
+	version, dirty, err := schemaVersion(m)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case dirty:
+		return fmt.Errorf("database schema is dirty at version %d after a failed migration, fix it and run 'migrate force VERSION'", version)
+	case version > latest:
+		return fmt.Errorf("database schema version %d is newer than this binary supports (%d), upgrade the binary", version, latest)
+	case version == latest:
+		return nil
+	case !autoMigrate:
+		return fmt.Errorf("database schema version %d is older than %d and AUTO_MIGRATE is off, run 'migrate up'", version, latest)
+	}
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,3 @@
+ALTER TABLE cookies DROP COLUMN IF EXISTS invalidated_at;
+ALTER TABLE cookies DROP COLUMN IF EXISTS last_validated_at;
+ALTER TABLE cookies DROP COLUMN IF EXISTS first_used_at;
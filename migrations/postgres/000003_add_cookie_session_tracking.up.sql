@@ -0,0 +1,3 @@
+ALTER TABLE cookies ADD COLUMN IF NOT EXISTS first_used_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE cookies ADD COLUMN IF NOT EXISTS last_validated_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE cookies ADD COLUMN IF NOT EXISTS invalidated_at TIMESTAMP WITH TIME ZONE;
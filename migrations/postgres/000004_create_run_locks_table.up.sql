@@ -0,0 +1,3 @@
+-- PostgreSQL runs coordinate through advisory locks and need no run_locks table. This migration
+-- only keeps the version numbers the same as the SQLite migrations.
+SELECT 1;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS statement_documents (
+    id SERIAL PRIMARY KEY,
+    sha256 CHAR(64) NOT NULL UNIQUE,
+    period_start DATE,
+    period_end DATE,
+    size_bytes INTEGER NOT NULL,
+    fetched_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS transactions (
+    id SERIAL PRIMARY KEY,
+    statement_key TEXT NOT NULL UNIQUE,
+    booking_date DATE NOT NULL,
+    value_date DATE,
+    partner_name TEXT NOT NULL,
+    amount NUMERIC(14, 2) NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'EUR',
+    raw_text TEXT,
+    document_id INTEGER REFERENCES statement_documents(id) ON DELETE SET NULL,
+    first_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_booking_date ON transactions(booking_date);
+CREATE INDEX IF NOT EXISTS idx_transactions_document_id ON transactions(document_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_statements_booking_date_amount;
+ALTER TABLE statements DROP COLUMN IF EXISTS amount;
+ALTER TABLE statements DROP COLUMN IF EXISTS partner_name;
+ALTER TABLE statements DROP COLUMN IF EXISTS booking_date;
+ALTER TABLE statements DROP COLUMN IF EXISTS key_version;
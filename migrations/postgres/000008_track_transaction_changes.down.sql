@@ -0,0 +1,8 @@
+DELETE FROM deliveries WHERE kind <> 'new';
+ALTER TABLE deliveries DROP CONSTRAINT IF EXISTS deliveries_statement_key_channel_kind_key;
+ALTER TABLE deliveries ADD CONSTRAINT deliveries_statement_key_channel_key UNIQUE (statement_key, channel);
+ALTER TABLE deliveries DROP COLUMN IF EXISTS previous_amount;
+ALTER TABLE deliveries DROP COLUMN IF EXISTS previous_booking_date;
+ALTER TABLE deliveries DROP COLUMN IF EXISTS kind;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS removed_at;
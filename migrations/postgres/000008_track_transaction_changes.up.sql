@@ -0,0 +1,12 @@
+-- Transactions that disappeared from a later statement stay in the history
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS removed_at TIMESTAMP WITH TIME ZONE;
+
+-- A statement can be delivered more than once per channel: when it is new,
+-- when its amount changes, when it is reversed and when it is removed
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'new'
+    CHECK (kind IN ('new', 'updated', 'reversed', 'removed'));
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS previous_booking_date DATE;
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS previous_amount NUMERIC(14, 2);
+
+ALTER TABLE deliveries DROP CONSTRAINT IF EXISTS deliveries_statement_key_channel_key;
+ALTER TABLE deliveries ADD CONSTRAINT deliveries_statement_key_channel_kind_key UNIQUE (statement_key, channel, kind);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transactions_category;
+ALTER TABLE transactions DROP COLUMN IF EXISTS category;
+DROP TABLE IF EXISTS category_rules;
@@ -0,0 +1,16 @@
+-- User-defined categorization rules, evaluated by priority before the built-in defaults
+CREATE TABLE IF NOT EXISTS category_rules (
+    id SERIAL PRIMARY KEY,
+    category TEXT NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 100,
+    partner_pattern TEXT,
+    min_amount NUMERIC(14, 2),
+    max_amount NUMERIC(14, 2),
+    sign TEXT CHECK (sign IN ('debit', 'credit')),
+    keywords TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS category TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_category ON transactions(category);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS sent_alerts;
+DROP TABLE IF EXISTS budgets;
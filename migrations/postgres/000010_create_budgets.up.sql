@@ -0,0 +1,16 @@
+-- Monthly spending limit per transaction category
+CREATE TABLE IF NOT EXISTS budgets (
+    category TEXT PRIMARY KEY,
+    monthly_limit NUMERIC(14, 2) NOT NULL CHECK (monthly_limit > 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Alerts sent per notification channel, so each one is only sent once
+CREATE TABLE IF NOT EXISTS sent_alerts (
+    id SERIAL PRIMARY KEY,
+    alert_key TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (alert_key, channel)
+);
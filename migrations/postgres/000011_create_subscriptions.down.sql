@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_subscriptions_next_charge_date;
+DROP TABLE IF EXISTS subscriptions;
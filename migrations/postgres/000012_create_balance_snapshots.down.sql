@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_balance_snapshots_account_recorded_at;
+DROP TABLE IF EXISTS balance_snapshots;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_purge_audit_purged_at;
+DROP TABLE IF EXISTS purge_audit;
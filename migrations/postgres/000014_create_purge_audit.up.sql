@@ -0,0 +1,12 @@
+-- Audit trail of every purge of stored data past its retention
+CREATE TABLE IF NOT EXISTS purge_audit (
+    id SERIAL PRIMARY KEY,
+    purged_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    source TEXT NOT NULL,
+    data_type TEXT NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('delete', 'anonymize')),
+    cutoff TIMESTAMP WITH TIME ZONE NOT NULL,
+    affected INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_purge_audit_purged_at ON purge_audit(purged_at);
@@ -0,0 +1,38 @@
+-- Fails when two accounts share a statement key, document, budget category or sent alert
+DROP INDEX IF EXISTS idx_transactions_account_booking_date;
+CREATE INDEX IF NOT EXISTS idx_transactions_booking_date ON transactions(booking_date);
+DROP INDEX IF EXISTS idx_cookies_account_updated_at;
+CREATE INDEX IF NOT EXISTS idx_cookies_updated_at ON cookies(updated_at DESC);
+
+ALTER TABLE sent_digests DROP CONSTRAINT IF EXISTS sent_digests_account_kind_channel_period_start_key;
+ALTER TABLE sent_digests ADD CONSTRAINT sent_digests_kind_channel_period_start_key UNIQUE (kind, channel, period_start);
+ALTER TABLE sent_digests DROP COLUMN IF EXISTS account;
+
+ALTER TABLE sent_alerts DROP CONSTRAINT IF EXISTS sent_alerts_account_alert_key_channel_key;
+ALTER TABLE sent_alerts ADD CONSTRAINT sent_alerts_alert_key_channel_key UNIQUE (alert_key, channel);
+ALTER TABLE sent_alerts DROP COLUMN IF EXISTS account;
+
+ALTER TABLE budgets DROP CONSTRAINT IF EXISTS budgets_pkey;
+ALTER TABLE budgets ADD CONSTRAINT budgets_pkey PRIMARY KEY (category);
+ALTER TABLE budgets DROP COLUMN IF EXISTS account;
+
+ALTER TABLE deliveries DROP CONSTRAINT IF EXISTS deliveries_account_statement_key_channel_kind_key;
+ALTER TABLE deliveries ADD CONSTRAINT deliveries_statement_key_channel_kind_key UNIQUE (statement_key, channel, kind);
+ALTER TABLE deliveries DROP COLUMN IF EXISTS account;
+
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_account_statement_key_key;
+ALTER TABLE transactions ADD CONSTRAINT transactions_statement_key_key UNIQUE (statement_key);
+ALTER TABLE transactions DROP COLUMN IF EXISTS account;
+
+ALTER TABLE statement_documents DROP CONSTRAINT IF EXISTS statement_documents_account_sha256_key;
+ALTER TABLE statement_documents ADD CONSTRAINT statement_documents_sha256_key UNIQUE (sha256);
+ALTER TABLE statement_documents DROP COLUMN IF EXISTS account;
+
+ALTER TABLE statements DROP CONSTRAINT IF EXISTS statements_pkey;
+ALTER TABLE statements ADD CONSTRAINT statements_pkey PRIMARY KEY (statement_key);
+ALTER TABLE statements DROP COLUMN IF EXISTS account;
+
+ALTER TABLE balance_snapshots DROP COLUMN IF EXISTS account;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS account;
+ALTER TABLE category_rules DROP COLUMN IF EXISTS account;
+ALTER TABLE cookies DROP COLUMN IF EXISTS account;
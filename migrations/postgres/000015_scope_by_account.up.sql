@@ -0,0 +1,39 @@
+-- Every table is scoped by the configured account (one N26 login) so several logins can share
+-- a database. Rows from before multiple accounts were supported belong to the default account.
+ALTER TABLE cookies ADD COLUMN IF NOT EXISTS account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE category_rules ADD COLUMN IF NOT EXISTS account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE balance_snapshots ADD COLUMN IF NOT EXISTS account TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE statements ADD COLUMN IF NOT EXISTS account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE statements DROP CONSTRAINT IF EXISTS statements_pkey;
+ALTER TABLE statements ADD CONSTRAINT statements_pkey PRIMARY KEY (account, statement_key);
+
+ALTER TABLE statement_documents ADD COLUMN IF NOT EXISTS account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE statement_documents DROP CONSTRAINT IF EXISTS statement_documents_sha256_key;
+ALTER TABLE statement_documents ADD CONSTRAINT statement_documents_account_sha256_key UNIQUE (account, sha256);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_statement_key_key;
+ALTER TABLE transactions ADD CONSTRAINT transactions_account_statement_key_key UNIQUE (account, statement_key);
+
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE deliveries DROP CONSTRAINT IF EXISTS deliveries_statement_key_channel_kind_key;
+ALTER TABLE deliveries ADD CONSTRAINT deliveries_account_statement_key_channel_kind_key UNIQUE (account, statement_key, channel, kind);
+
+ALTER TABLE budgets ADD COLUMN IF NOT EXISTS account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE budgets DROP CONSTRAINT IF EXISTS budgets_pkey;
+ALTER TABLE budgets ADD CONSTRAINT budgets_pkey PRIMARY KEY (account, category);
+
+ALTER TABLE sent_alerts ADD COLUMN IF NOT EXISTS account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE sent_alerts DROP CONSTRAINT IF EXISTS sent_alerts_alert_key_channel_key;
+ALTER TABLE sent_alerts ADD CONSTRAINT sent_alerts_account_alert_key_channel_key UNIQUE (account, alert_key, channel);
+
+ALTER TABLE sent_digests ADD COLUMN IF NOT EXISTS account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE sent_digests DROP CONSTRAINT IF EXISTS sent_digests_kind_channel_period_start_key;
+ALTER TABLE sent_digests ADD CONSTRAINT sent_digests_account_kind_channel_period_start_key UNIQUE (account, kind, channel, period_start);
+
+DROP INDEX IF EXISTS idx_cookies_updated_at;
+CREATE INDEX IF NOT EXISTS idx_cookies_account_updated_at ON cookies(account, updated_at DESC);
+DROP INDEX IF EXISTS idx_transactions_booking_date;
+CREATE INDEX IF NOT EXISTS idx_transactions_account_booking_date ON transactions(account, booking_date);
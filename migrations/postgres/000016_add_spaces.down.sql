@@ -0,0 +1,4 @@
+ALTER TABLE transactions DROP COLUMN IF EXISTS space;
+ALTER TABLE statement_documents DROP COLUMN IF EXISTS space;
+ALTER TABLE statements DROP COLUMN IF EXISTS space;
+DROP TABLE IF EXISTS spaces;
@@ -0,0 +1,14 @@
+-- N26 Spaces of every account, refreshed on each run
+CREATE TABLE IF NOT EXISTS spaces (
+    account TEXT NOT NULL DEFAULT 'default',
+    space_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account, space_id)
+);
+
+-- The Space a statement, document or transaction belongs to, empty for the main account
+ALTER TABLE statements ADD COLUMN IF NOT EXISTS space TEXT NOT NULL DEFAULT '';
+ALTER TABLE statement_documents ADD COLUMN IF NOT EXISTS space TEXT NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS space TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_cookies_updated_at;
+DROP TABLE IF EXISTS cookies;
+
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_statements_notified;
+DROP TABLE IF EXISTS statements;
+
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS statements (
+    statement_key TEXT PRIMARY KEY,
+    notified BOOLEAN NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_statements_notified ON statements(notified);
@@ -0,0 +1,3 @@
+ALTER TABLE cookies DROP COLUMN invalidated_at;
+ALTER TABLE cookies DROP COLUMN last_validated_at;
+ALTER TABLE cookies DROP COLUMN first_used_at;
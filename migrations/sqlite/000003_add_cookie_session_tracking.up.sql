@@ -0,0 +1,3 @@
+ALTER TABLE cookies ADD COLUMN first_used_at TIMESTAMP;
+ALTER TABLE cookies ADD COLUMN last_validated_at TIMESTAMP;
+ALTER TABLE cookies ADD COLUMN invalidated_at TIMESTAMP;
@@ -0,0 +1,7 @@
+-- SQLite has no advisory locks, so runs coordinate through a lease row per account
+CREATE TABLE IF NOT EXISTS run_locks (
+    lock_key TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    acquired_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_transactions_document_id;
+DROP INDEX IF EXISTS idx_transactions_booking_date;
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS statement_documents;
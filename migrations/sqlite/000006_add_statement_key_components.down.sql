@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_statements_booking_date_amount;
+ALTER TABLE statements DROP COLUMN amount;
+ALTER TABLE statements DROP COLUMN partner_name;
+ALTER TABLE statements DROP COLUMN booking_date;
+ALTER TABLE statements DROP COLUMN key_version;
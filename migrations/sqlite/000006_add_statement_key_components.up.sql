@@ -0,0 +1,14 @@
+-- Keep the fields a statement key was derived from, so keys from an older key
+-- version can be matched to new ones after a parser change
+ALTER TABLE statements ADD COLUMN key_version INTEGER NOT NULL DEFAULT 2;
+ALTER TABLE statements ADD COLUMN booking_date DATE;
+ALTER TABLE statements ADD COLUMN partner_name TEXT;
+ALTER TABLE statements ADD COLUMN amount NUMERIC;
+
+-- Fill the fields of already notified statements from the transaction history
+UPDATE statements
+SET booking_date = t.booking_date, partner_name = t.partner_name, amount = t.amount
+FROM transactions AS t
+WHERE t.statement_key = statements.statement_key;
+
+CREATE INDEX IF NOT EXISTS idx_statements_booking_date_amount ON statements(booking_date, amount);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_deliveries_channel_status;
+DROP TABLE IF EXISTS deliveries;
@@ -0,0 +1,15 @@
+-- Delivery state of every notified statement per notification channel.
+-- Statements notified before this table existed have no rows and are never re-sent.
+CREATE TABLE IF NOT EXISTS deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    statement_key TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (statement_key, channel)
+);
+
+CREATE INDEX IF NOT EXISTS idx_deliveries_channel_status ON deliveries(channel, status);
@@ -0,0 +1,22 @@
+CREATE TABLE deliveries_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    statement_key TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (statement_key, channel)
+);
+
+INSERT INTO deliveries_old (id, statement_key, channel, status, attempts, last_error, created_at, updated_at)
+SELECT id, statement_key, channel, status, attempts, last_error, created_at, updated_at FROM deliveries WHERE kind = 'new';
+
+DROP INDEX IF EXISTS idx_deliveries_channel_status;
+DROP TABLE deliveries;
+ALTER TABLE deliveries_old RENAME TO deliveries;
+
+CREATE INDEX IF NOT EXISTS idx_deliveries_channel_status ON deliveries(channel, status);
+
+ALTER TABLE transactions DROP COLUMN removed_at;
@@ -0,0 +1,29 @@
+-- Transactions that disappeared from a later statement stay in the history
+ALTER TABLE transactions ADD COLUMN removed_at TIMESTAMP;
+
+-- A statement can be delivered more than once per channel: when it is new,
+-- when its amount changes, when it is reversed and when it is removed.
+-- SQLite cannot change a UNIQUE constraint in place, so the table is rebuilt.
+CREATE TABLE deliveries_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    statement_key TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    kind TEXT NOT NULL DEFAULT 'new' CHECK (kind IN ('new', 'updated', 'reversed', 'removed')),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    previous_booking_date DATE,
+    previous_amount NUMERIC,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (statement_key, channel, kind)
+);
+
+INSERT INTO deliveries_new (id, statement_key, channel, status, attempts, last_error, created_at, updated_at)
+SELECT id, statement_key, channel, status, attempts, last_error, created_at, updated_at FROM deliveries;
+
+DROP INDEX IF EXISTS idx_deliveries_channel_status;
+DROP TABLE deliveries;
+ALTER TABLE deliveries_new RENAME TO deliveries;
+
+CREATE INDEX IF NOT EXISTS idx_deliveries_channel_status ON deliveries(channel, status);
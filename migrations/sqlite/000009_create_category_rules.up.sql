@@ -0,0 +1,16 @@
+-- User-defined categorization rules, evaluated by priority before the built-in defaults
+CREATE TABLE IF NOT EXISTS category_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    category TEXT NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 100,
+    partner_pattern TEXT,
+    min_amount NUMERIC,
+    max_amount NUMERIC,
+    sign TEXT CHECK (sign IN ('debit', 'credit')),
+    keywords TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE transactions ADD COLUMN category TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_category ON transactions(category);
@@ -0,0 +1,17 @@
+-- Recurring payments detected in the transaction history, replaced on every run
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    partner_name TEXT NOT NULL,
+    cadence TEXT NOT NULL CHECK (cadence IN ('weekly', 'monthly', 'yearly')),
+    amount NUMERIC NOT NULL,
+    previous_amount NUMERIC NOT NULL,
+    occurrences INTEGER NOT NULL,
+    first_charge_date DATE NOT NULL,
+    previous_charge_date DATE NOT NULL,
+    last_charge_date DATE NOT NULL,
+    next_charge_date DATE NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('active', 'overdue')),
+    detected_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_next_charge_date ON subscriptions(next_charge_date);
@@ -0,0 +1,12 @@
+-- Account balance read from the statement of every run
+CREATE TABLE IF NOT EXISTS balance_snapshots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id TEXT NOT NULL DEFAULT '',
+    recorded_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    period_start DATE,
+    period_end DATE,
+    balance NUMERIC NOT NULL,
+    document_id INTEGER REFERENCES statement_documents(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_balance_snapshots_account_recorded_at ON balance_snapshots(account_id, recorded_at);
@@ -0,0 +1,10 @@
+-- Weekly and monthly digests sent per notification channel, so each period is only sent once
+CREATE TABLE IF NOT EXISTS sent_digests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    sent_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (kind, channel, period_start)
+);
@@ -0,0 +1,12 @@
+-- Audit trail of every purge of stored data past its retention
+CREATE TABLE IF NOT EXISTS purge_audit (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    purged_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    source TEXT NOT NULL,
+    data_type TEXT NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('delete', 'anonymize')),
+    cutoff TIMESTAMP NOT NULL,
+    affected INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_purge_audit_purged_at ON purge_audit(purged_at);
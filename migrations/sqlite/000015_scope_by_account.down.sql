@@ -0,0 +1,128 @@
+-- Fails when two accounts share a statement key, document, budget category or sent alert
+CREATE TABLE sent_digests_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    sent_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (kind, channel, period_start)
+);
+INSERT INTO sent_digests_old (id, kind, channel, period_start, period_end, sent_at)
+SELECT id, kind, channel, period_start, period_end, sent_at FROM sent_digests;
+DROP TABLE sent_digests;
+ALTER TABLE sent_digests_old RENAME TO sent_digests;
+
+CREATE TABLE sent_alerts_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    alert_key TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    sent_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (alert_key, channel)
+);
+INSERT INTO sent_alerts_old (id, alert_key, channel, sent_at)
+SELECT id, alert_key, channel, sent_at FROM sent_alerts;
+DROP TABLE sent_alerts;
+ALTER TABLE sent_alerts_old RENAME TO sent_alerts;
+
+CREATE TABLE budgets_old (
+    category TEXT PRIMARY KEY,
+    monthly_limit NUMERIC NOT NULL CHECK (monthly_limit > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO budgets_old (category, monthly_limit, created_at, updated_at)
+SELECT category, monthly_limit, created_at, updated_at FROM budgets;
+DROP TABLE budgets;
+ALTER TABLE budgets_old RENAME TO budgets;
+
+CREATE TABLE deliveries_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    statement_key TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    kind TEXT NOT NULL DEFAULT 'new' CHECK (kind IN ('new', 'updated', 'reversed', 'removed')),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    previous_booking_date DATE,
+    previous_amount NUMERIC,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (statement_key, channel, kind)
+);
+INSERT INTO deliveries_old (id, statement_key, channel, kind, status, attempts, last_error, previous_booking_date,
+    previous_amount, created_at, updated_at)
+SELECT id, statement_key, channel, kind, status, attempts, last_error, previous_booking_date,
+    previous_amount, created_at, updated_at FROM deliveries;
+DROP INDEX IF EXISTS idx_deliveries_channel_status;
+DROP TABLE deliveries;
+ALTER TABLE deliveries_old RENAME TO deliveries;
+CREATE INDEX IF NOT EXISTS idx_deliveries_channel_status ON deliveries(channel, status);
+
+CREATE TABLE transactions_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    statement_key TEXT NOT NULL UNIQUE,
+    booking_date DATE NOT NULL,
+    value_date DATE,
+    partner_name TEXT NOT NULL,
+    amount NUMERIC NOT NULL,
+    currency TEXT NOT NULL DEFAULT 'EUR',
+    raw_text TEXT,
+    document_id INTEGER REFERENCES statement_documents(id) ON DELETE SET NULL,
+    first_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    removed_at TIMESTAMP,
+    category TEXT
+);
+INSERT INTO transactions_old (id, statement_key, booking_date, value_date, partner_name, amount, currency, raw_text,
+    document_id, first_seen_at, last_seen_at, removed_at, category)
+SELECT id, statement_key, booking_date, value_date, partner_name, amount, currency, raw_text,
+    document_id, first_seen_at, last_seen_at, removed_at, category FROM transactions;
+DROP INDEX IF EXISTS idx_transactions_account_booking_date;
+DROP INDEX IF EXISTS idx_transactions_document_id;
+DROP INDEX IF EXISTS idx_transactions_category;
+DROP TABLE transactions;
+ALTER TABLE transactions_old RENAME TO transactions;
+CREATE INDEX IF NOT EXISTS idx_transactions_booking_date ON transactions(booking_date);
+CREATE INDEX IF NOT EXISTS idx_transactions_document_id ON transactions(document_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_category ON transactions(category);
+
+CREATE TABLE statement_documents_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sha256 TEXT NOT NULL UNIQUE,
+    period_start DATE,
+    period_end DATE,
+    size_bytes INTEGER NOT NULL,
+    fetched_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO statement_documents_old (id, sha256, period_start, period_end, size_bytes, fetched_at)
+SELECT id, sha256, period_start, period_end, size_bytes, fetched_at FROM statement_documents;
+DROP TABLE statement_documents;
+ALTER TABLE statement_documents_old RENAME TO statement_documents;
+
+CREATE TABLE statements_old (
+    statement_key TEXT PRIMARY KEY,
+    notified BOOLEAN NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    key_version INTEGER NOT NULL DEFAULT 2,
+    booking_date DATE,
+    partner_name TEXT,
+    amount NUMERIC
+);
+INSERT INTO statements_old (statement_key, notified, created_at, updated_at, key_version, booking_date, partner_name, amount)
+SELECT statement_key, notified, created_at, updated_at, key_version, booking_date, partner_name, amount FROM statements;
+DROP INDEX IF EXISTS idx_statements_notified;
+DROP INDEX IF EXISTS idx_statements_booking_date_amount;
+DROP TABLE statements;
+ALTER TABLE statements_old RENAME TO statements;
+CREATE INDEX IF NOT EXISTS idx_statements_notified ON statements(notified);
+CREATE INDEX IF NOT EXISTS idx_statements_booking_date_amount ON statements(booking_date, amount);
+
+DROP INDEX IF EXISTS idx_cookies_account_updated_at;
+CREATE INDEX IF NOT EXISTS idx_cookies_updated_at ON cookies(updated_at DESC);
+
+ALTER TABLE balance_snapshots DROP COLUMN account;
+ALTER TABLE subscriptions DROP COLUMN account;
+ALTER TABLE category_rules DROP COLUMN account;
+ALTER TABLE cookies DROP COLUMN account;
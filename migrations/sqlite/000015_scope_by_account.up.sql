@@ -0,0 +1,141 @@
+-- Every table is scoped by the configured account (one N26 login) so several logins can share
+-- a database. Rows from before multiple accounts were supported belong to the default account.
+-- SQLite cannot change a primary key or UNIQUE constraint in place, so those tables are rebuilt.
+ALTER TABLE cookies ADD COLUMN account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE category_rules ADD COLUMN account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE subscriptions ADD COLUMN account TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE balance_snapshots ADD COLUMN account TEXT NOT NULL DEFAULT 'default';
+
+DROP INDEX IF EXISTS idx_cookies_updated_at;
+CREATE INDEX IF NOT EXISTS idx_cookies_account_updated_at ON cookies(account, updated_at DESC);
+
+CREATE TABLE statements_new (
+    account TEXT NOT NULL DEFAULT 'default',
+    statement_key TEXT NOT NULL,
+    notified BOOLEAN NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    key_version INTEGER NOT NULL DEFAULT 2,
+    booking_date DATE,
+    partner_name TEXT,
+    amount NUMERIC,
+    PRIMARY KEY (account, statement_key)
+);
+INSERT INTO statements_new (statement_key, notified, created_at, updated_at, key_version, booking_date, partner_name, amount)
+SELECT statement_key, notified, created_at, updated_at, key_version, booking_date, partner_name, amount FROM statements;
+DROP INDEX IF EXISTS idx_statements_notified;
+DROP INDEX IF EXISTS idx_statements_booking_date_amount;
+DROP TABLE statements;
+ALTER TABLE statements_new RENAME TO statements;
+CREATE INDEX IF NOT EXISTS idx_statements_notified ON statements(notified);
+CREATE INDEX IF NOT EXISTS idx_statements_booking_date_amount ON statements(booking_date, amount);
+
+CREATE TABLE statement_documents_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account TEXT NOT NULL DEFAULT 'default',
+    sha256 TEXT NOT NULL,
+    period_start DATE,
+    period_end DATE,
+    size_bytes INTEGER NOT NULL,
+    fetched_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (account, sha256)
+);
+INSERT INTO statement_documents_new (id, sha256, period_start, period_end, size_bytes, fetched_at)
+SELECT id, sha256, period_start, period_end, size_bytes, fetched_at FROM statement_documents;
+DROP TABLE statement_documents;
+ALTER TABLE statement_documents_new RENAME TO statement_documents;
+
+CREATE TABLE transactions_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account TEXT NOT NULL DEFAULT 'default',
+    statement_key TEXT NOT NULL,
+    booking_date DATE NOT NULL,
+    value_date DATE,
+    partner_name TEXT NOT NULL,
+    amount NUMERIC NOT NULL,
+    currency TEXT NOT NULL DEFAULT 'EUR',
+    raw_text TEXT,
+    document_id INTEGER REFERENCES statement_documents(id) ON DELETE SET NULL,
+    first_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    removed_at TIMESTAMP,
+    category TEXT,
+    UNIQUE (account, statement_key)
+);
+INSERT INTO transactions_new (id, statement_key, booking_date, value_date, partner_name, amount, currency, raw_text,
+    document_id, first_seen_at, last_seen_at, removed_at, category)
+SELECT id, statement_key, booking_date, value_date, partner_name, amount, currency, raw_text,
+    document_id, first_seen_at, last_seen_at, removed_at, category FROM transactions;
+DROP INDEX IF EXISTS idx_transactions_booking_date;
+DROP INDEX IF EXISTS idx_transactions_document_id;
+DROP INDEX IF EXISTS idx_transactions_category;
+DROP TABLE transactions;
+ALTER TABLE transactions_new RENAME TO transactions;
+CREATE INDEX IF NOT EXISTS idx_transactions_account_booking_date ON transactions(account, booking_date);
+CREATE INDEX IF NOT EXISTS idx_transactions_document_id ON transactions(document_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_category ON transactions(category);
+
+CREATE TABLE deliveries_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account TEXT NOT NULL DEFAULT 'default',
+    statement_key TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    kind TEXT NOT NULL DEFAULT 'new' CHECK (kind IN ('new', 'updated', 'reversed', 'removed')),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    previous_booking_date DATE,
+    previous_amount NUMERIC,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (account, statement_key, channel, kind)
+);
+INSERT INTO deliveries_new (id, statement_key, channel, kind, status, attempts, last_error, previous_booking_date,
+    previous_amount, created_at, updated_at)
+SELECT id, statement_key, channel, kind, status, attempts, last_error, previous_booking_date,
+    previous_amount, created_at, updated_at FROM deliveries;
+DROP INDEX IF EXISTS idx_deliveries_channel_status;
+DROP TABLE deliveries;
+ALTER TABLE deliveries_new RENAME TO deliveries;
+CREATE INDEX IF NOT EXISTS idx_deliveries_channel_status ON deliveries(channel, status);
+
+CREATE TABLE budgets_new (
+    account TEXT NOT NULL DEFAULT 'default',
+    category TEXT NOT NULL,
+    monthly_limit NUMERIC NOT NULL CHECK (monthly_limit > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account, category)
+);
+INSERT INTO budgets_new (category, monthly_limit, created_at, updated_at)
+SELECT category, monthly_limit, created_at, updated_at FROM budgets;
+DROP TABLE budgets;
+ALTER TABLE budgets_new RENAME TO budgets;
+
+CREATE TABLE sent_alerts_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account TEXT NOT NULL DEFAULT 'default',
+    alert_key TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    sent_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (account, alert_key, channel)
+);
+INSERT INTO sent_alerts_new (id, alert_key, channel, sent_at)
+SELECT id, alert_key, channel, sent_at FROM sent_alerts;
+DROP TABLE sent_alerts;
+ALTER TABLE sent_alerts_new RENAME TO sent_alerts;
+
+CREATE TABLE sent_digests_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account TEXT NOT NULL DEFAULT 'default',
+    kind TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    sent_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (account, kind, channel, period_start)
+);
+INSERT INTO sent_digests_new (id, kind, channel, period_start, period_end, sent_at)
+SELECT id, kind, channel, period_start, period_end, sent_at FROM sent_digests;
+DROP TABLE sent_digests;
+ALTER TABLE sent_digests_new RENAME TO sent_digests;
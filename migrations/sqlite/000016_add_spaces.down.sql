@@ -0,0 +1,4 @@
+ALTER TABLE transactions DROP COLUMN space;
+ALTER TABLE statement_documents DROP COLUMN space;
+ALTER TABLE statements DROP COLUMN space;
+DROP TABLE IF EXISTS spaces;
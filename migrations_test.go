@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedMigrationsMatchAcrossBackends(t *testing.T) {
+	sqliteLatest, err := latestMigrationVersion(backendSQLite)
+	if err != nil {
+		t.Fatalf("latestMigrationVersion(sqlite) error: %v", err)
+	}
+	postgresLatest, err := latestMigrationVersion(backendPostgres)
+	if err != nil {
+		t.Fatalf("latestMigrationVersion(postgres) error: %v", err)
+	}
+	if sqliteLatest == 0 || sqliteLatest != postgresLatest {
+		t.Errorf("latest versions = %d (sqlite), %d (postgres), want equal and non-zero", sqliteLatest, postgresLatest)
+	}
+
+	// Every version exists in both trees, so migrate goto and force accept the same versions
+	sqliteFiles, postgresFiles := migrationFileVersions(t, backendSQLite), migrationFileVersions(t, backendPostgres)
+	if !slices.Equal(sqliteFiles, postgresFiles) {
+		t.Errorf("migration files = %v (sqlite), %v (postgres), want the same versions", sqliteFiles, postgresFiles)
+	}
+}
+
+// migrationFileVersions lists the version and direction of every embedded migration of a backend,
+// e.g. "000004.up"
+func migrationFileVersions(t *testing.T, backend string) []string {
+	t.Helper()
+	entries, err := fs.ReadDir(migrationFiles, "migrations/"+backend)
+	if err != nil {
+		t.Fatalf("failed to list embedded migrations: %v", err)
+	}
+	var versions []string
+	for _, entry := range entries {
+		version, _, _ := strings.Cut(entry.Name(), "_")
+		versions = append(versions, version+filepath.Ext(strings.TrimSuffix(entry.Name(), ".sql")))
+	}
+	return versions
+}
+
+func TestOpenStorageChecksSchemaVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "n26.db")
+	conn := "sqlite://" + path
+	latest, err := latestMigrationVersion(backendSQLite)
+	if err != nil {
+		t.Fatalf("latestMigrationVersion error: %v", err)
+	}
+
+	// Without auto-migrate an empty database is refused until it is migrated explicitly
+	t.Setenv("AUTO_MIGRATE", "false")
+	if _, err := OpenStorage(conn); err == nil || !strings.Contains(err.Error(), "migrate up") {
+		t.Fatalf("OpenStorage(unmigrated) error = %v, want a hint to run migrate up", err)
+	}
+	db, err := openSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("openSQLiteDB error: %v", err)
+	}
+	defer db.Close()
+	var out bytes.Buffer
+	if err := migrateSchema(&out, db, backendSQLite, []string{"up"}); err != nil {
+		t.Fatalf("migrate up error: %v", err)
+	}
+	if want := "Schema version " + strconv.Itoa(int(latest)) + " of "; !strings.Contains(out.String(), want) {
+		t.Errorf("migrate up output = %q, want %q", out.String(), want)
+	}
+	openTestStorage(t, conn).Close()
+
+	// A reverted migration is applied again at startup when auto-migrate is on
+	if err := migrateSchema(&out, db, backendSQLite, []string{"down", "1"}); err != nil {
+		t.Fatalf("migrate down error: %v", err)
+	}
+	if _, err := OpenStorage(conn); err == nil {
+		t.Error("OpenStorage(older schema, AUTO_MIGRATE=false) succeeded, want error")
+	}
+	t.Setenv("AUTO_MIGRATE", "true")
+	openTestStorage(t, conn).Close()
+
+	// A schema written by a newer binary is always refused
+	if err := migrateSchema(&out, db, backendSQLite, []string{"force", strconv.Itoa(int(latest) + 1)}); err != nil {
+		t.Fatalf("migrate force error: %v", err)
+	}
+	if _, err := OpenStorage(conn); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("OpenStorage(newer schema) error = %v, want a newer schema error", err)
+	}
+}
+
+func TestMigrateSchemaRejectsInvalidArguments(t *testing.T) {
+	db, err := openSQLiteDB(filepath.Join(t.TempDir(), "n26.db"))
+	if err != nil {
+		t.Fatalf("openSQLiteDB error: %v", err)
+	}
+	defer db.Close()
+
+	for _, args := range [][]string{{"sideways"}, {"down"}, {"down", "0"}, {"goto", "-1"}, {"force", "x"}, {"up", "2"}} {
+		if err := migrateSchema(&bytes.Buffer{}, db, backendSQLite, args); err == nil {
+			t.Errorf("migrate %v succeeded, want error", args)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in minor units (cents) of a currency. Statements show amounts in both
+// "1.234,56" and "1,234.56" styles; counting cents keeps sums and comparisons exact.
+type Money struct {
+	Minor    int64  // Amount in minor units, negative for debits and overdrafts
+	Currency string // ISO 4217 code, empty means statementCurrency
+}
+
+// moneyMinorDigits is the number of decimals of the currencies N26 statements use
+const moneyMinorDigits = 2
+
+// currencySymbols are stripped from amounts before parsing, with the code they stand for
+var currencySymbols = []struct{ symbol, code string }{{"€", "EUR"}, {"EUR", "EUR"}, {"$", "USD"}, {"USD", "USD"}, {"£", "GBP"}, {"GBP", "GBP"}}
+
+// newMoney creates an amount from a whole number of minor units
+func newMoney(minor int64, currency string) Money {
+	return Money{Minor: minor, Currency: currency}
+}
+
+// moneyFromFloat converts a decimal value, as read from a NUMERIC column, to the nearest cent
+func moneyFromFloat(value float64, currency string) Money {
+	return Money{Minor: int64(math.Round(value * 100)), Currency: currency}
+}
+
+// parseMoney reads an amount as statements and users write it. The decimal separator may be
+// "," or "." ("1.234,56" and "1,234.56" are the same amount); with a single separator followed
+// by three digits it separates thousands ("1.234" is 1234). Spaces and apostrophes may also
+// separate thousands. Negative amounts are written "-2,50", "2,50-", "(2,50)" or with the
+// unicode minus sign. A currency symbol or code is taken as the currency, defaultCurrency
+// is used otherwise.
+func parseMoney(text, defaultCurrency string) (Money, error) {
+	value := strings.TrimSpace(text)
+	currency := defaultCurrency
+	for _, c := range currencySymbols {
+		if strings.Contains(value, c.symbol) {
+			value = strings.ReplaceAll(value, c.symbol, "")
+			currency = c.code
+		}
+	}
+	value = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', ' ', ' ', '\'', '’':
+			return -1
+		case '−':
+			return '-'
+		}
+		return r
+	}, value)
+
+	negative := false
+	switch {
+	case strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")"):
+		negative, value = true, value[1:len(value)-1]
+	case strings.HasSuffix(value, "-"):
+		negative, value = true, strings.TrimSuffix(value, "-")
+	}
+	if strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+		negative = negative != (value[0] == '-')
+		value = value[1:]
+	}
+
+	whole, fraction, err := splitDecimal(value)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q: %w", text, err)
+	}
+	units, err := strconv.ParseInt(whole+fraction+strings.Repeat("0", moneyMinorDigits-len(fraction)), 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q: %w", text, err)
+	}
+	if negative {
+		units = -units
+	}
+	return Money{Minor: units, Currency: currency}, nil
+}
+
+// splitDecimal splits an unsigned amount into its whole digits, without thousands separators,
+// and its decimal digits
+func splitDecimal(value string) (whole, fraction string, err error) {
+	decimal := strings.LastIndexAny(value, ".,")
+	if decimal >= 0 && !(strings.Contains(value, ".") && strings.Contains(value, ",")) {
+		// With one kind of separator, "1.234" and "1,234,567" only separate thousands
+		separator := value[decimal : decimal+1]
+		if strings.Count(value, separator) > 1 || len(value)-decimal-1 == 3 {
+			decimal = -1
+		}
+	}
+
+	whole = value
+	if decimal >= 0 {
+		whole, fraction = value[:decimal], value[decimal+1:]
+		if strings.Contains(whole, value[decimal:decimal+1]) {
+			return "", "", fmt.Errorf("misplaced decimal separator")
+		}
+		if len(fraction) > moneyMinorDigits {
+			return "", "", fmt.Errorf("more than %d decimals", moneyMinorDigits)
+		}
+	}
+
+	groups := strings.FieldsFunc(whole, func(r rune) bool { return r == '.' || r == ',' })
+	for i, group := range groups {
+		if (i > 0 && len(group) != 3) || (i == 0 && len(groups) > 1 && len(group) > 3) {
+			return "", "", fmt.Errorf("misplaced thousands separator")
+		}
+	}
+	whole = strings.Join(groups, "")
+	if whole == "" && fraction == "" {
+		return "", "", fmt.Errorf("no digits")
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	for _, r := range whole + fraction {
+		if r < '0' || r > '9' {
+			return "", "", fmt.Errorf("unexpected character %q", r)
+		}
+	}
+	return whole, fraction, nil
+}
+
+// currency returns the currency code, defaulting to the statement currency
+func (m Money) currency() string {
+	if m.Currency == "" {
+		return statementCurrency
+	}
+	return m.Currency
+}
+
+// Float returns the amount in major units, for statistics and charts
+func (m Money) Float() float64 {
+	return float64(m.Minor) / 100
+}
+
+// Neg returns the amount with the opposite sign, e.g. the reversal of a charge
+func (m Money) Neg() Money {
+	return Money{Minor: -m.Minor, Currency: m.Currency}
+}
+
+// Add returns the sum of two amounts, failing when their currencies differ
+func (m Money) Add(other Money) (Money, error) {
+	if m.currency() != other.currency() {
+		return Money{}, fmt.Errorf("cannot add %s to %s, the currencies differ", other, m)
+	}
+	currency := m.Currency
+	if currency == "" {
+		currency = other.Currency
+	}
+	return Money{Minor: m.Minor + other.Minor, Currency: currency}, nil
+}
+
+// Compare returns -1, 0 or +1 as the amount is smaller than, equal to or larger than the other,
+// failing when their currencies differ
+func (m Money) Compare(other Money) (int, error) {
+	difference, err := m.Add(other.Neg())
+	if err != nil {
+		return 0, err
+	}
+	return cmp.Compare(difference.Minor, 0), nil
+}
+
+// Abs returns the amount without its sign
+func (m Money) Abs() Money {
+	if m.Minor < 0 {
+		return m.Neg()
+	}
+	return m
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Minor == 0
+}
+
+// decimal formats the amount with a "." decimal separator and no thousands separators
+// ("-1234.56"), the form stored in NUMERIC columns and exported to CSV
+func (m Money) decimal() string {
+	units := m.Minor
+	sign := ""
+	if units < 0 {
+		sign, units = "-", -units
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, units/100, units%100)
+}
+
+// format formats the amount the way N26 statements show it, e.g. "-1.234,56"
+func (m Money) format() string {
+	whole, fraction, _ := strings.Cut(m.decimal(), ".")
+	sign := ""
+	if strings.HasPrefix(whole, "-") {
+		sign, whole = "-", whole[1:]
+	}
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "." + whole[i:]
+	}
+	return sign + whole + "," + fraction
+}
+
+// String formats the amount with its currency, e.g. "-1.234,56 EUR"
+func (m Money) String() string {
+	return m.format() + " " + m.currency()
+}
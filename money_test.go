@@ -0,0 +1,101 @@
+package main
+
+import "testing"
+
+// eur parses an amount written as in a statement, for test fixtures
+func eur(amount string) Money {
+	money, err := parseMoney(amount, statementCurrency)
+	if err != nil {
+		panic(err)
+	}
+	return money
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := map[string]int64{
+		"-2,50":          -250,
+		"2,50€":          250,
+		"+100,10 €":      10010,
+		"1.234,56":       123456,
+		"1,234.56":       123456,
+		"-12,345.67 EUR": -1234567,
+		"1.234.567,89":   123456789,
+		"1,234,567":      123456700,
+		"1.234":          123400,
+		"1 234,56":       123456,
+		"1'234.56":       123456,
+		"99.5":           9950,
+		"7":              700,
+		",50":            50,
+		"250,00-":        -25000,
+		"(1.000,00)":     -100000,
+		"−3,20 €":        -320,
+		"-€2.50":         -250,
+	}
+	for text, want := range tests {
+		got, err := parseMoney(text, statementCurrency)
+		if err != nil {
+			t.Errorf("parseMoney(%q) failed: %v", text, err)
+			continue
+		}
+		if got.Minor != want || got.Currency != "EUR" {
+			t.Errorf("parseMoney(%q) = %+v, want %d EUR cents", text, got, want)
+		}
+	}
+
+	for _, text := range []string{"", "-", "abc", "2,5055", "1.23.45", "12.34,5.6", "1,2345.00", "1.234,56,78", "(2,50"} {
+		if got, err := parseMoney(text, statementCurrency); err == nil {
+			t.Errorf("parseMoney(%q) = %+v, want error", text, got)
+		}
+	}
+}
+
+func TestParseMoneyCurrency(t *testing.T) {
+	if got := eur("$12.00"); got.Currency != "USD" || got.Minor != 1200 {
+		t.Errorf("parseMoney($12.00) = %+v, want 1200 USD cents", got)
+	}
+	if got, err := parseMoney("12,00", ""); err != nil || got.Currency != "" || got.String() != "12,00 EUR" {
+		t.Errorf("parseMoney(12,00) = %+v, %v, want the statement currency by default", got, err)
+	}
+}
+
+func TestMoneyFormatting(t *testing.T) {
+	tests := []struct {
+		money   Money
+		decimal string
+		text    string
+	}{
+		{newMoney(-250, "EUR"), "-2.50", "-2,50 EUR"},
+		{newMoney(123456789, "EUR"), "1234567.89", "1.234.567,89 EUR"},
+		{newMoney(-100000, "USD"), "-1000.00", "-1.000,00 USD"},
+		{newMoney(5, ""), "0.05", "0,05 EUR"},
+		{newMoney(-5, "EUR"), "-0.05", "-0,05 EUR"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.decimal(); got != tt.decimal {
+			t.Errorf("%+v.decimal() = %q, want %q", tt.money, got, tt.decimal)
+		}
+		if got := tt.money.String(); got != tt.text {
+			t.Errorf("%+v.String() = %q, want %q", tt.money, got, tt.text)
+		}
+		if parsed := eur(tt.money.format()); parsed.Minor != tt.money.Minor {
+			t.Errorf("parseMoney(%q) = %d cents, want %d", tt.money.format(), parsed.Minor, tt.money.Minor)
+		}
+	}
+	if got := moneyFromFloat(-0.1+-0.2, "EUR"); got.Minor != -30 {
+		t.Errorf("moneyFromFloat(-0.3) = %d cents, want -30", got.Minor)
+	}
+}
+
+func TestMoneyAdd(t *testing.T) {
+	sum, err := eur("-2,50").Add(newMoney(1000, ""))
+	if err != nil || sum != eur("7,50") {
+		t.Errorf("Add() = %+v, %v, want 7,50 EUR", sum, err)
+	}
+	if sum, err := (Money{}).Add(eur("1,00")); err != nil || sum.Currency != "EUR" || sum.Minor != 100 {
+		t.Errorf("Add() to the zero amount = %+v, %v, want 1,00 EUR", sum, err)
+	}
+	if sum, err := eur("-2,50").Add(eur("$2,50")); err == nil {
+		t.Errorf("Add() of EUR and USD = %+v, want an error", sum)
+	}
+}
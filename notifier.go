@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Notifier delivers notifications about new transactions, and alerts, to one channel
+type Notifier interface {
+	// Channel is the stable name delivery state is tracked under, e.g. "discord"
+	Channel() string
+	Notify(notification Notification) error
+	SendAlert(alert Alert) error
+}
+
+// Notification is a batch of transaction changes of one kind for one channel
+type Notification struct {
+	Kind              ChangeKind
+	Space             string // Name of the N26 Space the changes are of, empty for the main account
+	Changes           []TransactionChange
+	TotalTransactions int    // Transactions in the downloaded statement
+	Balance           *Money // Account balance, nil when unknown
+}
+
+// loadNotifiers returns the notification channels configured for the account
+func loadNotifiers(config AccountConfig) ([]Notifier, error) {
+	var notifiers []Notifier
+	if webhookURL := config.Getenv("WEBHOOK_URL"); webhookURL != "" {
+		notifiers = append(notifiers, &DiscordNotifier{webhookURL: webhookURL})
+	}
+
+	if len(notifiers) == 0 {
+		return nil, fmt.Errorf("WEBHOOK_URL environment variable is not set")
+	}
+	return notifiers, nil
+}
+
+// DiscordWebhookPayload represents the JSON structure for Discord webhook
+type DiscordWebhookPayload struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []DiscordEmbed `json:"embeds,omitempty"`
+}
+
+// DiscordEmbed is a rich embed in a Discord webhook message
+type DiscordEmbed struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Color       int                 `json:"color"` // 0x00FF00 for green (success)
+	Fields      []DiscordEmbedField `json:"fields,omitempty"`
+	Timestamp   string              `json:"timestamp,omitempty"`
+}
+
+// DiscordEmbedField is a name/value field of a Discord embed
+type DiscordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// DiscordNotifier posts notifications to a Discord webhook
+type DiscordNotifier struct {
+	webhookURL string
+}
+
+// Channel returns the delivery channel name of Discord notifications
+func (n *DiscordNotifier) Channel() string {
+	return "discord"
+}
+
+// discordStyles is the embed title and color of each kind of change
+var discordStyles = map[ChangeKind]struct {
+	title string
+	color int
+}{
+	ChangeNew:      {"✅ N26 PDF Movements", 0x00FF00},          // Green
+	ChangeUpdated:  {"🔄 N26 Updated Transactions", 0xFFA500},   // Orange
+	ChangeReversed: {"↩️ N26 Reversed Transactions", 0x3498DB}, // Blue
+	ChangeRemoved:  {"🗑️ N26 Removed Transactions", 0x95A5A6},  // Grey
+}
+
+// Notify posts the changes as a Discord embed, styled by their kind
+func (n *DiscordNotifier) Notify(notification Notification) error {
+	changes := notification.Changes
+
+	// Format transactions (limit to first 10 for Discord embed)
+	var transactionsText strings.Builder
+	maxTransactions := min(len(changes), 10)
+
+	for _, change := range changes[:maxTransactions] {
+		transactionsText.WriteString(formatDiscordChange(change) + "\n")
+	}
+
+	if len(changes) > maxTransactions {
+		transactionsText.WriteString(fmt.Sprintf("\n_... and %d more %s transactions_", len(changes)-maxTransactions, notification.Kind))
+	}
+
+	// Create Discord embed
+	var fields []DiscordEmbedField
+	if notification.Kind == ChangeNew {
+		fields = append(fields,
+			DiscordEmbedField{
+				Name:   "New Transactions",
+				Value:  fmt.Sprintf("%d", len(changes)),
+				Inline: true,
+			},
+			DiscordEmbedField{
+				Name:   "Total Transactions",
+				Value:  fmt.Sprintf("%d", notification.TotalTransactions),
+				Inline: true,
+			},
+		)
+	}
+	balance := "N/A"
+	if notification.Balance != nil {
+		balance = notification.Balance.String()
+	}
+	fields = append(fields,
+		DiscordEmbedField{
+			Name:   "Account Balance",
+			Value:  balance,
+			Inline: true,
+		},
+		DiscordEmbedField{
+			Name:   "Transactions",
+			Value:  transactionsText.String(),
+			Inline: false,
+		},
+	)
+
+	// Create content message for notification preview with all changes
+	var contentBuilder strings.Builder
+	for _, change := range changes {
+		contentBuilder.WriteString(formatDiscordChange(change) + "\n\n")
+	}
+
+	style := discordStyles[notification.Kind]
+	title := style.title
+	if notification.Space != "" {
+		title += " · " + notification.Space
+	}
+	payload := DiscordWebhookPayload{
+		Content: strings.TrimSpace(contentBuilder.String()),
+		Embeds: []DiscordEmbed{
+			{
+				Title:       title,
+				Description: "",
+				Color:       style.color,
+				Fields:      fields,
+				Timestamp:   time.Now().Format(time.RFC3339),
+			},
+		},
+	}
+
+	return n.post(payload)
+}
+
+// formatDiscordChange formats one change as "Date | Partner Name | Amount", with what changed
+func formatDiscordChange(change TransactionChange) string {
+	record := change.Record
+	date := formatStatementDate(record.BookingDate)
+	line := fmt.Sprintf("**%s** | %s | `%s`", date, record.PartnerName, record.Amount)
+
+	switch change.Kind {
+	case ChangeUpdated:
+		if change.Previous != nil {
+			line = fmt.Sprintf("**%s** | %s | ~~`%s`~~ → `%s`",
+				date, record.PartnerName, change.Previous.Amount, record.Amount)
+		}
+	case ChangeReversed:
+		if change.Previous != nil {
+			line += fmt.Sprintf(" reverses `%s` of %s", change.Previous.Amount, formatStatementDate(change.Previous.BookingDate))
+		}
+	case ChangeRemoved:
+		line = fmt.Sprintf("**%s** | %s | ~~`%s`~~", date, record.PartnerName, record.Amount)
+	}
+	if record.Category != "" {
+		line += fmt.Sprintf(" | _%s_", record.Category)
+	}
+	return line
+}
+
+// discordAlertColors is the embed color of each kind of alert
+var discordAlertColors = map[AlertKind]int{
+	AlertBudget:       0xE74C3C, // Red
+	AlertSubscription: 0x9B59B6, // Purple
+	AlertAnomaly:      0xF1C40F, // Yellow
+	AlertBalance:      0xE67E22, // Orange
+	AlertIntegrity:    0x95A5A6, // Grey
+	AlertDigest:       0x3498DB, // Blue
+}
+
+// SendAlert posts an alert as a Discord embed with one line per item
+func (n *DiscordNotifier) SendAlert(alert Alert) error {
+	text := strings.Join(alert.Lines, "\n")
+	payload := DiscordWebhookPayload{
+		Content: text,
+		Embeds: []DiscordEmbed{
+			{
+				Title:       alert.Title,
+				Description: text,
+				Color:       discordAlertColors[alert.Kind],
+				Timestamp:   time.Now().Format(time.RFC3339),
+			},
+		},
+	}
+	return n.post(payload)
+}
+
+// post sends a payload to the webhook
+func (n *DiscordNotifier) post(payload DiscordWebhookPayload) error {
+	// Marshal JSON
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Discord payload: %w", err)
+	}
+
+	// Send HTTP POST request
+	req, err := http.NewRequest("POST", n.webhookURL, strings.NewReader(string(jsonData)))
+	if err != nil {
+		return fmt.Errorf("failed to create Discord webhook request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send Discord webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("discord webhook returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
@@ -2,23 +2,31 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/gen2brain/go-fitz"
 )
 
 // Transaction represents a parsed transaction from the PDF
 type Transaction struct {
-	BookingDate string
-	ValueDate   string
+	BookingDate time.Time // Midnight of the day in the account's timezone
+	ValueDate   time.Time
 	PartnerName string
-	Amount      string
+	Amount      Money
+	RawText     string // The lines of the PDF the transaction was parsed from
+	Category    string // Assigned by the categorization rules, empty until categorized
+	Space       string // ID of the N26 Space the transaction belongs to, empty for the main account
 }
 
+// statementCurrency is the currency of N26 statement amounts (always shown with €)
+const statementCurrency = "EUR"
+
 // AccountBalance represents the account balance extracted from the PDF
 type AccountBalance struct {
-	Balance string // The balance amount (e.g., "1,234.56" or "1.234,56")
+	Balance Money // Negative when the account is overdrawn
 }
 
 // PDFParser handles parsing of N26 PDF statements
@@ -79,7 +87,21 @@ func (p *PDFParser) ParseTransactions() ([]Transaction, error) {
 		return nil, err
 	}
 
-	return parseTransactionsFromText(text)
+	period, err := parsePeriodFromText(text)
+	if err != nil {
+		return parseTransactionsFromText(text, nil)
+	}
+	return parseTransactionsFromText(text, &period)
+}
+
+// ParsePeriod extracts the period the statement covers, as its header states it
+func (p *PDFParser) ParsePeriod() (StatementPeriod, error) {
+	text, err := p.ExtractText()
+	if err != nil {
+		return StatementPeriod{}, err
+	}
+
+	return parsePeriodFromText(text)
 }
 
 // ParseBalance extracts the account balance from the PDF text
@@ -92,54 +114,118 @@ func (p *PDFParser) ParseBalance() (*AccountBalance, error) {
 	return parseBalanceFromText(text)
 }
 
-// parseBalanceFromText extracts the account balance from PDF text
-func parseBalanceFromText(text string) (*AccountBalance, error) {
-	lines := strings.Split(text, "\n")
+// Labels of the balances in the statement summary, Spanish and English. The amount is on the next line.
+var (
+	closingBalanceLabels = []string{"Tu nuevo saldo", "Your new balance"}
+	openingBalanceLabels = []string{"Saldo previo", "Previous balance"}
+)
 
-	// Look for the line containing "Tu nuevo saldo" (Spanish) or "Your new balance" (English)
-	balancePattern := regexp.MustCompile(`([+-]?\d+[.,]\d{2})\s*€?`)
+// amountText matches an amount with optional thousands separators and sign, so "1.234,56" is not
+// taken as "234,56". An overdraft may also be shown with a trailing minus or in parentheses.
+const amountText = `[+\-−]?\(?(?:\d{1,3}(?:[.,]\d{3})+|\d+)[.,]\d{2}\)?-?`
 
-	for i, line := range lines {
-		line = strings.TrimSpace(line)
+// balancePattern finds amounts, with their euro sign, within a line
+var balancePattern = regexp.MustCompile(amountText + `\s*€?`)
 
-		// Check for Spanish balance text
-		if strings.Contains(line, "Tu nuevo saldo") || strings.Contains(line, "tu nuevo saldo") {
-			// The balance is on the next line
-			if i+1 < len(lines) {
-				nextLine := strings.TrimSpace(lines[i+1])
-				// Extract balance amount from the next line
-				amounts := balancePattern.FindAllString(nextLine, -1)
-				if len(amounts) > 0 {
-					// Take the last amount found (most likely the balance)
-					balance := strings.TrimSpace(amounts[len(amounts)-1])
-					balance = strings.TrimSuffix(balance, "€")
-					balance = strings.TrimSpace(balance)
-					return &AccountBalance{Balance: balance}, nil
-				}
-			}
+// statementAmountText matches an amount written the way statements write them, with a comma
+// decimal and dot thousands separators, so a line like "12.50" or "01.10" is not an amount
+const statementAmountText = `[+\-−]?\(?(?:\d{1,3}(?:\.\d{3})+|\d+),\d{2}\)?-?`
+
+// amountLinePattern matches a line that only holds an amount, the last line of a transaction
+var amountLinePattern = regexp.MustCompile(`^` + statementAmountText + `\s*€?\s*$`)
+
+// periodPattern matches the header line stating the statement period, e.g. "01.10.2025 hasta
+// 31.10.2025" or "Kontoauszug 01.10.2025 - 31.10.2025". The line holds nothing else, so a date
+// range in a transaction description does not match.
+var periodPattern = regexp.MustCompile(`^(?i:(?:Kontoauszug|Extracto|Account statement|Actividad de la cuenta)[ \t:]*)?` +
+	`(\d{2}\.\d{2}\.\d{4})[ \t]*(?:-|–|hasta|until|to|bis|al)[ \t]*(\d{2}\.\d{2}\.\d{4})$`)
+
+// bookingDateLinePattern matches a line holding only a date, the booking date of a transaction
+var bookingDateLinePattern = regexp.MustCompile(`^\d{2}\.\d{2}\.\d{4}$`)
+
+// parsePeriodFromText extracts the statement period from the header of the PDF text, the lines
+// before the first transaction, with both dates in the account's timezone
+func parsePeriodFromText(text string) (StatementPeriod, error) {
+	var match []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if bookingDateLinePattern.MatchString(line) || amountLinePattern.MatchString(line) {
+			break
+		}
+		if match = periodPattern.FindStringSubmatch(line); match != nil {
+			break
 		}
+	}
+	if match == nil {
+		return StatementPeriod{}, fmt.Errorf("statement period not found in PDF header")
+	}
+	from, err := parseStatementDate(match[1])
+	if err != nil {
+		return StatementPeriod{}, err
+	}
+	to, err := parseStatementDate(match[2])
+	if err != nil {
+		return StatementPeriod{}, err
+	}
+	if to.Before(from) {
+		return StatementPeriod{}, fmt.Errorf("statement period %s ends before it starts", match[0])
+	}
+	return StatementPeriod{From: from, To: to}, nil
+}
 
-		// Check for English balance text (if PDF is in English)
-		if strings.Contains(line, "Your new balance") || strings.Contains(line, "your new balance") {
-			// The balance is on the next line
-			if i+1 < len(lines) {
-				nextLine := strings.TrimSpace(lines[i+1])
-				amounts := balancePattern.FindAllString(nextLine, -1)
-				if len(amounts) > 0 {
-					balance := strings.TrimSpace(amounts[len(amounts)-1])
-					balance = strings.TrimSuffix(balance, "€")
-					balance = strings.TrimSpace(balance)
-					return &AccountBalance{Balance: balance}, nil
-				}
+// contains reports whether a date falls within the period, both ends included
+func (p StatementPeriod) contains(date time.Time) bool {
+	return !date.Before(p.From) && !date.After(p.To)
+}
+
+// parseBalanceFromText extracts the account balance (the new balance) from PDF text
+func parseBalanceFromText(text string) (*AccountBalance, error) {
+	balance, found, err := findLabeledAmount(text, closingBalanceLabels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse balance: %w", err)
+	}
+	if !found {
+		return nil, fmt.Errorf("balance not found in PDF")
+	}
+	return &AccountBalance{Balance: balance}, nil
+}
+
+// parseOpeningBalanceFromText extracts the balance at the start of the statement period from PDF text
+func parseOpeningBalanceFromText(text string) (*AccountBalance, error) {
+	balance, found, err := findLabeledAmount(text, openingBalanceLabels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse previous balance: %w", err)
+	}
+	if !found {
+		return nil, fmt.Errorf("previous balance not found in PDF")
+	}
+	return &AccountBalance{Balance: balance}, nil
+}
+
+// findLabeledAmount returns the last amount on the line after the first line containing one
+// of the labels (case-insensitive); found is false when there is none
+func findLabeledAmount(text string, labels []string) (amount Money, found bool, err error) {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.ToLower(strings.TrimSpace(line))
+		for _, label := range labels {
+			if !strings.Contains(line, strings.ToLower(label)) || i+1 >= len(lines) {
+				continue
+			}
+			// Take the last amount found (most likely the balance)
+			amounts := balancePattern.FindAllString(strings.TrimSpace(lines[i+1]), -1)
+			if len(amounts) > 0 {
+				amount, err := parseMoney(amounts[len(amounts)-1], statementCurrency)
+				return amount, true, err
 			}
 		}
 	}
-
-	return nil, fmt.Errorf("balance not found in PDF")
+	return Money{}, false, nil
 }
 
-// parseTransactionsFromText extracts transaction data from PDF text
-func parseTransactionsFromText(text string) ([]Transaction, error) {
+// parseTransactionsFromText extracts transaction data from PDF text. Transactions booked outside
+// the period the header states are rejected as misread, period is nil when it states none.
+func parseTransactionsFromText(text string, period *StatementPeriod) ([]Transaction, error) {
 	var transactions []Transaction
 
 	// Split text into lines
@@ -149,9 +235,6 @@ func parseTransactionsFromText(text string) ([]Transaction, error) {
 	// Date format: DD.MM.YYYY
 	// Amount format: -XX,XX€ or +XX,XX€ or XX,XX€
 	datePattern := regexp.MustCompile(`(\d{2}\.\d{2}\.\d{4})`)
-	// Amount with euro sign: -2,50€ or 2,50€
-	amountPattern := regexp.MustCompile(`([+-]?\d+[.,]\d{2})\s*€?`)
-
 	// Look for transaction blocks
 	// In N26 PDFs, transactions typically appear as:
 	// Partner Name
@@ -160,6 +243,9 @@ func parseTransactionsFromText(text string) ([]Transaction, error) {
 	// DD.MM.YYYY
 	// -XX,XX€
 
+	// First line of the current transaction block (the line after the previous amount)
+	blockStart := 0
+
 	for i := 0; i < len(lines); i++ {
 		line := strings.TrimSpace(lines[i])
 		if line == "" {
@@ -167,15 +253,20 @@ func parseTransactionsFromText(text string) ([]Transaction, error) {
 		}
 
 		// Look for amount pattern with euro sign (transaction amount line)
-		// Pattern: -XX,XX€ or +XX,XX€ (must have comma as decimal separator for N26)
-		if matched, _ := regexp.MatchString(`^[+-]?\d+,\d{2}\s*€?\s*$`, line); matched {
+		// Pattern: -XX,XX€, +X.XXX,XX€ or XX,XX€
+		if amountLinePattern.MatchString(line) {
 			// Found an amount line, extract it
-			amount := strings.TrimSpace(line)
-			amount = strings.TrimSuffix(amount, "€")
-			amount = strings.TrimSpace(amount)
+			amount, err := parseMoney(line, statementCurrency)
+			if err != nil {
+				continue
+			}
 
 			// Look backwards for transaction details (up to 10 lines back)
-			tx := &Transaction{Amount: amount}
+			tx := &Transaction{
+				Amount:  amount,
+				RawText: rawTransactionBlock(lines, max(blockStart, i-10), i),
+			}
+			blockStart = i + 1
 
 			// Look for dates in previous lines
 			for j := max(0, i-10); j < i; j++ {
@@ -185,18 +276,22 @@ func parseTransactionsFromText(text string) ([]Transaction, error) {
 				if strings.Contains(checkLine, "Fecha de valor") {
 					dates := datePattern.FindAllString(checkLine, -1)
 					if len(dates) > 0 {
-						tx.ValueDate = dates[0]
+						if date, err := parseStatementDate(dates[0]); err == nil {
+							tx.ValueDate = date
+						}
 					}
 				}
 
 				// Check for booking date (standalone date line, not in "Fecha de valor")
-				if tx.BookingDate == "" && !strings.Contains(checkLine, "Fecha de valor") {
+				if tx.BookingDate.IsZero() && !strings.Contains(checkLine, "Fecha de valor") {
 					dates := datePattern.FindAllString(checkLine, -1)
 					if len(dates) > 0 && len(checkLine) < 20 {
 						// Likely a standalone date line
-						tx.BookingDate = dates[0]
-						if tx.ValueDate == "" {
-							tx.ValueDate = dates[0]
+						if date, err := parseStatementDate(dates[0]); err == nil {
+							tx.BookingDate = date
+							if tx.ValueDate.IsZero() {
+								tx.ValueDate = date
+							}
 						}
 					}
 				}
@@ -210,7 +305,7 @@ func parseTransactionsFromText(text string) ([]Transaction, error) {
 				// Skip if it's a header, date, amount, or metadata line
 				if len(checkLine) < 3 ||
 					datePattern.MatchString(checkLine) ||
-					amountPattern.MatchString(checkLine) ||
+					balancePattern.MatchString(checkLine) ||
 					strings.Contains(checkLine, "Fecha") ||
 					strings.Contains(checkLine, "Descripción") ||
 					strings.Contains(checkLine, "Cantidad") ||
@@ -234,18 +329,17 @@ func parseTransactionsFromText(text string) ([]Transaction, error) {
 				}
 			}
 
-			// Only add transaction if it has required fields and valid amount
-			if tx.BookingDate != "" && tx.Amount != "" {
-				// Validate amount is actually an amount (has comma, not just a date)
-				if strings.Contains(tx.Amount, ",") {
-					// Filter out non-transaction entries
-					if isRealTransaction(tx) {
-						if tx.ValueDate == "" {
-							tx.ValueDate = tx.BookingDate
-						}
-						transactions = append(transactions, *tx)
-					}
+			// Only add transaction if it has required fields, filtering out non-transaction entries
+			if !tx.BookingDate.IsZero() && isRealTransaction(tx) {
+				if period != nil && !period.contains(tx.BookingDate) {
+					log.Printf("Warning: Rejected transaction %s %q booked outside the statement period %s – %s",
+						formatStatementDate(tx.BookingDate), tx.PartnerName, formatStatementDate(period.From), formatStatementDate(period.To))
+					continue
 				}
+				if tx.ValueDate.IsZero() {
+					tx.ValueDate = tx.BookingDate
+				}
+				transactions = append(transactions, *tx)
 			}
 		}
 	}
@@ -253,6 +347,17 @@ func parseTransactionsFromText(text string) ([]Transaction, error) {
 	return transactions, nil
 }
 
+// rawTransactionBlock joins the non-empty lines from start to end (inclusive)
+func rawTransactionBlock(lines []string, start, end int) string {
+	var block []string
+	for j := start; j <= end && j < len(lines); j++ {
+		if line := strings.TrimSpace(lines[j]); line != "" {
+			block = append(block, line)
+		}
+	}
+	return strings.Join(block, "\n")
+}
+
 func max(a, b int) int {
 	if a > b {
 		return a
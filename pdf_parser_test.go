@@ -0,0 +1,148 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// statementDay parses a DD.MM.YYYY date as statements write it, for test fixtures
+func statementDay(value string) time.Time {
+	date, err := parseStatementDate(value)
+	if err != nil {
+		panic(err)
+	}
+	return date
+}
+
+func TestParseTransactionsFromTextAmounts(t *testing.T) {
+	tests := map[string]int64{
+		"-2,50€":       -250,
+		"+100,10€":     10010,
+		"-1.234,56€":   -123456,
+		"+12.000,00 €": 1200000,
+		"1.250,00-€":   -125000,
+	}
+	for line, want := range tests {
+		text := "Landlord\nTransferencias salientes\nFecha de valor 01.10.2025\n02.10.2025\n" + line
+		transactions, err := parseTransactionsFromText(text, nil)
+		if err != nil {
+			t.Fatalf("parseTransactionsFromText(%q) failed: %v", line, err)
+		}
+		if len(transactions) != 1 {
+			t.Fatalf("parseTransactionsFromText(%q) = %+v, want one transaction", line, transactions)
+		}
+		got := transactions[0]
+		if got.Amount.Minor != want || got.Amount.Currency != "EUR" || got.PartnerName != "Landlord" || !got.BookingDate.Equal(statementDay("02.10.2025")) {
+			t.Errorf("parseTransactionsFromText(%q) = %+v, want Landlord on 02.10.2025 with %d EUR cents", line, got, want)
+		}
+	}
+
+	if transactions, _ := parseTransactionsFromText("Landlord\n02.10.2025\n1.23,45€", nil); len(transactions) != 0 {
+		t.Errorf("parseTransactionsFromText(misplaced separator) = %+v, want none", transactions)
+	}
+}
+
+func TestParseTransactionsFromTextDotDecimalLines(t *testing.T) {
+	for _, line := range []string{"12.50", "01.10", "3.00 €"} {
+		text := "Landlord\nTransferencias salientes\nFecha de valor 01.10.2025\n02.10.2025\n" + line + "\n-500,00€"
+		transactions, err := parseTransactionsFromText(text, nil)
+		if err != nil || len(transactions) != 1 {
+			t.Fatalf("parseTransactionsFromText(%q inside the block) = %+v, %v, want one transaction", line, transactions, err)
+		}
+		if got := transactions[0]; got.Amount.Minor != -50000 || got.PartnerName != "Landlord" {
+			t.Errorf("parseTransactionsFromText(%q inside the block) = %+v, want Landlord with -500,00 EUR", line, got)
+		}
+	}
+}
+
+func TestParseTransactionsFromTextDates(t *testing.T) {
+	t.Setenv("N26_TIMEZONE", "Europe/Madrid")
+	text := "Landlord\nFecha de valor 30.09.2025\n01.10.2025\n-500,00€"
+	transactions, err := parseTransactionsFromText(text, nil)
+	if err != nil || len(transactions) != 1 {
+		t.Fatalf("parseTransactionsFromText() = %+v, %v, want one transaction", transactions, err)
+	}
+	madrid, _ := time.LoadLocation("Europe/Madrid")
+	got := transactions[0]
+	if want := time.Date(2025, time.October, 1, 0, 0, 0, 0, madrid); !got.BookingDate.Equal(want) || got.BookingDate.Location().String() != "Europe/Madrid" {
+		t.Errorf("BookingDate = %v, want midnight of 01.10.2025 in Madrid", got.BookingDate)
+	}
+	if want := time.Date(2025, time.September, 30, 0, 0, 0, 0, madrid); !got.ValueDate.Equal(want) {
+		t.Errorf("ValueDate = %v, want midnight of 30.09.2025 in Madrid", got.ValueDate)
+	}
+}
+
+func TestParseTransactionsFromTextOutsidePeriod(t *testing.T) {
+	period := &StatementPeriod{From: statementDay("01.10.2025"), To: statementDay("31.10.2025")}
+	for block, want := range map[string]int{
+		"Landlord\n01.10.2025\n-500,00€":  1,
+		"Coffee Shop\n31.10.2025\n-2,50€": 1,
+		"Misprint\n01.11.2024\n-9,99€":    0,
+	} {
+		transactions, err := parseTransactionsFromText(block, period)
+		if err != nil {
+			t.Fatalf("parseTransactionsFromText(%q) failed: %v", block, err)
+		}
+		if len(transactions) != want {
+			t.Errorf("parseTransactionsFromText(%q) = %+v, want %d transactions", block, transactions, want)
+		}
+	}
+}
+
+func TestParsePeriodFromText(t *testing.T) {
+	tests := []string{
+		"Actividad de la cuenta\n01.10.2025 hasta 31.10.2025\n",
+		"Account statement\n01.10.2025 until 31.10.2025",
+		"Kontoauszug 01.10.2025 - 31.10.2025",
+		"Extracto 01.10.2025 – 31.10.2025",
+	}
+	for _, text := range tests {
+		period, err := parsePeriodFromText(text)
+		if err != nil {
+			t.Errorf("parsePeriodFromText(%q) failed: %v", text, err)
+			continue
+		}
+		if !period.From.Equal(statementDay("01.10.2025")) || !period.To.Equal(statementDay("31.10.2025")) {
+			t.Errorf("parsePeriodFromText(%q) = %v – %v, want October 2025", text, period.From, period.To)
+		}
+	}
+
+	for _, text := range []string{
+		"Landlord\n01.10.2025\n-500,00€",
+		"31.10.2025 - 01.10.2025",
+		"32.10.2025 - 31.11.2025",
+		"Hotel Booking\nStay 01.09.2025 - 05.09.2025\n06.09.2025\n-300,00€",
+		"Landlord\n01.10.2025\n-500,00€\n01.09.2025 - 30.09.2025",
+	} {
+		if period, err := parsePeriodFromText(text); err == nil {
+			t.Errorf("parsePeriodFromText(%q) = %+v, want error", text, period)
+		}
+	}
+}
+
+func TestParsePeriodFromTextIgnoresDateRangesInTheBody(t *testing.T) {
+	text := "Actividad de la cuenta\n01.10.2025 hasta 31.10.2025\n\n" +
+		"Hotel Booking\nStay 01.09.2025 - 05.09.2025\n02.10.2025\n-300,00€\n" +
+		"Insurance\n01.01.2026 - 31.12.2026\n03.10.2025\n-120,00€"
+	period, err := parsePeriodFromText(text)
+	if err != nil {
+		t.Fatalf("parsePeriodFromText() failed: %v", err)
+	}
+	if !period.From.Equal(statementDay("01.10.2025")) || !period.To.Equal(statementDay("31.10.2025")) {
+		t.Errorf("parsePeriodFromText() = %v – %v, want the header's October 2025", period.From, period.To)
+	}
+
+	transactions, err := parseTransactionsFromText(text, &period)
+	if err != nil || len(transactions) != 2 {
+		t.Errorf("parseTransactionsFromText() = %+v, %v, want both transactions within the header's period", transactions, err)
+	}
+}
+
+func TestStatementPeriodContains(t *testing.T) {
+	period := StatementPeriod{From: statementDay("01.10.2025"), To: statementDay("31.10.2025")}
+	for date, want := range map[string]bool{"30.09.2025": false, "01.10.2025": true, "31.10.2025": true, "01.11.2025": false} {
+		if got := period.contains(statementDay(date)); got != want {
+			t.Errorf("contains(%s) = %v, want %v", date, got, want)
+		}
+	}
+}
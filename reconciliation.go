@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// Reconciliation cross-checks a statement: the previous balance plus the parsed
+// transactions should add up to the new balance
+type Reconciliation struct {
+	Opening      Money // Previous balance
+	Closing      Money // New balance
+	Transactions Money // Sum of the parsed amounts
+	Expected     Money // Previous balance plus the parsed amounts
+	Difference   Money // How much of the balance change the parsed transactions do not account for
+	Count        int   // Number of parsed transactions
+}
+
+// Balanced tells whether the transactions account for the balance change to the cent
+func (r Reconciliation) Balanced() bool {
+	return r.Difference.IsZero()
+}
+
+// reconcileStatement sums the transactions of a statement against its balances. It fails when
+// a balance is missing or an amount is in another currency, as nothing can be cross-checked then.
+func reconcileStatement(statement *ParsedStatement) (Reconciliation, error) {
+	if statement.Opening == nil || statement.Balance == nil {
+		return Reconciliation{}, fmt.Errorf("statement has no previous and new balance")
+	}
+
+	r := Reconciliation{
+		Opening:      statement.Opening.Balance,
+		Closing:      statement.Balance.Balance,
+		Transactions: Money{Currency: statement.Balance.Balance.Currency},
+		Count:        len(statement.Transactions),
+	}
+	var err error
+	for _, t := range statement.Transactions {
+		if r.Transactions, err = r.Transactions.Add(t.Amount); err != nil {
+			return Reconciliation{}, err
+		}
+	}
+	if r.Expected, err = r.Opening.Add(r.Transactions); err != nil {
+		return Reconciliation{}, err
+	}
+	if r.Difference, err = r.Closing.Add(r.Expected.Neg()); err != nil {
+		return Reconciliation{}, err
+	}
+	return r, nil
+}
+
+// describeDiscrepancy explains a reconciliation that does not add up
+func describeDiscrepancy(r Reconciliation) string {
+	return fmt.Sprintf("previous balance %s + %d transactions %s = %s, but the new balance is %s: %s unaccounted for, a transaction may be missing or misread",
+		r.Opening, r.Count, r.Transactions, r.Expected, r.Closing, r.Difference)
+}
+
+// reconciliationAlertItems reports a statement whose transactions do not add up to its balances,
+// keyed by the document so the same PDF is reported once
+func reconciliationAlertItems(statement *ParsedStatement, r Reconciliation) []AlertItem {
+	if r.Balanced() {
+		return nil
+	}
+	return []AlertItem{{
+		Key: "integrity|" + statement.Document.SHA256,
+		Line: fmt.Sprintf("**Statement %s – %s**: %s", statement.Document.PeriodStart.Format(statementDateLayout),
+			statement.Document.PeriodEnd.Format(statementDateLayout), describeDiscrepancy(r)),
+	}}
+}
+
+// checkReconciliation warns every channel when the parsed transactions do not account for the
+// balance change of the statement, the best sign that the parser missed or invented a transaction
+func checkReconciliation(statement *ParsedStatement, alertRepo AlertRepository, notifiers []Notifier) error {
+	r, err := reconcileStatement(statement)
+	if err != nil {
+		log.Printf("Warning: Could not reconcile statement: %v", err)
+		return nil
+	}
+	if r.Balanced() {
+		fmt.Printf("Statement reconciled: %d transactions account for the balance change\n", r.Count)
+		return nil
+	}
+
+	log.Printf("Warning: Parse integrity: %s", describeDiscrepancy(r))
+	return sendAlerts(alertRepo, notifiers, AlertIntegrity, "🧮 N26 Statement Does Not Add Up", reconciliationAlertItems(statement, r))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseOpeningBalanceFromText(t *testing.T) {
+	text := "Resumen\nSaldo previo\n+1.000,00€\nSalidas\n-250,50€\nEntradas\n+100,00€\nTu nuevo saldo\n+849,50€"
+	opening, err := parseOpeningBalanceFromText(text)
+	if err != nil || opening.Balance != eur("1000,00") {
+		t.Errorf("parseOpeningBalanceFromText() = %+v, %v, want +1.000,00", opening, err)
+	}
+	closing, err := parseBalanceFromText(text)
+	if err != nil || closing.Balance != eur("849,50") {
+		t.Errorf("parseBalanceFromText() = %+v, %v, want +849,50", closing, err)
+	}
+
+	if _, err := parseOpeningBalanceFromText("Your new balance\n10,00€"); err == nil {
+		t.Error("parseOpeningBalanceFromText() without a previous balance succeeded, want an error")
+	}
+}
+
+func TestReconcileStatement(t *testing.T) {
+	statement := &ParsedStatement{
+		Document: StatementDocument{SHA256: "abc", PeriodStart: time.Date(2025, 9, 20, 0, 0, 0, 0, time.UTC), PeriodEnd: time.Date(2025, 10, 20, 0, 0, 0, 0, time.UTC)},
+		Opening:  &AccountBalance{Balance: eur("+1.000,00")},
+		Balance:  &AccountBalance{Balance: eur("+849,50")},
+		Transactions: []Transaction{
+			{BookingDate: statementDay("01.10.2025"), PartnerName: "Supermarket", Amount: eur("-250,50")},
+			{BookingDate: statementDay("02.10.2025"), PartnerName: "Cafe", Amount: eur("-0,10")},
+			{BookingDate: statementDay("03.10.2025"), PartnerName: "Friend", Amount: eur("+100,10")},
+		},
+	}
+
+	r, err := reconcileStatement(statement)
+	if err != nil {
+		t.Fatalf("reconcileStatement() failed: %v", err)
+	}
+	if !r.Balanced() || r.Count != 3 {
+		t.Errorf("reconcileStatement() = %+v, want 3 balanced transactions", r)
+	}
+	if items := reconciliationAlertItems(statement, r); len(items) != 0 {
+		t.Errorf("reconciliationAlertItems() = %+v, want none for a balanced statement", items)
+	}
+
+	// The parser missed the cafe
+	statement.Transactions = append(statement.Transactions[:1], statement.Transactions[2:]...)
+	r, err = reconcileStatement(statement)
+	if err != nil {
+		t.Fatalf("reconcileStatement() failed: %v", err)
+	}
+	if r.Balanced() || r.Difference.Minor != -10 {
+		t.Errorf("Difference = %s, want -0,10 EUR", r.Difference)
+	}
+	items := reconciliationAlertItems(statement, r)
+	if len(items) != 1 || items[0].Key != "integrity|abc" || !strings.Contains(items[0].Line, "= 849,60 EUR, but the new balance is 849,50 EUR: -0,10 EUR unaccounted for") {
+		t.Errorf("reconciliationAlertItems() = %+v, want the -0,10 EUR discrepancy of document abc", items)
+	}
+
+	statement.Transactions = append(statement.Transactions, Transaction{BookingDate: statementDay("04.10.2025"), PartnerName: "Shop", Amount: eur("$0,10")})
+	if r, err := reconcileStatement(statement); err == nil {
+		t.Errorf("reconcileStatement() with a USD amount = %+v, want an error", r)
+	}
+
+	statement.Opening = nil
+	if _, err := reconcileStatement(statement); err == nil {
+		t.Error("reconcileStatement() without a previous balance succeeded, want an error")
+	}
+}
@@ -0,0 +1,1481 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// repositoryBackend creates fresh, empty repositories for one storage backend
+type repositoryBackend struct {
+	name    string
+	newRepo func(t testing.TB) *Storage
+}
+
+// repositoryBackends lists every backend the contract suite runs against.
+// PostgreSQL only runs when TEST_DB_CONN points at a disposable database.
+func repositoryBackends() []repositoryBackend {
+	backends := []repositoryBackend{
+		{name: "memory", newRepo: func(t testing.TB) *Storage {
+			storage, err := OpenStorage("memory://")
+			if err != nil {
+				t.Fatalf("failed to open memory storage: %v", err)
+			}
+			return storage
+		}},
+		{name: "sqlite", newRepo: func(t testing.TB) *Storage {
+			return openTestStorage(t, "sqlite://"+filepath.Join(t.TempDir(), "n26.db"))
+		}},
+	}
+
+	if conn := os.Getenv("TEST_DB_CONN"); conn != "" {
+		backends = append(backends, repositoryBackend{name: "postgres", newRepo: func(t testing.TB) *Storage {
+			storage := openTestStorage(t, conn)
+			truncateTestTables(t, storage.DB)
+			return storage
+		}})
+	}
+
+	return backends
+}
+
+// openTestStorage opens a storage backend
+func openTestStorage(t testing.TB, conn string) *Storage {
+	t.Helper()
+	storage, err := OpenStorage(conn)
+	if err != nil {
+		t.Fatalf("failed to open storage: %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+	return storage
+}
+
+// truncateTestTables empties every table but the migration version so each test starts from a
+// clean database. The tables are listed from the database, so tables added by later migrations are not missed.
+func truncateTestTables(t testing.TB, db *sql.DB) {
+	t.Helper()
+	rows, err := db.Query(`
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_type = 'BASE TABLE' AND table_name <> 'schema_migrations'
+	`)
+	if err != nil {
+		t.Fatalf("failed to list tables: %v", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			t.Fatalf("failed to scan table: %v", err)
+		}
+		tables = append(tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("failed to list tables: %v", err)
+	}
+
+	if _, err := db.Exec(`TRUNCATE ` + strings.Join(tables, ", ") + ` RESTART IDENTITY`); err != nil {
+		t.Fatalf("failed to truncate tables: %v", err)
+	}
+}
+
+func TestCookieRepositoryContract(t *testing.T) {
+	for _, backend := range repositoryBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			testCookieRepositoryContract(t, func(t *testing.T) CookieRepository {
+				return backend.newRepo(t).Cookies
+			})
+		})
+	}
+}
+
+func TestStatementRepositoryContract(t *testing.T) {
+	for _, backend := range repositoryBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			testStatementRepositoryContract(t, func(t *testing.T) StatementRepository {
+				return backend.newRepo(t).Statements
+			})
+		})
+	}
+}
+
+func TestTransactionRepositoryContract(t *testing.T) {
+	for _, backend := range repositoryBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			testTransactionRepositoryContract(t, func(t *testing.T) TransactionRepository {
+				return backend.newRepo(t).Transactions
+			})
+		})
+	}
+}
+
+func TestDeliveryRepositoryContract(t *testing.T) {
+	for _, backend := range repositoryBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			testDeliveryRepositoryContract(t, backend.newRepo)
+		})
+	}
+}
+
+func TestCategoryRuleRepositoryContract(t *testing.T) {
+	for _, backend := range repositoryBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			testCategoryRuleRepositoryContract(t, func(t *testing.T) CategoryRuleRepository {
+				return backend.newRepo(t).Rules
+			})
+		})
+	}
+}
+
+func TestBudgetRepositoryContract(t *testing.T) {
+	for _, backend := range repositoryBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			testBudgetRepositoryContract(t, func(t *testing.T) BudgetRepository {
+				return backend.newRepo(t).Budgets
+			})
+		})
+	}
+}
+
+func TestAlertRepositoryContract(t *testing.T) {
+	for _, backend := range repositoryBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			testAlertRepositoryContract(t, func(t *testing.T) AlertRepository {
+				return backend.newRepo(t).Alerts
+			})
+		})
+	}
+}
+
+func TestSubscriptionRepositoryContract(t *testing.T) {
+	for _, backend := range repositoryBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			testSubscriptionRepositoryContract(t, func(t *testing.T) SubscriptionRepository {
+				return backend.newRepo(t).Subscriptions
+			})
+		})
+	}
+}
+
+func TestBalanceRepositoryContract(t *testing.T) {
+	for _, backend := range repositoryBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			testBalanceRepositoryContract(t, func(t *testing.T) BalanceRepository {
+				return backend.newRepo(t).Balances
+			})
+		})
+	}
+}
+
+func TestDigestRepositoryContract(t *testing.T) {
+	for _, backend := range repositoryBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			testDigestRepositoryContract(t, func(t *testing.T) DigestRepository {
+				return backend.newRepo(t).Digests
+			})
+		})
+	}
+}
+
+func TestSpaceRepositoryContract(t *testing.T) {
+	for _, backend := range repositoryBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			testSpaceRepositoryContract(t, func(t *testing.T) SpaceRepository {
+				return backend.newRepo(t).Spaces
+			})
+		})
+	}
+}
+
+func TestRetentionRepositoryContract(t *testing.T) {
+	for _, backend := range repositoryBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			if backend.name == "memory" {
+				t.Skip("memory storage is not persisted, there is nothing to purge")
+			}
+			testRetentionRepositoryContract(t, backend.newRepo)
+		})
+	}
+}
+
+func TestStorageIsolatesAccounts(t *testing.T) {
+	for _, backend := range repositoryBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			storage := backend.newRepo(t)
+			alice, err := storage.ForAccount("alice")
+			if err != nil {
+				t.Fatalf("ForAccount(alice) error: %v", err)
+			}
+			bob, err := storage.ForAccount("bob")
+			if err != nil {
+				t.Fatalf("ForAccount(bob) error: %v", err)
+			}
+
+			if err := alice.Cookies.Save("session=alice"); err != nil {
+				t.Fatalf("Save() failed: %v", err)
+			}
+			if _, err := bob.Cookies.Get(); err == nil {
+				t.Error("Get() of another account succeeded, want error")
+			}
+
+			record := StatementRecord{Key: "shared-key", KeyVersion: statementKeyVersion}
+			if err := alice.Statements.MarkMultipleAsNotified([]StatementRecord{record}); err != nil {
+				t.Fatalf("MarkMultipleAsNotified() failed: %v", err)
+			}
+			if notified, err := bob.Statements.IsNotified(record.Key); err != nil || notified {
+				t.Errorf("IsNotified() of another account = %v, %v, want false", notified, err)
+			}
+			if err := bob.Statements.MarkMultipleAsNotified([]StatementRecord{record}); err != nil {
+				t.Fatalf("MarkMultipleAsNotified() with a key of another account failed: %v", err)
+			}
+
+			for account, limit := range map[*Storage]Money{alice: eur("100,00"), bob: eur("250,00")} {
+				if err := account.Budgets.SetBudget(Budget{Category: "Groceries", MonthlyLimit: limit}); err != nil {
+					t.Fatalf("SetBudget() failed: %v", err)
+				}
+			}
+			budgets, err := alice.Budgets.ListBudgets()
+			if err != nil {
+				t.Fatalf("ListBudgets() failed: %v", err)
+			}
+			if len(budgets) != 1 || budgets[0].MonthlyLimit != eur("100,00") {
+				t.Errorf("ListBudgets() = %+v, want only the account's own budget", budgets)
+			}
+
+			again, err := storage.ForAccount("alice")
+			if err != nil {
+				t.Fatalf("ForAccount(alice) error: %v", err)
+			}
+			if got := cookieValue(t, again.Cookies); got != "session=alice" {
+				t.Errorf("Get() after reopening the account = %q, want %q", got, "session=alice")
+			}
+		})
+	}
+}
+
+// cookieValue strips the TIMESTAMP prefix that Get adds to the stored value
+func cookieValue(t *testing.T, repo CookieRepository) string {
+	t.Helper()
+	cookie, err := repo.Get()
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if !strings.HasPrefix(cookie, "TIMESTAMP=") {
+		t.Fatalf("Get() = %q, want TIMESTAMP prefix", cookie)
+	}
+	return normalizeCookie(cookie)
+}
+
+// testCookieRepositoryContract is the behaviour every CookieRepository must provide
+func testCookieRepositoryContract(t *testing.T, newRepo func(t *testing.T) CookieRepository) {
+	t.Run("get on empty repository fails", func(t *testing.T) {
+		repo := newRepo(t)
+		if _, err := repo.Get(); err == nil {
+			t.Fatal("Get() on empty repository succeeded, want error")
+		}
+	})
+
+	t.Run("get returns the most recently saved cookie", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, cookie := range []string{"session=first", "session=second", "session=third"} {
+			if err := repo.Save(cookie); err != nil {
+				t.Fatalf("Save(%q) failed: %v", cookie, err)
+			}
+		}
+		if got := cookieValue(t, repo); got != "session=third" {
+			t.Errorf("Get() = %q, want %q", got, "session=third")
+		}
+	})
+
+	t.Run("save normalizes the cookie", func(t *testing.T) {
+		repo := newRepo(t)
+		if err := repo.Save("  TIMESTAMP=2025-01-01T00:00:00Z; session=abc\n"); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		if got := cookieValue(t, repo); got != "session=abc" {
+			t.Errorf("Get() = %q, want %q", got, "session=abc")
+		}
+	})
+
+	t.Run("sessions track validation and invalidation of the latest cookie", func(t *testing.T) {
+		repo := newRepo(t)
+		if err := repo.Save("session=old"); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		if err := repo.MarkValidated(); err != nil {
+			t.Fatalf("MarkValidated() failed: %v", err)
+		}
+		if err := repo.MarkInvalid(); err != nil {
+			t.Fatalf("MarkInvalid() failed: %v", err)
+		}
+		if err := repo.Save("session=new"); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		if err := repo.MarkValidated(); err != nil {
+			t.Fatalf("MarkValidated() failed: %v", err)
+		}
+
+		sessions, err := repo.Sessions()
+		if err != nil {
+			t.Fatalf("Sessions() failed: %v", err)
+		}
+		if len(sessions) != 2 {
+			t.Fatalf("Sessions() returned %d sessions, want 2", len(sessions))
+		}
+
+		old, current := sessions[0], sessions[1]
+		if old.FirstUsedAt == nil || old.LastValidatedAt == nil || old.InvalidatedAt == nil {
+			t.Errorf("old session = %+v, want first used, validated and invalidated", old)
+		}
+		if current.FirstUsedAt == nil || current.LastValidatedAt == nil || current.InvalidatedAt != nil {
+			t.Errorf("current session = %+v, want first used and validated only", current)
+		}
+	})
+
+	t.Run("mark on empty repository is a no-op", func(t *testing.T) {
+		repo := newRepo(t)
+		if err := repo.MarkValidated(); err != nil {
+			t.Errorf("MarkValidated() failed: %v", err)
+		}
+		if err := repo.MarkInvalid(); err != nil {
+			t.Errorf("MarkInvalid() failed: %v", err)
+		}
+	})
+
+	t.Run("concurrent saves are all stored", func(t *testing.T) {
+		repo := newRepo(t)
+		const writers = 10
+
+		var wg sync.WaitGroup
+		errs := make(chan error, writers)
+		for i := range writers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- repo.Save(fmt.Sprintf("session=%d", i))
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("concurrent Save() failed: %v", err)
+			}
+		}
+
+		sessions, err := repo.Sessions()
+		if err != nil {
+			t.Fatalf("Sessions() failed: %v", err)
+		}
+		if len(sessions) != writers {
+			t.Errorf("Sessions() returned %d sessions, want %d", len(sessions), writers)
+		}
+	})
+}
+
+// testStatementRepositoryContract is the behaviour every StatementRepository must provide
+func testStatementRepositoryContract(t *testing.T, newRepo func(t *testing.T) StatementRepository) {
+	t.Run("missing key is not notified", func(t *testing.T) {
+		repo := newRepo(t)
+		notified, err := repo.IsNotified(generateStatementKey(statementDay("01.01.2025"), "Nobody", eur("-1,00"), 1))
+		if err != nil {
+			t.Fatalf("IsNotified() failed: %v", err)
+		}
+		if notified {
+			t.Error("IsNotified() of missing key = true, want false")
+		}
+	})
+
+	t.Run("marked keys are notified", func(t *testing.T) {
+		repo := newRepo(t)
+		records := statementRecords([]Transaction{
+			{BookingDate: statementDay("01.10.2025"), PartnerName: "Coffee Shop", Amount: eur("-2,50")},
+			{BookingDate: statementDay("02.10.2025"), PartnerName: "Supermarket", Amount: eur("-45,10")},
+		})
+		if err := repo.MarkMultipleAsNotified(records); err != nil {
+			t.Fatalf("MarkMultipleAsNotified() failed: %v", err)
+		}
+
+		for _, record := range records {
+			key := record.Key
+			notified, err := repo.IsNotified(key)
+			if err != nil {
+				t.Fatalf("IsNotified(%q) failed: %v", key, err)
+			}
+			if !notified {
+				t.Errorf("IsNotified(%q) = false, want true", key)
+			}
+		}
+
+		other := generateStatementKey(statementDay("03.10.2025"), "Coffee Shop", eur("-2,50"), 1)
+		if notified, err := repo.IsNotified(other); err != nil || notified {
+			t.Errorf("IsNotified(%q) = %v, %v, want false, nil", other, notified, err)
+		}
+	})
+
+	t.Run("filter returns unnotified keys in order", func(t *testing.T) {
+		repo := newRepo(t)
+		records := statementRecords([]Transaction{
+			{BookingDate: statementDay("01.10.2025"), PartnerName: "Coffee Shop", Amount: eur("-2,50")},
+			{BookingDate: statementDay("02.10.2025"), PartnerName: "Supermarket", Amount: eur("-45,10")},
+			{BookingDate: statementDay("03.10.2025"), PartnerName: "Bakery", Amount: eur("-3,00")},
+		})
+		if err := repo.MarkMultipleAsNotified(records[1:2]); err != nil {
+			t.Fatalf("MarkMultipleAsNotified() failed: %v", err)
+		}
+
+		keys := recordKeys(records)
+		unnotified, err := repo.FilterUnnotified(keys)
+		if err != nil {
+			t.Fatalf("FilterUnnotified() failed: %v", err)
+		}
+		if len(unnotified) != 2 || unnotified[0] != keys[0] || unnotified[1] != keys[2] {
+			t.Errorf("FilterUnnotified() = %q, want [%q %q]", unnotified, keys[0], keys[2])
+		}
+
+		if unnotified, err := repo.FilterUnnotified(nil); err != nil || len(unnotified) != 0 {
+			t.Errorf("FilterUnnotified(nil) = %q, %v, want none", unnotified, err)
+		}
+	})
+
+	t.Run("marking more rows than one insert holds", func(t *testing.T) {
+		repo := newRepo(t)
+		records := benchmarkStatementRecords(postgresStatementBatchSize*2 + 1)
+		if err := repo.MarkMultipleAsNotified(records); err != nil {
+			t.Fatalf("MarkMultipleAsNotified() failed: %v", err)
+		}
+		unnotified, err := repo.FilterUnnotified(recordKeys(records))
+		if err != nil || len(unnotified) != 0 {
+			t.Errorf("FilterUnnotified() = %d keys, %v, want none", len(unnotified), err)
+		}
+	})
+
+	t.Run("marking is idempotent", func(t *testing.T) {
+		repo := newRepo(t)
+		record := statementRecords([]Transaction{{BookingDate: statementDay("01.10.2025"), PartnerName: "Coffee Shop", Amount: eur("-2,50")}})[0]
+		key := record.Key
+		for range 3 {
+			if err := repo.MarkMultipleAsNotified([]StatementRecord{record, record}); err != nil {
+				t.Fatalf("MarkMultipleAsNotified() failed: %v", err)
+			}
+		}
+		if notified, err := repo.IsNotified(key); err != nil || !notified {
+			t.Errorf("IsNotified() = %v, %v, want true, nil", notified, err)
+		}
+	})
+
+	t.Run("marking nothing succeeds", func(t *testing.T) {
+		repo := newRepo(t)
+		if err := repo.MarkMultipleAsNotified(nil); err != nil {
+			t.Errorf("MarkMultipleAsNotified(nil) failed: %v", err)
+		}
+	})
+
+	t.Run("concurrent overlapping marks", func(t *testing.T) {
+		repo := newRepo(t)
+		const workers = 8
+		const keysPerWorker = 20
+
+		var wg sync.WaitGroup
+		errs := make(chan error, workers)
+		for w := range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var records []StatementRecord
+				// Workers overlap on half of their keys
+				for i := range keysPerWorker {
+					records = append(records, StatementRecord{
+						Key:         fmt.Sprintf("key-%d", w*keysPerWorker/2+i),
+						KeyVersion:  statementKeyVersion,
+						BookingDate: statementDay("01.10.2025"),
+						PartnerName: "Coffee Shop",
+						Amount:      eur("-2,50"),
+					})
+				}
+				errs <- repo.MarkMultipleAsNotified(records)
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("concurrent MarkMultipleAsNotified() failed: %v", err)
+			}
+		}
+
+		total := (workers + 1) * keysPerWorker / 2
+		for i := range total {
+			key := fmt.Sprintf("key-%d", i)
+			if notified, err := repo.IsNotified(key); err != nil || !notified {
+				t.Errorf("IsNotified(%q) = %v, %v, want true, nil", key, notified, err)
+			}
+		}
+	})
+
+	t.Run("outdated statements are found by space, date and amount", func(t *testing.T) {
+		repo := newRepo(t)
+		records := []StatementRecord{
+			{Key: "old-coffee", KeyVersion: 1, BookingDate: statementDay("01.10.2025"), PartnerName: "COFFEE SHOP", Amount: eur("-2,50")},
+			{Key: "old-other-day", KeyVersion: 1, BookingDate: statementDay("02.10.2025"), PartnerName: "COFFEE SHOP", Amount: eur("-2,50")},
+			{Key: "old-other-amount", KeyVersion: 1, BookingDate: statementDay("01.10.2025"), PartnerName: "COFFEE SHOP", Amount: eur("-2,51")},
+			{Key: "old-space-coffee", KeyVersion: 1, BookingDate: statementDay("01.10.2025"), PartnerName: "COFFEE SHOP", Amount: eur("-2,50"), Space: "space-1"},
+			{Key: "current-coffee", KeyVersion: statementKeyVersion, BookingDate: statementDay("01.10.2025"), PartnerName: "Coffee Shop", Amount: eur("-2,50")},
+		}
+		if err := repo.MarkMultipleAsNotified(records); err != nil {
+			t.Fatalf("MarkMultipleAsNotified() failed: %v", err)
+		}
+
+		outdated, err := repo.FindOutdated("", statementDay("01.10.2025"), eur("-2,50"), statementKeyVersion)
+		if err != nil {
+			t.Fatalf("FindOutdated() failed: %v", err)
+		}
+		if len(outdated) != 1 || outdated[0] != records[0] {
+			t.Errorf("FindOutdated() = %+v, want [%+v]", outdated, records[0])
+		}
+	})
+
+	t.Run("rekey moves a statement to its new key", func(t *testing.T) {
+		repo := newRepo(t)
+		old := StatementRecord{Key: "old-coffee", KeyVersion: 1, BookingDate: statementDay("01.10.2025"), PartnerName: "COFFEE SHOP", Amount: eur("-2,50")}
+		if err := repo.MarkMultipleAsNotified([]StatementRecord{old}); err != nil {
+			t.Fatalf("MarkMultipleAsNotified() failed: %v", err)
+		}
+
+		current := statementRecords([]Transaction{{BookingDate: statementDay("01.10.2025"), PartnerName: "Coffee Shop", Amount: eur("-2,50")}})[0]
+		if err := repo.Rekey(old.Key, current); err != nil {
+			t.Fatalf("Rekey() failed: %v", err)
+		}
+
+		if notified, err := repo.IsNotified(current.Key); err != nil || !notified {
+			t.Errorf("IsNotified(new key) = %v, %v, want true, nil", notified, err)
+		}
+		if notified, err := repo.IsNotified(old.Key); err != nil || notified {
+			t.Errorf("IsNotified(old key) = %v, %v, want false, nil", notified, err)
+		}
+		if outdated, err := repo.FindOutdated("", statementDay("01.10.2025"), eur("-2,50"), statementKeyVersion); err != nil || len(outdated) != 0 {
+			t.Errorf("FindOutdated() after rekey = %+v, %v, want none", outdated, err)
+		}
+	})
+}
+
+// testTransactionRepositoryContract is the behaviour every TransactionRepository must provide
+func testTransactionRepositoryContract(t *testing.T, newRepo func(t *testing.T) TransactionRepository) {
+	october := StatementPeriod{
+		From: time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2025, 10, 31, 0, 0, 0, 0, time.UTC),
+	}
+	document := StatementDocument{SHA256: strings.Repeat("a", 64), PeriodStart: october.From, PeriodEnd: october.To, SizeBytes: 1234}
+	transactions := []Transaction{
+		{BookingDate: statementDay("02.10.2025"), ValueDate: statementDay("01.10.2025"), PartnerName: "Coffee Shop", Amount: eur("-2,50"), RawText: "Coffee Shop\n02.10.2025\n-2,50€"},
+		{BookingDate: statementDay("01.10.2025"), ValueDate: statementDay("01.10.2025"), PartnerName: "Employer", Amount: eur("1500,00")},
+		{BookingDate: statementDay("05.11.2025"), ValueDate: statementDay("05.11.2025"), PartnerName: "Supermarket", Amount: eur("-45,10")},
+	}
+
+	t.Run("saving the same document twice returns the same id", func(t *testing.T) {
+		repo := newRepo(t)
+		first, err := repo.SaveDocument(document)
+		if err != nil {
+			t.Fatalf("SaveDocument() failed: %v", err)
+		}
+		second, err := repo.SaveDocument(document)
+		if err != nil {
+			t.Fatalf("SaveDocument() failed: %v", err)
+		}
+		if first == 0 || first != second {
+			t.Errorf("SaveDocument() ids = %d, %d, want the same non-zero id", first, second)
+		}
+	})
+
+	t.Run("recorded transactions are listed by booking date", func(t *testing.T) {
+		repo := newRepo(t)
+		documentID, err := repo.SaveDocument(document)
+		if err != nil {
+			t.Fatalf("SaveDocument() failed: %v", err)
+		}
+		if err := repo.RecordTransactions(documentID, transactions); err != nil {
+			t.Fatalf("RecordTransactions() failed: %v", err)
+		}
+
+		stored, err := repo.ListTransactions(october.From, october.To)
+		if err != nil {
+			t.Fatalf("ListTransactions() failed: %v", err)
+		}
+		if len(stored) != 2 {
+			t.Fatalf("ListTransactions() returned %d transactions, want 2", len(stored))
+		}
+
+		employer, coffee := stored[0], stored[1]
+		if employer.PartnerName != "Employer" || employer.Amount != eur("1500,00") {
+			t.Errorf("first transaction = %+v, want Employer 1500,00", employer)
+		}
+		if coffee.PartnerName != "Coffee Shop" || coffee.Amount != eur("-2,50") {
+			t.Errorf("second transaction = %+v, want Coffee Shop -2,50 EUR", coffee)
+		}
+		if !coffee.ValueDate.Equal(statementDay("01.10.2025")) || coffee.RawText != transactions[0].RawText {
+			t.Errorf("second transaction = %+v, want value date and raw text preserved", coffee)
+		}
+		if coffee.DocumentID != documentID {
+			t.Errorf("DocumentID = %d, want %d", coffee.DocumentID, documentID)
+		}
+		if coffee.Key != generateStatementKey(statementDay("02.10.2025"), "Coffee Shop", eur("-2,50"), 1) {
+			t.Errorf("Key = %q, want the statement key", coffee.Key)
+		}
+	})
+
+	t.Run("recording again keeps first seen and refreshes last seen", func(t *testing.T) {
+		repo := newRepo(t)
+		if err := repo.RecordTransactions(0, transactions[:1]); err != nil {
+			t.Fatalf("RecordTransactions() failed: %v", err)
+		}
+		before, err := repo.ListTransactions(october.From, october.To)
+		if err != nil || len(before) != 1 {
+			t.Fatalf("ListTransactions() = %d transactions, %v, want 1", len(before), err)
+		}
+
+		time.Sleep(1100 * time.Millisecond)
+		if err := repo.RecordTransactions(0, transactions[:1]); err != nil {
+			t.Fatalf("RecordTransactions() failed: %v", err)
+		}
+		after, err := repo.ListTransactions(october.From, october.To)
+		if err != nil || len(after) != 1 {
+			t.Fatalf("ListTransactions() = %d transactions, %v, want 1", len(after), err)
+		}
+
+		if !after[0].FirstSeenAt.Equal(before[0].FirstSeenAt) {
+			t.Errorf("FirstSeenAt changed from %v to %v", before[0].FirstSeenAt, after[0].FirstSeenAt)
+		}
+		if !after[0].LastSeenAt.After(before[0].LastSeenAt) {
+			t.Errorf("LastSeenAt = %v, want after %v", after[0].LastSeenAt, before[0].LastSeenAt)
+		}
+	})
+
+	t.Run("invalid transactions are rejected", func(t *testing.T) {
+		repo := newRepo(t)
+		invalid := []Transaction{transactions[0], {PartnerName: "Broken", Amount: eur("-1,00")}}
+		if err := repo.RecordTransactions(0, invalid); err == nil {
+			t.Fatal("RecordTransactions() without a booking date succeeded, want error")
+		}
+
+		stored, err := repo.ListTransactions(october.From, october.To)
+		if err != nil {
+			t.Fatalf("ListTransactions() failed: %v", err)
+		}
+		if len(stored) != 0 {
+			t.Errorf("ListTransactions() returned %d transactions after a failed batch, want 0", len(stored))
+		}
+	})
+	t.Run("rekey keeps the first seen timestamp", func(t *testing.T) {
+		repo := newRepo(t)
+		if err := repo.RecordTransactions(0, transactions[:1]); err != nil {
+			t.Fatalf("RecordTransactions() failed: %v", err)
+		}
+		before, err := repo.ListTransactions(october.From, october.To)
+		if err != nil || len(before) != 1 {
+			t.Fatalf("ListTransactions() = %d transactions, %v, want 1", len(before), err)
+		}
+
+		newKey := generateStatementKey(statementDay("02.10.2025"), "Coffee Shop GmbH", eur("-2,50"), 1)
+		if err := repo.RekeyTransaction(before[0].Key, newKey); err != nil {
+			t.Fatalf("RekeyTransaction() failed: %v", err)
+		}
+		after, err := repo.ListTransactions(october.From, october.To)
+		if err != nil || len(after) != 1 {
+			t.Fatalf("ListTransactions() = %d transactions, %v, want 1", len(after), err)
+		}
+		if after[0].Key != newKey || !after[0].FirstSeenAt.Equal(before[0].FirstSeenAt) {
+			t.Errorf("rekeyed transaction = %+v, want key %q and first seen %v", after[0], newKey, before[0].FirstSeenAt)
+		}
+	})
+
+	t.Run("categories are stored and updated", func(t *testing.T) {
+		repo := newRepo(t)
+		categorized := []Transaction{transactions[0], transactions[1]}
+		categorized[0].Category = "Restaurants"
+		if err := repo.RecordTransactions(0, categorized); err != nil {
+			t.Fatalf("RecordTransactions() failed: %v", err)
+		}
+
+		// Recording without a category keeps the stored one
+		if err := repo.RecordTransactions(0, transactions[:1]); err != nil {
+			t.Fatalf("RecordTransactions() failed: %v", err)
+		}
+		stored, err := repo.ListTransactions(october.From, october.To)
+		if err != nil || len(stored) != 2 {
+			t.Fatalf("ListTransactions() = %d transactions, %v, want 2", len(stored), err)
+		}
+		if stored[0].Category != "" || stored[1].Category != "Restaurants" {
+			t.Errorf("categories = %q, %q, want \"\", Restaurants", stored[0].Category, stored[1].Category)
+		}
+
+		if err := repo.UpdateCategories(map[string]string{stored[0].Key: "Income", stored[1].Key: "Groceries"}); err != nil {
+			t.Fatalf("UpdateCategories() failed: %v", err)
+		}
+		stored, err = repo.ListTransactions(october.From, october.To)
+		if err != nil || len(stored) != 2 {
+			t.Fatalf("ListTransactions() = %d transactions, %v, want 2", len(stored), err)
+		}
+		if stored[0].Category != "Income" || stored[1].Category != "Groceries" {
+			t.Errorf("categories = %q, %q, want Income, Groceries", stored[0].Category, stored[1].Category)
+		}
+	})
+
+	t.Run("transactions keep their space", func(t *testing.T) {
+		repo := newRepo(t)
+		savings := slices.Clone(transactions[:1])
+		savings[0].Space = "space-savings"
+		spaceDocument := document
+		spaceDocument.SHA256, spaceDocument.Space = strings.Repeat("b", 64), "space-savings"
+		documentID, err := repo.SaveDocument(spaceDocument)
+		if err != nil {
+			t.Fatalf("SaveDocument() failed: %v", err)
+		}
+		if err := repo.RecordTransactions(documentID, savings); err != nil {
+			t.Fatalf("RecordTransactions() failed: %v", err)
+		}
+		mainID, err := repo.SaveDocument(document)
+		if err != nil {
+			t.Fatalf("SaveDocument() failed: %v", err)
+		}
+		if err := repo.RecordTransactions(mainID, transactions[:1]); err != nil {
+			t.Fatalf("RecordTransactions() failed: %v", err)
+		}
+
+		stored, err := repo.ListTransactions(october.From, october.To)
+		if err != nil {
+			t.Fatalf("ListTransactions() failed: %v", err)
+		}
+		if len(stored) != 2 {
+			t.Fatalf("ListTransactions() = %+v, want the same transaction in both spaces", stored)
+		}
+		if main := spaceTransactions(stored, ""); len(main) != 1 || main[0].DocumentID != mainID {
+			t.Errorf("main account transactions = %+v, want the one of the main statement", main)
+		}
+		if space := spaceTransactions(stored, "space-savings"); len(space) != 1 || space[0].DocumentID != documentID {
+			t.Errorf("space transactions = %+v, want the one of the space statement", space)
+		}
+	})
+}
+
+// testCategoryRuleRepositoryContract is the behaviour every CategoryRuleRepository must provide
+func testCategoryRuleRepositoryContract(t *testing.T, newRepo func(t *testing.T) CategoryRuleRepository) {
+	minAmount, maxAmount := eur("5,00"), eur("49,99")
+
+	t.Run("rules are listed by priority then id", func(t *testing.T) {
+		repo := newRepo(t)
+		rules := []CategoryRule{
+			{Category: "Rent", Priority: 100, PartnerPattern: `^landlord`, Sign: signDebit},
+			{Category: "Gym", Priority: 10, MinAmount: &minAmount, MaxAmount: &maxAmount, Keywords: []string{"fitness", "gym"}},
+			{Category: "Books", Priority: 100, Keywords: []string{"bookstore"}},
+		}
+		var ids []int64
+		for _, rule := range rules {
+			id, err := repo.AddRule(rule)
+			if err != nil {
+				t.Fatalf("AddRule() failed: %v", err)
+			}
+			ids = append(ids, id)
+		}
+
+		listed, err := repo.ListRules()
+		if err != nil {
+			t.Fatalf("ListRules() failed: %v", err)
+		}
+		if len(listed) != 3 {
+			t.Fatalf("ListRules() returned %d rules, want 3", len(listed))
+		}
+		if listed[0].ID != ids[1] || listed[1].ID != ids[0] || listed[2].ID != ids[2] {
+			t.Errorf("ListRules() order = %d, %d, %d, want %d, %d, %d",
+				listed[0].ID, listed[1].ID, listed[2].ID, ids[1], ids[0], ids[2])
+		}
+
+		gym := listed[0]
+		if gym.Category != "Gym" || gym.MinAmount == nil || *gym.MinAmount != minAmount || gym.MaxAmount == nil || *gym.MaxAmount != maxAmount {
+			t.Errorf("gym rule = %+v, want amounts %s-%s", gym, minAmount, maxAmount)
+		}
+		if strings.Join(gym.Keywords, ",") != "fitness,gym" || gym.PartnerPattern != "" || gym.Sign != "" {
+			t.Errorf("gym rule = %+v, want keywords fitness,gym and no other conditions", gym)
+		}
+		rent := listed[1]
+		if rent.PartnerPattern != `^landlord` || rent.Sign != signDebit || rent.MinAmount != nil || len(rent.Keywords) != 0 {
+			t.Errorf("rent rule = %+v, want partner pattern and sign only", rent)
+		}
+	})
+
+	t.Run("invalid rules are rejected", func(t *testing.T) {
+		repo := newRepo(t)
+		invalid := []CategoryRule{
+			{Category: "Nothing"},
+			{Category: "", Keywords: []string{"x"}},
+			{Category: "Broken", PartnerPattern: `(`},
+			{Category: "Sign", Sign: "both"},
+		}
+		for _, rule := range invalid {
+			if _, err := repo.AddRule(rule); err == nil {
+				t.Errorf("AddRule(%+v) succeeded, want error", rule)
+			}
+		}
+	})
+
+	t.Run("deleted rules are no longer listed", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.AddRule(CategoryRule{Category: "Books", Keywords: []string{"bookstore"}})
+		if err != nil {
+			t.Fatalf("AddRule() failed: %v", err)
+		}
+		if err := repo.DeleteRule(id); err != nil {
+			t.Fatalf("DeleteRule() failed: %v", err)
+		}
+		if err := repo.DeleteRule(id); err == nil {
+			t.Error("DeleteRule() of a deleted rule succeeded, want error")
+		}
+		listed, err := repo.ListRules()
+		if err != nil || len(listed) != 0 {
+			t.Errorf("ListRules() = %d rules, %v, want none", len(listed), err)
+		}
+	})
+}
+
+// testDeliveryRepositoryContract is the behaviour every DeliveryRepository must provide.
+// Deliveries belong to notified statements, so it runs against a whole storage backend.
+func testDeliveryRepositoryContract(t *testing.T, newStorage func(t testing.TB) *Storage) {
+	records := statementRecords([]Transaction{
+		{BookingDate: statementDay("02.10.2025"), PartnerName: "Coffee Shop", Amount: eur("-2,50")},
+		{BookingDate: statementDay("01.10.2025"), PartnerName: "Supermarket", Amount: eur("-45,10")},
+	})
+
+	changes := make([]TransactionChange, len(records))
+	for i, record := range records {
+		changes[i] = TransactionChange{Kind: ChangeNew, Record: record}
+	}
+
+	// enqueue marks the records as notified and queues them as new for the channels
+	enqueue := func(t *testing.T, storage *Storage, channels ...string) {
+		t.Helper()
+		if err := storage.Deliveries.Enqueue(channels, changes); err != nil {
+			t.Fatalf("Enqueue() failed: %v", err)
+		}
+		if err := storage.Statements.MarkMultipleAsNotified(records); err != nil {
+			t.Fatalf("MarkMultipleAsNotified() failed: %v", err)
+		}
+	}
+
+	t.Run("enqueued statements are pending per channel", func(t *testing.T) {
+		storage := newStorage(t)
+		enqueue(t, storage, "discord", "email")
+
+		for _, channel := range []string{"discord", "email"} {
+			pending, err := storage.Deliveries.Pending(channel)
+			if err != nil {
+				t.Fatalf("Pending(%q) failed: %v", channel, err)
+			}
+			// Oldest booking first
+			if len(pending) != 2 || pending[0].Record != records[1] || pending[1].Record != records[0] || pending[0].Kind != ChangeNew {
+				t.Errorf("Pending(%q) = %+v, want both records by booking date", channel, pending)
+			}
+		}
+		if pending, err := storage.Deliveries.Pending("sms"); err != nil || len(pending) != 0 {
+			t.Errorf("Pending(unknown channel) = %+v, %v, want none", pending, err)
+		}
+	})
+
+	t.Run("removals of statements never notified are pending", func(t *testing.T) {
+		storage := newStorage(t)
+		transactions := []Transaction{{BookingDate: statementDay("03.10.2025"), PartnerName: "Bakery", Amount: eur("-3,00")}}
+		documentID, err := storage.Transactions.SaveDocument(StatementDocument{SHA256: "removed"})
+		if err != nil {
+			t.Fatalf("SaveDocument() failed: %v", err)
+		}
+		if err := storage.Transactions.RecordTransactions(documentID, transactions); err != nil {
+			t.Fatalf("RecordTransactions() failed: %v", err)
+		}
+		removed := statementRecords(transactions)[0]
+		if err := storage.Deliveries.Enqueue([]string{"discord"}, []TransactionChange{{Kind: ChangeRemoved, Record: removed}}); err != nil {
+			t.Fatalf("Enqueue() failed: %v", err)
+		}
+
+		pending, err := storage.Deliveries.Pending("discord")
+		if err != nil {
+			t.Fatalf("Pending() failed: %v", err)
+		}
+		if len(pending) != 1 || pending[0].Kind != ChangeRemoved || pending[0].Record.Key != removed.Key ||
+			pending[0].Record.PartnerName != "Bakery" || pending[0].Record.Amount != eur("-3,00") ||
+			!pending[0].Record.BookingDate.Equal(removed.BookingDate) {
+			t.Errorf("Pending() = %+v, want the removed bakery transaction", pending)
+		}
+	})
+
+	t.Run("channels are delivered independently", func(t *testing.T) {
+		storage := newStorage(t)
+		enqueue(t, storage, "discord", "email")
+		keys := recordKeys(records)
+
+		if err := storage.Deliveries.MarkSent("discord", ChangeNew, keys); err != nil {
+			t.Fatalf("MarkSent() failed: %v", err)
+		}
+		if err := storage.Deliveries.MarkFailed("email", ChangeNew, keys, fmt.Errorf("smtp unavailable")); err != nil {
+			t.Fatalf("MarkFailed() failed: %v", err)
+		}
+
+		if pending, err := storage.Deliveries.Pending("discord"); err != nil || len(pending) != 0 {
+			t.Errorf("Pending(sent channel) = %+v, %v, want none", pending, err)
+		}
+		if pending, err := storage.Deliveries.Pending("email"); err != nil || len(pending) != 2 {
+			t.Errorf("Pending(failed channel) = %d records, %v, want 2", len(pending), err)
+		}
+
+		failed, err := storage.Deliveries.ListDeliveries(DeliveryFailed)
+		if err != nil {
+			t.Fatalf("ListDeliveries() failed: %v", err)
+		}
+		if len(failed) != 2 {
+			t.Fatalf("ListDeliveries(failed) returned %d deliveries, want 2", len(failed))
+		}
+		if failed[0].Channel != "email" || failed[0].Attempts != 1 || failed[0].LastError != "smtp unavailable" {
+			t.Errorf("failed delivery = %+v, want email, 1 attempt, smtp unavailable", failed[0])
+		}
+
+		// A retry that succeeds clears the error and counts the attempt
+		if err := storage.Deliveries.MarkSent("email", ChangeNew, keys); err != nil {
+			t.Fatalf("MarkSent() failed: %v", err)
+		}
+		all, err := storage.Deliveries.ListDeliveries("")
+		if err != nil {
+			t.Fatalf("ListDeliveries() failed: %v", err)
+		}
+		if len(all) != 4 {
+			t.Fatalf("ListDeliveries() returned %d deliveries, want 4", len(all))
+		}
+		for _, delivery := range all {
+			if delivery.Status != DeliverySent || delivery.LastError != "" {
+				t.Errorf("delivery = %+v, want sent without error", delivery)
+			}
+			if delivery.Channel == "email" && delivery.Attempts != 2 {
+				t.Errorf("email delivery attempts = %d, want 2", delivery.Attempts)
+			}
+		}
+	})
+
+	t.Run("enqueuing again keeps the delivery state", func(t *testing.T) {
+		storage := newStorage(t)
+		enqueue(t, storage, "discord")
+		if err := storage.Deliveries.MarkSent("discord", ChangeNew, recordKeys(records)); err != nil {
+			t.Fatalf("MarkSent() failed: %v", err)
+		}
+		enqueue(t, storage, "discord")
+
+		if pending, err := storage.Deliveries.Pending("discord"); err != nil || len(pending) != 0 {
+			t.Errorf("Pending() after enqueuing sent statements = %+v, %v, want none", pending, err)
+		}
+	})
+
+	t.Run("changes of a statement are delivered separately", func(t *testing.T) {
+		storage := newStorage(t)
+		enqueue(t, storage, "discord")
+		if err := storage.Deliveries.MarkSent("discord", ChangeNew, recordKeys(records)); err != nil {
+			t.Fatalf("MarkSent() failed: %v", err)
+		}
+
+		previous := StatementRecord{BookingDate: statementDay("01.10.2025"), Amount: eur("-2,00")}
+		updated := TransactionChange{Kind: ChangeUpdated, Record: records[0], Previous: &previous}
+		if err := storage.Deliveries.Enqueue([]string{"discord"}, []TransactionChange{updated}); err != nil {
+			t.Fatalf("Enqueue() failed: %v", err)
+		}
+
+		pending, err := storage.Deliveries.Pending("discord")
+		if err != nil {
+			t.Fatalf("Pending() failed: %v", err)
+		}
+		if len(pending) != 1 || pending[0].Kind != ChangeUpdated || pending[0].Record != records[0] {
+			t.Fatalf("Pending() = %+v, want the update of %q", pending, records[0].Key)
+		}
+		if got := pending[0].Previous; got == nil || got.BookingDate != previous.BookingDate || got.Amount != previous.Amount {
+			t.Errorf("Previous = %+v, want booking date and amount %+v", got, previous)
+		}
+	})
+
+	t.Run("rekey moves deliveries to the new key", func(t *testing.T) {
+		storage := newStorage(t)
+		enqueue(t, storage, "discord")
+		oldKey := records[0].Key
+		if err := storage.Deliveries.MarkSent("discord", ChangeNew, []string{oldKey}); err != nil {
+			t.Fatalf("MarkSent() failed: %v", err)
+		}
+
+		newKey := generateStatementKey(statementDay("02.10.2025"), "Coffee Shop GmbH", eur("-2,50"), 1)
+		if err := storage.Deliveries.RekeyDeliveries(oldKey, newKey); err != nil {
+			t.Fatalf("RekeyDeliveries() failed: %v", err)
+		}
+
+		sent, err := storage.Deliveries.ListDeliveries(DeliverySent)
+		if err != nil {
+			t.Fatalf("ListDeliveries() failed: %v", err)
+		}
+		if len(sent) != 1 || sent[0].Key != newKey {
+			t.Errorf("sent deliveries = %+v, want one under the new key", sent)
+		}
+	})
+
+	t.Run("pending records keep their space", func(t *testing.T) {
+		storage := newStorage(t)
+		spaceRecords := statementRecords([]Transaction{{BookingDate: statementDay("03.10.2025"), PartnerName: "Main Account", Amount: eur("100,00"), Space: "space-savings"}})
+		if err := storage.Deliveries.Enqueue([]string{"discord"}, []TransactionChange{{Kind: ChangeNew, Record: spaceRecords[0]}}); err != nil {
+			t.Fatalf("Enqueue() failed: %v", err)
+		}
+		if err := storage.Statements.MarkMultipleAsNotified(spaceRecords); err != nil {
+			t.Fatalf("MarkMultipleAsNotified() failed: %v", err)
+		}
+
+		pending, err := storage.Deliveries.Pending("discord")
+		if err != nil {
+			t.Fatalf("Pending() failed: %v", err)
+		}
+		if len(pending) != 1 || pending[0].Record != spaceRecords[0] {
+			t.Errorf("Pending() = %+v, want %+v", pending, spaceRecords[0])
+		}
+	})
+}
+
+// testBudgetRepositoryContract is the behaviour every BudgetRepository must provide
+func testBudgetRepositoryContract(t *testing.T, newRepo func(t *testing.T) BudgetRepository) {
+	t.Run("budgets are listed by category and can be replaced", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, budget := range []Budget{{"Restaurants", eur("150,00")}, {"Groceries", eur("400,00")}, {"Restaurants", eur("200,50")}} {
+			if err := repo.SetBudget(budget); err != nil {
+				t.Fatalf("SetBudget(%+v) failed: %v", budget, err)
+			}
+		}
+
+		budgets, err := repo.ListBudgets()
+		if err != nil {
+			t.Fatalf("ListBudgets() failed: %v", err)
+		}
+		want := []Budget{{"Groceries", eur("400,00")}, {"Restaurants", eur("200,50")}}
+		if len(budgets) != len(want) || budgets[0] != want[0] || budgets[1] != want[1] {
+			t.Errorf("ListBudgets() = %+v, want %+v", budgets, want)
+		}
+	})
+
+	t.Run("invalid budgets are rejected", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, budget := range []Budget{{"", eur("100,00")}, {"Groceries", eur("0,00")}, {"Groceries", eur("-5,00")}, {"Groceries", eur("$100.00")}} {
+			if err := repo.SetBudget(budget); err == nil {
+				t.Errorf("SetBudget(%+v) succeeded, want error", budget)
+			}
+		}
+	})
+
+	t.Run("deleted budgets are no longer listed", func(t *testing.T) {
+		repo := newRepo(t)
+		if err := repo.SetBudget(Budget{"Groceries", eur("400,00")}); err != nil {
+			t.Fatalf("SetBudget() failed: %v", err)
+		}
+		if err := repo.DeleteBudget("Groceries"); err != nil {
+			t.Fatalf("DeleteBudget() failed: %v", err)
+		}
+		if err := repo.DeleteBudget("Groceries"); err == nil {
+			t.Error("DeleteBudget() of a deleted budget succeeded, want error")
+		}
+		budgets, err := repo.ListBudgets()
+		if err != nil || len(budgets) != 0 {
+			t.Errorf("ListBudgets() = %+v, %v, want none", budgets, err)
+		}
+	})
+}
+
+// testAlertRepositoryContract is the behaviour every AlertRepository must provide
+func testAlertRepositoryContract(t *testing.T, newRepo func(t *testing.T) AlertRepository) {
+	t.Run("sent alerts are tracked per channel", func(t *testing.T) {
+		repo := newRepo(t)
+		if err := repo.MarkAlertsSent("discord", []string{"a", "c"}); err != nil {
+			t.Fatalf("MarkAlertsSent() failed: %v", err)
+		}
+		// Marking again is a no-op
+		if err := repo.MarkAlertsSent("discord", []string{"a"}); err != nil {
+			t.Fatalf("MarkAlertsSent() failed: %v", err)
+		}
+
+		unsent, err := repo.FilterUnsent("discord", []string{"c", "b", "a", "d"})
+		if err != nil {
+			t.Fatalf("FilterUnsent() failed: %v", err)
+		}
+		if strings.Join(unsent, ",") != "b,d" {
+			t.Errorf("FilterUnsent(discord) = %v, want [b d]", unsent)
+		}
+
+		unsent, err = repo.FilterUnsent("email", []string{"a", "b"})
+		if err != nil {
+			t.Fatalf("FilterUnsent() failed: %v", err)
+		}
+		if strings.Join(unsent, ",") != "a,b" {
+			t.Errorf("FilterUnsent(email) = %v, want [a b]", unsent)
+		}
+	})
+}
+
+// testSubscriptionRepositoryContract is the behaviour every SubscriptionRepository must provide
+func testSubscriptionRepositoryContract(t *testing.T, newRepo func(t *testing.T) SubscriptionRepository) {
+	day := func(month time.Month, d int) time.Time { return time.Date(2025, month, d, 0, 0, 0, 0, time.UTC) }
+	spotify := Subscription{PartnerName: "Spotify AB", Cadence: CadenceMonthly, Amount: eur("12,99"), PreviousAmount: eur("10,99"), Occurrences: 4,
+		FirstChargeDate: day(7, 5), PreviousChargeDate: day(9, 5), LastChargeDate: day(10, 5), NextChargeDate: day(11, 5), Status: SubscriptionActive}
+	gym := Subscription{PartnerName: "Gym Club", Cadence: CadenceWeekly, Amount: eur("5,00"), PreviousAmount: eur("5,00"), Occurrences: 4,
+		FirstChargeDate: day(9, 1), PreviousChargeDate: day(9, 15), LastChargeDate: day(9, 22), NextChargeDate: day(9, 29), Status: SubscriptionOverdue}
+
+	t.Run("replaced subscriptions are listed by next charge", func(t *testing.T) {
+		repo := newRepo(t)
+		if err := repo.ReplaceSubscriptions([]Subscription{gym}); err != nil {
+			t.Fatalf("ReplaceSubscriptions() failed: %v", err)
+		}
+		if err := repo.ReplaceSubscriptions([]Subscription{spotify, gym}); err != nil {
+			t.Fatalf("ReplaceSubscriptions() failed: %v", err)
+		}
+
+		subscriptions, err := repo.ListSubscriptions()
+		if err != nil {
+			t.Fatalf("ListSubscriptions() failed: %v", err)
+		}
+		if len(subscriptions) != 2 {
+			t.Fatalf("ListSubscriptions() returned %d subscriptions, want 2", len(subscriptions))
+		}
+		if subscriptions[0] != gym || subscriptions[1] != spotify {
+			t.Errorf("ListSubscriptions() = %+v, want %+v then %+v", subscriptions, gym, spotify)
+		}
+	})
+
+	t.Run("replacing with nothing clears the list", func(t *testing.T) {
+		repo := newRepo(t)
+		if err := repo.ReplaceSubscriptions([]Subscription{spotify}); err != nil {
+			t.Fatalf("ReplaceSubscriptions() failed: %v", err)
+		}
+		if err := repo.ReplaceSubscriptions(nil); err != nil {
+			t.Fatalf("ReplaceSubscriptions() failed: %v", err)
+		}
+		subscriptions, err := repo.ListSubscriptions()
+		if err != nil || len(subscriptions) != 0 {
+			t.Errorf("ListSubscriptions() = %+v, %v, want none", subscriptions, err)
+		}
+	})
+}
+
+// testBalanceRepositoryContract is the behaviour every BalanceRepository must provide
+func testBalanceRepositoryContract(t *testing.T, newRepo func(t *testing.T) BalanceRepository) {
+	at := func(day, hour int) time.Time { return time.Date(2025, 10, day, hour, 30, 0, 0, time.UTC) }
+	snapshot := func(account string, recordedAt time.Time, balance string) BalanceSnapshot {
+		return BalanceSnapshot{Account: account, RecordedAt: recordedAt, PeriodStart: time.Date(2025, 9, 20, 0, 0, 0, 0, time.UTC),
+			PeriodEnd: time.Date(2025, 10, 20, 0, 0, 0, 0, time.UTC), Balance: eur(balance)}
+	}
+
+	t.Run("snapshots of an account are listed oldest first within the range", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, s := range []BalanceSnapshot{
+			snapshot("acc-1", at(12, 8), "-1.234.567,89"),
+			snapshot("acc-1", at(10, 8), "1.500,00"),
+			snapshot("acc-2", at(11, 8), "99,00"),
+			snapshot("acc-1", at(1, 8), "2.000,00"),
+		} {
+			id, err := repo.RecordSnapshot(s)
+			if err != nil {
+				t.Fatalf("RecordSnapshot() failed: %v", err)
+			}
+			if id <= 0 {
+				t.Errorf("RecordSnapshot() = %d, want a positive ID", id)
+			}
+		}
+
+		snapshots, err := repo.ListSnapshots("acc-1", at(10, 8), at(12, 8))
+		if err != nil {
+			t.Fatalf("ListSnapshots() failed: %v", err)
+		}
+		if len(snapshots) != 2 {
+			t.Fatalf("ListSnapshots() returned %d snapshots, want 2: %+v", len(snapshots), snapshots)
+		}
+		first, second := snapshots[0], snapshots[1]
+		if !first.RecordedAt.Equal(at(10, 8)) || first.Balance != eur("1.500,00") || !second.RecordedAt.Equal(at(12, 8)) || second.Balance != eur("-1.234.567,89") {
+			t.Errorf("ListSnapshots() = %+v, want 1500 on the 10th then 1200.50 on the 12th", snapshots)
+		}
+		if first.Account != "acc-1" || !first.PeriodStart.Equal(time.Date(2025, 9, 20, 0, 0, 0, 0, time.UTC)) ||
+			!first.PeriodEnd.Equal(time.Date(2025, 10, 20, 0, 0, 0, 0, time.UTC)) || first.DocumentID != 0 {
+			t.Errorf("ListSnapshots() = %+v, want the recorded account and period without a document", first)
+		}
+	})
+}
+
+// testDigestRepositoryContract is the behaviour every DigestRepository must provide
+func testDigestRepositoryContract(t *testing.T, newRepo func(t *testing.T) DigestRepository) {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2025, month, d, 0, 0, 0, 0, statementLocation())
+	}
+
+	t.Run("the latest sent period is tracked per kind and channel", func(t *testing.T) {
+		repo := newRepo(t)
+		if _, sent, err := repo.LastSentDigest(DigestWeekly, "discord"); err != nil || sent {
+			t.Fatalf("LastSentDigest() on an empty repository = %t, %v, want nothing sent", sent, err)
+		}
+
+		for _, start := range []time.Time{day(10, 6), day(10, 13), day(9, 29)} {
+			if err := repo.MarkDigestSent(DigestWeekly, "discord", start, start.AddDate(0, 0, 6)); err != nil {
+				t.Fatalf("MarkDigestSent() failed: %v", err)
+			}
+		}
+		// Marking again is a no-op
+		if err := repo.MarkDigestSent(DigestWeekly, "discord", day(10, 13), day(10, 19)); err != nil {
+			t.Fatalf("MarkDigestSent() failed: %v", err)
+		}
+		if err := repo.MarkDigestSent(DigestMonthly, "discord", day(9, 1), day(9, 30)); err != nil {
+			t.Fatalf("MarkDigestSent() failed: %v", err)
+		}
+
+		last, sent, err := repo.LastSentDigest(DigestWeekly, "discord")
+		if err != nil || !sent || !last.Equal(day(10, 13)) {
+			t.Errorf("LastSentDigest(weekly, discord) = %v, %t, %v, want 2025-10-13", last, sent, err)
+		}
+		last, sent, err = repo.LastSentDigest(DigestMonthly, "discord")
+		if err != nil || !sent || !last.Equal(day(9, 1)) {
+			t.Errorf("LastSentDigest(monthly, discord) = %v, %t, %v, want 2025-09-01", last, sent, err)
+		}
+		if _, sent, err := repo.LastSentDigest(DigestWeekly, "email"); err != nil || sent {
+			t.Errorf("LastSentDigest(weekly, email) = %t, %v, want nothing sent", sent, err)
+		}
+	})
+}
+
+// testSpaceRepositoryContract is the behaviour every SpaceRepository must provide
+func testSpaceRepositoryContract(t *testing.T, newRepo func(t *testing.T) SpaceRepository) {
+	t.Run("spaces are listed main account first, then by name", func(t *testing.T) {
+		repo := newRepo(t)
+		if spaces, err := repo.ListSpaces(); err != nil || len(spaces) != 0 {
+			t.Fatalf("ListSpaces() on empty repository = %+v, %v, want none", spaces, err)
+		}
+
+		spaces := []Space{
+			{ID: "space-travel", Name: "Travel"},
+			{ID: "space-main", Name: "Main Account", Primary: true},
+			{ID: "space-savings", Name: "Savings"},
+		}
+		if err := repo.ReplaceSpaces(spaces); err != nil {
+			t.Fatalf("ReplaceSpaces() failed: %v", err)
+		}
+		got, err := repo.ListSpaces()
+		if err != nil {
+			t.Fatalf("ListSpaces() failed: %v", err)
+		}
+		want := []Space{spaces[1], spaces[2], spaces[0]}
+		if !slices.Equal(got, want) {
+			t.Errorf("ListSpaces() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("replacing drops the spaces that are gone", func(t *testing.T) {
+		repo := newRepo(t)
+		if err := repo.ReplaceSpaces([]Space{{ID: "space-main", Name: "Main Account", Primary: true}, {ID: "space-old", Name: "Holidays"}}); err != nil {
+			t.Fatalf("ReplaceSpaces() failed: %v", err)
+		}
+		renamed := []Space{{ID: "space-main", Name: "Main Account", Primary: true}, {ID: "space-new", Name: "Rainy Day"}}
+		if err := repo.ReplaceSpaces(renamed); err != nil {
+			t.Fatalf("ReplaceSpaces() failed: %v", err)
+		}
+		if got, err := repo.ListSpaces(); err != nil || !slices.Equal(got, renamed) {
+			t.Errorf("ListSpaces() = %+v, %v, want %+v", got, err, renamed)
+		}
+	})
+}
+
+// testRetentionRepositoryContract is the behaviour every persistent RetentionRepository must provide
+func testRetentionRepositoryContract(t *testing.T, newStorage func(t testing.TB) *Storage) {
+	now := time.Now().UTC()
+	old := now.AddDate(0, 0, -100)
+	cutoff := now.AddDate(0, 0, -60)
+
+	// seed stores one old and one recent row of every data type, backdating through SQL
+	seed := func(t *testing.T) *Storage {
+		storage := newStorage(t)
+		backdate := func(query string, args ...any) {
+			t.Helper()
+			if _, err := storage.DB.Exec(query, args...); err != nil {
+				t.Fatalf("failed to backdate: %v", err)
+			}
+		}
+
+		oldDoc, err := storage.Transactions.SaveDocument(StatementDocument{SHA256: "old", SizeBytes: 1})
+		if err != nil {
+			t.Fatalf("SaveDocument() failed: %v", err)
+		}
+		if _, err := storage.Transactions.SaveDocument(StatementDocument{SHA256: "new", SizeBytes: 1}); err != nil {
+			t.Fatalf("SaveDocument() failed: %v", err)
+		}
+		backdate(`UPDATE statement_documents SET fetched_at = $1 WHERE id = $2`, old, oldDoc)
+		err = storage.Transactions.RecordTransactions(oldDoc, []Transaction{
+			{BookingDate: old, PartnerName: "Old Shop", Amount: eur("-10,00"), RawText: "old raw"},
+			{BookingDate: now, PartnerName: "New Shop", Amount: eur("-20,00"), RawText: "new raw"},
+		})
+		if err != nil {
+			t.Fatalf("RecordTransactions() failed: %v", err)
+		}
+
+		if err := storage.Statements.MarkMultipleAsNotified([]StatementRecord{{Key: "k-old", PartnerName: "Old Shop"}, {Key: "k-new", PartnerName: "New Shop"}}); err != nil {
+			t.Fatalf("MarkMultipleAsNotified() failed: %v", err)
+		}
+		backdate(`UPDATE statements SET created_at = $1 WHERE statement_key = 'k-old'`, old)
+
+		for _, recordedAt := range []time.Time{old, now} {
+			if _, err := storage.Balances.RecordSnapshot(BalanceSnapshot{RecordedAt: recordedAt, Balance: eur("1,00"), DocumentID: oldDoc}); err != nil {
+				t.Fatalf("RecordSnapshot() failed: %v", err)
+			}
+		}
+
+		if err := storage.Alerts.MarkAlertsSent("discord", []string{"a-old", "a-new"}); err != nil {
+			t.Fatalf("MarkAlertsSent() failed: %v", err)
+		}
+		backdate(`UPDATE sent_alerts SET sent_at = $1 WHERE alert_key = 'a-old'`, old)
+		for _, start := range []time.Time{old, old.AddDate(0, 0, 7)} {
+			if err := storage.Digests.MarkDigestSent(DigestWeekly, "discord", start, start.AddDate(0, 0, 6)); err != nil {
+				t.Fatalf("MarkDigestSent() failed: %v", err)
+			}
+		}
+		backdate(`UPDATE sent_digests SET sent_at = $1`, old)
+
+		deliveries := []TransactionChange{{Kind: ChangeNew, Record: StatementRecord{Key: "d-sent"}}, {Kind: ChangeNew, Record: StatementRecord{Key: "d-pending"}}}
+		if err := storage.Deliveries.Enqueue([]string{"discord"}, deliveries); err != nil {
+			t.Fatalf("Enqueue() failed: %v", err)
+		}
+		if err := storage.Deliveries.MarkSent("discord", ChangeNew, []string{"d-sent"}); err != nil {
+			t.Fatalf("MarkSent() failed: %v", err)
+		}
+		backdate(`UPDATE deliveries SET updated_at = $1`, old)
+
+		for _, cookie := range []string{"c1", "c2"} {
+			if err := storage.Cookies.Save(cookie); err != nil {
+				t.Fatalf("Save() failed: %v", err)
+			}
+		}
+		backdate(`UPDATE cookies SET updated_at = $1`, old)
+		return storage
+	}
+
+	all := RetentionPolicy{Action: PurgeDelete, CookieKeep: 1}.rules(now, cutoff)
+	wantDeleted := map[DataType]int64{
+		DataStatements: 1, DataTransactions: 1, DataBalances: 1, DataDocuments: 1,
+		DataAlerts:     2, // The old alert and the older of the two digests
+		DataCookies:    1, // The newest cookie is kept
+		DataDeliveries: 1, // The sent delivery, the pending one is kept
+	}
+
+	t.Run("dry run counts without changing anything", func(t *testing.T) {
+		storage := seed(t)
+		for pass := 0; pass < 2; pass++ {
+			for _, rule := range all {
+				count, err := storage.Retention.Purge(rule, true)
+				if err != nil {
+					t.Fatalf("Purge(%s, dry run) failed: %v", rule.DataType, err)
+				}
+				if count != wantDeleted[rule.DataType] {
+					t.Errorf("Purge(%s, dry run) = %d, want %d", rule.DataType, count, wantDeleted[rule.DataType])
+				}
+			}
+		}
+	})
+
+	t.Run("expired rows are deleted and references detached", func(t *testing.T) {
+		storage := seed(t)
+		for _, rule := range all {
+			deleted, err := storage.Retention.Purge(rule, false)
+			if err != nil {
+				t.Fatalf("Purge(%s) failed: %v", rule.DataType, err)
+			}
+			if deleted != wantDeleted[rule.DataType] {
+				t.Errorf("Purge(%s) = %d, want %d", rule.DataType, deleted, wantDeleted[rule.DataType])
+			}
+		}
+
+		transactions, err := storage.Transactions.ListTransactions(old.AddDate(0, 0, -1), now)
+		if err != nil || len(transactions) != 1 || transactions[0].PartnerName != "New Shop" || transactions[0].DocumentID != 0 {
+			t.Errorf("ListTransactions() = %+v, %v, want only New Shop without its purged document", transactions, err)
+		}
+		snapshots, err := storage.Balances.ListSnapshots("", old.AddDate(0, 0, -1), now.Add(time.Minute))
+		if err != nil || len(snapshots) != 1 || snapshots[0].DocumentID != 0 {
+			t.Errorf("ListSnapshots() = %+v, %v, want the recent snapshot without its purged document", snapshots, err)
+		}
+		if notified, err := storage.Statements.IsNotified("k-old"); err != nil || notified {
+			t.Errorf("IsNotified(k-old) = %t, %v, want purged", notified, err)
+		}
+		if unsent, err := storage.Alerts.FilterUnsent("discord", []string{"a-old", "a-new"}); err != nil || len(unsent) != 1 || unsent[0] != "a-old" {
+			t.Errorf("FilterUnsent() = %v, %v, want only a-old purged", unsent, err)
+		}
+		if last, sent, err := storage.Digests.LastSentDigest(DigestWeekly, "discord"); err != nil || !sent || !last.Equal(sqlDateOnly(old.AddDate(0, 0, 7))) {
+			t.Errorf("LastSentDigest() = %v, %t, %v, want the latest digest kept", last, sent, err)
+		}
+		if cookie := cookieValue(t, storage.Cookies); cookie != "c2" {
+			t.Errorf("latest cookie = %q, want c2 kept", cookie)
+		}
+	})
+
+	t.Run("stale pending deliveries survive the purge", func(t *testing.T) {
+		storage := seed(t)
+		rules := []RetentionRule{{DataType: DataDeliveries, Cutoff: cutoff, Action: PurgeDelete}}
+		if _, err := purgeExpiredData(storage.Retention, rules, purgeSourceRun, false, now); err != nil {
+			t.Fatalf("purgeExpiredData() failed: %v", err)
+		}
+		if pending, err := storage.Deliveries.ListDeliveries(DeliveryPending); err != nil || len(pending) != 1 || pending[0].Key != "d-pending" {
+			t.Errorf("ListDeliveries(pending) = %+v, %v, want the stale pending delivery kept", pending, err)
+		}
+		if sent, err := storage.Deliveries.ListDeliveries(DeliverySent); err != nil || len(sent) != 0 {
+			t.Errorf("ListDeliveries(sent) = %+v, %v, want the stale sent delivery purged", sent, err)
+		}
+	})
+
+	t.Run("anonymizing clears partner names once", func(t *testing.T) {
+		storage := seed(t)
+		for _, dataType := range []DataType{DataTransactions, DataStatements} {
+			rule := RetentionRule{DataType: dataType, Cutoff: cutoff, Action: PurgeAnonymize}
+			for pass, want := range []int64{1, 0} {
+				affected, err := storage.Retention.Purge(rule, false)
+				if err != nil || affected != want {
+					t.Errorf("Purge(%s, anonymize) pass %d = %d, %v, want %d", dataType, pass+1, affected, err, want)
+				}
+			}
+		}
+
+		transactions, err := storage.Transactions.ListTransactions(old.AddDate(0, 0, -1), now)
+		if err != nil || len(transactions) != 2 {
+			t.Fatalf("ListTransactions() = %+v, %v, want both transactions kept", transactions, err)
+		}
+		if transactions[0].PartnerName != "" || transactions[0].RawText != "" || transactions[0].Amount != eur("-10,00") {
+			t.Errorf("old transaction = %+v, want partner and raw text cleared, amount kept", transactions[0])
+		}
+		if transactions[1].PartnerName != "New Shop" {
+			t.Errorf("recent transaction = %+v, want it untouched", transactions[1])
+		}
+	})
+
+	t.Run("purges are listed most recent first", func(t *testing.T) {
+		storage := newStorage(t)
+		for i, dataType := range []DataType{DataTransactions, DataCookies} {
+			entry := PurgeAuditEntry{PurgedAt: now.Add(time.Duration(i) * time.Minute).Truncate(time.Second), Source: purgeSourceCommand,
+				DataType: dataType, Action: PurgeDelete, Cutoff: cutoff.Truncate(time.Second), Affected: int64(i + 3)}
+			if err := storage.Retention.RecordPurge(entry); err != nil {
+				t.Fatalf("RecordPurge() failed: %v", err)
+			}
+		}
+
+		entries, err := storage.Retention.ListPurges(10)
+		if err != nil || len(entries) != 2 {
+			t.Fatalf("ListPurges() = %+v, %v, want 2 entries", entries, err)
+		}
+		if entries[0].DataType != DataCookies || entries[0].Affected != 4 || entries[0].Source != purgeSourceCommand ||
+			!entries[0].Cutoff.Equal(cutoff.Truncate(time.Second)) || entries[1].DataType != DataTransactions {
+			t.Errorf("ListPurges() = %+v, want cookies then transactions", entries)
+		}
+	})
+}
+
+// sqlDateOnly truncates a time to its date, the way dates are read back from DATE columns
+func sqlDateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, statementLocation())
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// DataType is a kind of stored data with its own retention
+type DataType string
+
+const (
+	DataCookies      DataType = "cookies"      // Session cookies
+	DataStatements   DataType = "statements"   // Keys of notified statements
+	DataTransactions DataType = "transactions" // Transaction history
+	DataDocuments    DataType = "documents"    // Downloaded statement metadata
+	DataDeliveries   DataType = "deliveries"   // Notification delivery state
+	DataBalances     DataType = "balances"     // Balance snapshots
+	DataAlerts       DataType = "alerts"       // Sent alerts and digests
+)
+
+// retentionDataTypes lists every data type in purge order, references before what they point to
+var retentionDataTypes = []DataType{
+	DataDeliveries, DataStatements, DataTransactions, DataBalances, DataDocuments, DataAlerts, DataCookies,
+}
+
+// retentionExemptTables lists the stored tables no data type covers, with why nothing in them expires
+var retentionExemptTables = []struct {
+	table  string
+	reason string
+}{
+	{"subscriptions", "replaced on every run from the transactions the retention kept"},
+	{"spaces", "replaced on every run with the spaces N26 currently lists"},
+	{"category_rules", "configuration, managed with the rules command"},
+	{"budgets", "configuration, managed with the budgets command"},
+}
+
+// PurgeAction is what happens to data past its retention
+type PurgeAction string
+
+const (
+	PurgeDelete    PurgeAction = "delete"
+	PurgeAnonymize PurgeAction = "anonymize" // Partner names and raw text are cleared, dates and amounts kept
+)
+
+// Sources of a purge in the audit trail
+const (
+	purgeSourceRun     = "run"     // The retention policy applied by a regular run
+	purgeSourceCommand = "command" // The purge command
+)
+
+// retentionMinDays is the shortest retention of everything but cookies. Statements are downloaded
+// for the last 30 days, younger data would be seen as new and notified again.
+const retentionMinDays = 31
+
+// RetentionRule is what to do with one data type older than the cutoff
+type RetentionRule struct {
+	DataType DataType
+	Cutoff   time.Time
+	Action   PurgeAction
+	Keep     int // Cookies only: the most recent ones are always kept
+}
+
+// RetentionPolicy is how many days each data type is kept, 0 keeps it forever
+type RetentionPolicy struct {
+	Days       map[DataType]int
+	Action     PurgeAction // For statements and transactions, the other data types are always deleted
+	CookieKeep int
+}
+
+// loadRetentionPolicy reads RETENTION_<TYPE>_DAYS for every data type (default 0, kept forever) and
+// RETENTION_MODE (delete or anonymize). Cookies keep following COOKIE_RETENTION_DAYS and COOKIE_RETENTION_KEEP.
+func loadRetentionPolicy() RetentionPolicy {
+	cookies := loadCookieRetentionPolicy()
+	policy := RetentionPolicy{
+		Days:       map[DataType]int{DataCookies: int(cookies.MaxAge / (24 * time.Hour))},
+		Action:     PurgeDelete,
+		CookieKeep: cookies.Keep,
+	}
+
+	for _, dataType := range retentionDataTypes {
+		if dataType == DataCookies {
+			continue
+		}
+		name := "RETENTION_" + strings.ToUpper(string(dataType)) + "_DAYS"
+		days := globalConfig.envInt(name, 0)
+		if days > 0 && days < retentionMinDays {
+			log.Printf("Warning: %s=%d is shorter than the %d-day download window, using %d", name, days, retentionMinDays-1, retentionMinDays)
+			days = retentionMinDays
+		}
+		policy.Days[dataType] = max(days, 0)
+	}
+
+	switch mode := PurgeAction(strings.ToLower(globalConfig.Getenv("RETENTION_MODE"))); mode {
+	case "", PurgeDelete:
+	case PurgeAnonymize:
+		policy.Action = PurgeAnonymize
+	default:
+		log.Printf("Warning: Invalid RETENTION_MODE %q, using %q", mode, PurgeDelete)
+	}
+	return policy
+}
+
+// rules returns the purge rules of the data types with a retention. A non-zero before overrides
+// every retention with that cutoff.
+func (p RetentionPolicy) rules(now, before time.Time) []RetentionRule {
+	var rules []RetentionRule
+	for _, dataType := range retentionDataTypes {
+		cutoff := before
+		if cutoff.IsZero() {
+			days := p.Days[dataType]
+			if days <= 0 {
+				continue
+			}
+			cutoff = now.AddDate(0, 0, -days)
+		}
+
+		rule := RetentionRule{DataType: dataType, Cutoff: cutoff, Action: PurgeDelete, Keep: p.CookieKeep}
+		if dataType == DataStatements || dataType == DataTransactions {
+			rule.Action = p.Action
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// validatePurgeCutoff rejects a cutoff inside the download window, where purged data would come back as new
+func validatePurgeCutoff(before, now time.Time) error {
+	if latest := now.AddDate(0, 0, -retentionMinDays); before.After(latest) {
+		return fmt.Errorf("cutoff %s is inside the %d-day download window, use %s or earlier",
+			before.Format("2006-01-02"), retentionMinDays-1, latest.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// purgeExpiredData applies the rules and returns how many rows each one affected. A dry run only
+// counts the rows. Purges are recorded in the audit trail: every rule of a purge command, and the
+// rules of a regular run that changed something.
+func purgeExpiredData(repo RetentionRepository, rules []RetentionRule, source string, dryRun bool, now time.Time) ([]PurgeAuditEntry, error) {
+	var entries []PurgeAuditEntry
+	for _, rule := range rules {
+		affected, err := repo.Purge(rule, dryRun)
+		if err != nil {
+			return entries, fmt.Errorf("failed to purge %s: %w", rule.DataType, err)
+		}
+
+		entry := PurgeAuditEntry{PurgedAt: now, Source: source, DataType: rule.DataType, Action: rule.Action, Cutoff: rule.Cutoff, Affected: affected}
+		entries = append(entries, entry)
+		if dryRun || (source == purgeSourceRun && affected == 0) {
+			continue
+		}
+		if err := repo.RecordPurge(entry); err != nil {
+			return entries, err
+		}
+		if affected > 0 {
+			log.Printf("Purged %d %s older than %s (%s)", affected, rule.DataType, rule.Cutoff.Format("2006-01-02"), rule.Action)
+		}
+	}
+	return entries, nil
+}
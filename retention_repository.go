@@ -0,0 +1,225 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RetentionRepository deletes or anonymizes stored data past its retention and keeps an audit trail of purges
+type RetentionRepository interface {
+	Purge(rule RetentionRule, dryRun bool) (int64, error)
+	RecordPurge(entry PurgeAuditEntry) error
+	ListPurges(limit int) ([]PurgeAuditEntry, error)
+}
+
+// PurgeAuditEntry records how many rows of one data type a purge deleted or anonymized
+type PurgeAuditEntry struct {
+	ID       int64
+	PurgedAt time.Time
+	Source   string // purgeSourceRun or purgeSourceCommand
+	DataType DataType
+	Action   PurgeAction
+	Cutoff   time.Time
+	Affected int64
+}
+
+// retentionTarget is one table a data type is stored in
+type retentionTarget struct {
+	table     string
+	where     string   // Selects the expired rows, $1 is the cutoff and $2 the number of rows to keep
+	dateOnly  bool     // The cutoff is compared with a DATE column
+	keep      bool     // The query takes $2
+	anonymize string   // SET clause that anonymizes a row, empty when the data type can only be deleted
+	detach    []string // Statements run before deleting, clearing references to the expired rows
+}
+
+// retentionTargets maps each data type to the tables it is stored in. The queries only use
+// syntax PostgreSQL and SQLite have in common.
+var retentionTargets = map[DataType][]retentionTarget{
+	DataCookies: {{
+		table: "cookies",
+		// The most recent cookies of every account are kept
+		where: `updated_at < $1 AND id NOT IN (
+			SELECT recent.id FROM cookies AS recent
+			WHERE recent.account = cookies.account
+			ORDER BY recent.updated_at DESC, recent.id DESC LIMIT $2
+		)`,
+		keep: true,
+	}},
+	DataStatements: {{
+		table:     "statements",
+		where:     "created_at < $1",
+		anonymize: "partner_name = NULL",
+	}},
+	DataTransactions: {{
+		table:     "transactions",
+		where:     "booking_date < $1",
+		dateOnly:  true,
+		anonymize: "partner_name = '', raw_text = NULL",
+	}},
+	DataDocuments: {{
+		table: "statement_documents",
+		where: "fetched_at < $1",
+		// SQLite does not enforce the ON DELETE SET NULL of the references
+		detach: []string{
+			"UPDATE transactions SET document_id = NULL WHERE document_id IN (SELECT id FROM statement_documents WHERE fetched_at < $1)",
+			"UPDATE balance_snapshots SET document_id = NULL WHERE document_id IN (SELECT id FROM statement_documents WHERE fetched_at < $1)",
+		},
+	}},
+	DataDeliveries: {{
+		table: "deliveries",
+		// Pending and failed deliveries are kept however old, they are notifications not sent yet
+		where: "status = 'sent' AND updated_at < $1",
+	}},
+	DataBalances: {{
+		table: "balance_snapshots",
+		where: "recorded_at < $1",
+	}},
+	DataAlerts: {
+		{
+			table: "sent_alerts",
+			where: "sent_at < $1",
+		},
+		{
+			// The latest digest of every account, kind and channel is kept, it is where the next one resumes
+			table: "sent_digests",
+			where: `sent_at < $1 AND EXISTS (
+				SELECT 1 FROM sent_digests AS newer
+				WHERE newer.account = sent_digests.account AND newer.kind = sent_digests.kind AND newer.channel = sent_digests.channel
+				AND newer.period_start > sent_digests.period_start
+			)`,
+		},
+	},
+}
+
+// anonymizedCondition restricts an anonymization to rows that still carry personal data,
+// so a repeated purge reports nothing new
+var anonymizedCondition = map[string]string{
+	"statements":   "partner_name IS NOT NULL",
+	"transactions": "(partner_name <> '' OR raw_text IS NOT NULL)",
+}
+
+// SQLRetentionRepository implements RetentionRepository on both PostgreSQL and SQLite
+type SQLRetentionRepository struct {
+	db *sql.DB
+}
+
+// NewSQLRetentionRepository creates a retention repository on a migrated database
+func NewSQLRetentionRepository(db *sql.DB) *SQLRetentionRepository {
+	return &SQLRetentionRepository{db: db}
+}
+
+// Purge deletes or anonymizes the rows of a data type older than the rule's cutoff and returns
+// how many rows were affected. A dry run only counts them.
+func (r *SQLRetentionRepository) Purge(rule RetentionRule, dryRun bool) (int64, error) {
+	targets, ok := retentionTargets[rule.DataType]
+	if !ok {
+		return 0, fmt.Errorf("unknown data type %q", rule.DataType)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var total int64
+	for _, target := range targets {
+		var cutoff any = rule.Cutoff.UTC()
+		if target.dateOnly {
+			cutoff = sqlDate(rule.Cutoff)
+		}
+		args := []any{cutoff}
+		if target.keep {
+			args = append(args, rule.Keep)
+		}
+
+		where := target.where
+		anonymize := rule.Action == PurgeAnonymize && target.anonymize != ""
+		if anonymize {
+			where += " AND " + anonymizedCondition[target.table]
+		}
+
+		if dryRun {
+			var count int64
+			if err := tx.QueryRow(`SELECT COUNT(*) FROM `+target.table+` WHERE `+where, args...).Scan(&count); err != nil {
+				return 0, fmt.Errorf("failed to count expired %s: %w", target.table, err)
+			}
+			total += count
+			continue
+		}
+
+		query := `DELETE FROM ` + target.table + ` WHERE ` + where
+		if anonymize {
+			query = `UPDATE ` + target.table + ` SET ` + target.anonymize + ` WHERE ` + where
+		} else {
+			for _, detach := range target.detach {
+				if _, err := tx.Exec(detach, args...); err != nil {
+					return 0, fmt.Errorf("failed to detach expired %s: %w", target.table, err)
+				}
+			}
+		}
+		result, err := tx.Exec(query, args...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge %s: %w", target.table, err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to count purged %s: %w", target.table, err)
+		}
+		total += affected
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit purge: %w", err)
+	}
+	return total, nil
+}
+
+// RecordPurge adds a purge to the audit trail
+func (r *SQLRetentionRepository) RecordPurge(entry PurgeAuditEntry) error {
+	query := `
+		INSERT INTO purge_audit (purged_at, source, data_type, action, cutoff, affected)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := r.db.Exec(query, entry.PurgedAt.UTC(), entry.Source, string(entry.DataType), string(entry.Action),
+		entry.Cutoff.UTC(), entry.Affected)
+	if err != nil {
+		return fmt.Errorf("failed to record purge: %w", err)
+	}
+	return nil
+}
+
+// ListPurges returns the most recent purges first, at most limit of them
+func (r *SQLRetentionRepository) ListPurges(limit int) ([]PurgeAuditEntry, error) {
+	query := `
+		SELECT id, purged_at, source, data_type, action, cutoff, affected
+		FROM purge_audit
+		ORDER BY purged_at DESC, id DESC
+		LIMIT $1
+	`
+	rows, err := r.db.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list purges: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []PurgeAuditEntry
+	for rows.Next() {
+		var entry PurgeAuditEntry
+		var dataType, action string
+		if err := rows.Scan(&entry.ID, &entry.PurgedAt, &entry.Source, &dataType, &action, &entry.Cutoff, &entry.Affected); err != nil {
+			return nil, fmt.Errorf("failed to scan purge: %w", err)
+		}
+		entry.DataType = DataType(dataType)
+		entry.Action = PurgeAction(action)
+		entry.PurgedAt = entry.PurgedAt.UTC()
+		entry.Cutoff = entry.Cutoff.UTC()
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list purges: %w", err)
+	}
+	return entries, nil
+}
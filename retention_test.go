@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetentionPolicyRules(t *testing.T) {
+	t.Setenv("COOKIE_RETENTION_DAYS", "10")
+	t.Setenv("RETENTION_TRANSACTIONS_DAYS", "365")
+	t.Setenv("RETENTION_DELIVERIES_DAYS", "7") // Inside the download window
+	t.Setenv("RETENTION_MODE", "anonymize")
+	now := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+
+	policy := loadRetentionPolicy()
+	rules := policy.rules(now, time.Time{})
+	got := make(map[DataType]RetentionRule)
+	for _, rule := range rules {
+		got[rule.DataType] = rule
+	}
+	if len(rules) != 3 {
+		t.Fatalf("rules() = %+v, want cookies, transactions and deliveries only", rules)
+	}
+	if rule := got[DataTransactions]; !rule.Cutoff.Equal(now.AddDate(0, 0, -365)) || rule.Action != PurgeAnonymize {
+		t.Errorf("transactions rule = %+v, want anonymize older than 365 days", rule)
+	}
+	if rule := got[DataDeliveries]; !rule.Cutoff.Equal(now.AddDate(0, 0, -retentionMinDays)) || rule.Action != PurgeDelete {
+		t.Errorf("deliveries rule = %+v, want delete older than the %d-day minimum", rule, retentionMinDays)
+	}
+	if rule := got[DataCookies]; !rule.Cutoff.Equal(now.AddDate(0, 0, -10)) || rule.Keep != 5 {
+		t.Errorf("cookies rule = %+v, want 10 days keeping the latest 5", rule)
+	}
+
+	// A cutoff overrides every retention
+	before := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if rules := policy.rules(now, before); len(rules) != len(retentionDataTypes) || !rules[0].Cutoff.Equal(before) {
+		t.Errorf("rules(before) = %+v, want every data type older than %v", rules, before)
+	}
+	if err := validatePurgeCutoff(now.AddDate(0, 0, -5), now); err == nil {
+		t.Error("validatePurgeCutoff() inside the download window succeeded, want an error")
+	}
+	if err := validatePurgeCutoff(before, now); err != nil {
+		t.Errorf("validatePurgeCutoff(%v) = %v, want nil", before, err)
+	}
+}
+
+func TestPurgeExpiredDataAudit(t *testing.T) {
+	repo := NewMemoryRetentionRepository()
+	now := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	rules := []RetentionRule{{DataType: DataTransactions, Cutoff: now.AddDate(0, 0, -90), Action: PurgeDelete}}
+
+	// Dry runs and regular runs that purge nothing leave no audit record
+	for _, source := range []string{purgeSourceCommand, purgeSourceRun} {
+		if _, err := purgeExpiredData(repo, rules, source, source == purgeSourceCommand, now); err != nil {
+			t.Fatalf("purgeExpiredData() failed: %v", err)
+		}
+	}
+	if entries, _ := repo.ListPurges(10); len(entries) != 0 {
+		t.Errorf("ListPurges() = %+v, want no audit records", entries)
+	}
+
+	// The purge command is always audited
+	entries, err := purgeExpiredData(repo, rules, purgeSourceCommand, false, now)
+	if err != nil || len(entries) != 1 || entries[0].Affected != 0 {
+		t.Fatalf("purgeExpiredData() = %+v, %v, want one rule with nothing purged", entries, err)
+	}
+	audit, _ := repo.ListPurges(10)
+	if len(audit) != 1 || audit[0].Source != purgeSourceCommand || audit[0].DataType != DataTransactions {
+		t.Errorf("ListPurges() = %+v, want the command purge of transactions", audit)
+	}
+}
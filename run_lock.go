@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// RunLockMode controls what happens when another run already holds the lock
+type RunLockMode string
+
+const (
+	RunLockWait RunLockMode = "wait" // Block until the other run finishes (up to the timeout)
+	RunLockSkip RunLockMode = "skip" // Exit immediately without doing anything
+)
+
+// ErrRunLockHeld is returned in skip mode when another run holds the lock
+var ErrRunLockHeld = errors.New("another run holds the lock")
+
+// ErrRunLockTimeout is returned in wait mode when another run still holds the lock after the timeout
+var ErrRunLockTimeout = errors.New("timed out waiting for another run to release the lock")
+
+// RunLock is held for the duration of a run and released when the run finishes
+type RunLock interface {
+	Release() error
+}
+
+// postgresRunLock is a PostgreSQL session-level advisory lock.
+// Advisory locks belong to a database session, so the lock keeps a dedicated connection
+// out of the pool until it is released.
+type postgresRunLock struct {
+	conn *sql.Conn
+	key  int64
+}
+
+// sqliteRunLock is a lease row in the run_locks table, since SQLite has no advisory locks.
+// The holder renews the lease while it runs; it expires on its own if the holding process dies
+// without releasing it.
+type sqliteRunLock struct {
+	db     *sql.DB
+	key    string
+	holder string
+	stop   chan struct{} // Closed by Release to stop renewing the lease
+	done   chan struct{} // Closed once the renewal has stopped
+}
+
+// memoryRunLock is a no-op lock for in-memory storage, where runs cannot overlap
+type memoryRunLock struct{}
+
+const (
+	// sqliteRunLockLease bounds how long a crashed run can block later runs
+	sqliteRunLockLease = 5 * time.Minute
+	// sqliteRunLockRenewal is how often a live run extends its lease, so runs longer than the
+	// lease (a 2FA wait, slow downloads of several accounts) keep the lock
+	sqliteRunLockRenewal = time.Minute
+)
+
+// loadRunLockConfig reads RUN_LOCK_MODE and RUN_LOCK_TIMEOUT_SECONDS
+func loadRunLockConfig(config AccountConfig) (RunLockMode, time.Duration) {
+	mode := RunLockMode(strings.ToLower(config.Getenv("RUN_LOCK_MODE")))
+	switch mode {
+	case RunLockWait, RunLockSkip:
+	case "":
+		mode = RunLockWait
+	default:
+		log.Printf("Warning: Invalid RUN_LOCK_MODE %q, using %q", mode, RunLockWait)
+		mode = RunLockWait
+	}
+
+	timeout := time.Duration(config.envInt("RUN_LOCK_TIMEOUT_SECONDS", 600)) * time.Second
+	return mode, timeout
+}
+
+// runLockKey derives a stable advisory lock key for an account
+func runLockKey(account string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("n26-scraper:" + account))
+	return int64(h.Sum64())
+}
+
+// AcquireRunLock takes the run lock for an account on the storage backend.
+// In skip mode it fails with ErrRunLockHeld straight away if the lock is taken;
+// in wait mode it polls until the lock is free, failing with ErrRunLockTimeout when the timeout expires.
+func AcquireRunLock(ctx context.Context, storage *Storage, account string, mode RunLockMode, timeout time.Duration) (RunLock, error) {
+	var lock RunLock
+	var tryLock func() (bool, error)
+	var abandon func()
+
+	switch storage.Backend {
+	case backendMemory:
+		return memoryRunLock{}, nil
+	case backendSQLite:
+		sqliteLock := &sqliteRunLock{
+			db:     storage.DB,
+			key:    fmt.Sprintf("%d", runLockKey(account)),
+			holder: fmt.Sprintf("pid-%d-%d", os.Getpid(), time.Now().UnixNano()),
+		}
+		lock = sqliteLock
+		tryLock = func() (bool, error) {
+			acquired, err := sqliteLock.tryAcquire(ctx)
+			if acquired {
+				sqliteLock.startRenewal()
+			}
+			return acquired, err
+		}
+		abandon = func() {}
+	default:
+		conn, err := storage.DB.Conn(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get connection for run lock: %w", err)
+		}
+		pgLock := &postgresRunLock{conn: conn, key: runLockKey(account)}
+		lock = pgLock
+		tryLock = func() (bool, error) { return pgLock.tryAcquire(ctx) }
+		abandon = func() { conn.Close() }
+	}
+
+	deadline := time.Now().Add(timeout)
+	pollInterval := 2 * time.Second
+	loggedWait := false
+
+	for {
+		acquired, err := tryLock()
+		if err != nil {
+			abandon()
+			return nil, fmt.Errorf("failed to acquire run lock: %w", err)
+		}
+		if acquired {
+			return lock, nil
+		}
+
+		if mode == RunLockSkip {
+			abandon()
+			return nil, ErrRunLockHeld
+		}
+		if time.Now().After(deadline) {
+			abandon()
+			return nil, fmt.Errorf("%w after %s", ErrRunLockTimeout, timeout)
+		}
+
+		if !loggedWait {
+			fmt.Printf("Another run is in progress, waiting up to %s for it to finish...\n", timeout)
+			loggedWait = true
+		}
+
+		select {
+		case <-ctx.Done():
+			abandon()
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// tryAcquire attempts to take the advisory lock without blocking
+func (l *postgresRunLock) tryAcquire(ctx context.Context) (bool, error) {
+	var acquired bool
+	err := l.conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, l.key).Scan(&acquired)
+	return acquired, err
+}
+
+// Release unlocks the advisory lock and returns the connection to the pool
+func (l *postgresRunLock) Release() error {
+	defer l.conn.Close()
+
+	var released bool
+	err := l.conn.QueryRowContext(context.Background(), `SELECT pg_advisory_unlock($1)`, l.key).Scan(&released)
+	if err != nil {
+		return fmt.Errorf("failed to release run lock: %w", err)
+	}
+	if !released {
+		return fmt.Errorf("run lock was not held")
+	}
+	return nil
+}
+
+// Release does nothing, the in-memory lock is never contended
+func (memoryRunLock) Release() error {
+	return nil
+}
+
+// tryAcquire clears an expired lease and then attempts to insert our own
+func (l *sqliteRunLock) tryAcquire(ctx context.Context) (bool, error) {
+	now := time.Now().UTC()
+	if _, err := l.db.ExecContext(ctx, `DELETE FROM run_locks WHERE lock_key = ? AND expires_at < ?`, l.key, now); err != nil {
+		return false, err
+	}
+
+	result, err := l.db.ExecContext(ctx,
+		`INSERT INTO run_locks (lock_key, holder, acquired_at, expires_at) VALUES (?, ?, ?, ?) ON CONFLICT (lock_key) DO NOTHING`,
+		l.key, l.holder, now, now.Add(sqliteRunLockLease),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return inserted == 1, nil
+}
+
+// startRenewal extends the lease every sqliteRunLockRenewal until Release stops it
+func (l *sqliteRunLock) startRenewal() {
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	go func() {
+		defer close(l.done)
+		ticker := time.NewTicker(sqliteRunLockRenewal)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+				if err := l.renew(); err != nil {
+					log.Printf("Warning: Failed to renew run lock: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// renew moves the expiry of our lease one lease length past now
+func (l *sqliteRunLock) renew() error {
+	result, err := l.db.Exec(`UPDATE run_locks SET expires_at = ? WHERE lock_key = ? AND holder = ?`,
+		time.Now().UTC().Add(sqliteRunLockLease), l.key, l.holder)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		return fmt.Errorf("the lease expired and another run took the lock")
+	}
+	return nil
+}
+
+// Release stops renewing the lease and deletes our lease row
+func (l *sqliteRunLock) Release() error {
+	if l.stop != nil {
+		close(l.stop)
+		<-l.done
+	}
+	result, err := l.db.Exec(`DELETE FROM run_locks WHERE lock_key = ? AND holder = ?`, l.key, l.holder)
+	if err != nil {
+		return fmt.Errorf("failed to release run lock: %w", err)
+	}
+	if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+		return fmt.Errorf("run lock was not held")
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteRunLockLease(t *testing.T) {
+	storage := openTestStorage(t, "sqlite://"+filepath.Join(t.TempDir(), "n26.db"))
+	ctx := context.Background()
+
+	lock, err := AcquireRunLock(ctx, storage, "acc-1", RunLockSkip, time.Second)
+	if err != nil {
+		t.Fatalf("AcquireRunLock() failed: %v", err)
+	}
+	if _, err := AcquireRunLock(ctx, storage, "acc-1", RunLockSkip, time.Second); !errors.Is(err, ErrRunLockHeld) {
+		t.Fatalf("second AcquireRunLock() = %v, want ErrRunLockHeld", err)
+	}
+
+	// A renewed lease outlives its first expiry
+	if _, err := storage.DB.Exec(`UPDATE run_locks SET expires_at = ?`, time.Now().UTC().Add(-time.Minute)); err != nil {
+		t.Fatalf("failed to expire the lease: %v", err)
+	}
+	if err := lock.(*sqliteRunLock).renew(); err != nil {
+		t.Fatalf("renew() failed: %v", err)
+	}
+	if _, err := AcquireRunLock(ctx, storage, "acc-1", RunLockSkip, time.Second); !errors.Is(err, ErrRunLockHeld) {
+		t.Fatalf("AcquireRunLock() after renewal = %v, want ErrRunLockHeld", err)
+	}
+
+	// Waiting fails rather than skips once the timeout expires
+	if _, err := AcquireRunLock(ctx, storage, "acc-1", RunLockWait, 0); !errors.Is(err, ErrRunLockTimeout) || errors.Is(err, ErrRunLockHeld) {
+		t.Fatalf("AcquireRunLock(wait) = %v, want ErrRunLockTimeout", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release() failed: %v", err)
+	}
+	next, err := AcquireRunLock(ctx, storage, "acc-1", RunLockSkip, time.Second)
+	if err != nil {
+		t.Fatalf("AcquireRunLock() after release failed: %v", err)
+	}
+	if err := next.Release(); err != nil {
+		t.Fatalf("Release() failed: %v", err)
+	}
+
+	// A lease that expired and was taken over cannot be renewed
+	stale := &sqliteRunLock{db: storage.DB, key: "stale", holder: "gone"}
+	if err := stale.renew(); err == nil {
+		t.Error("renew() of a lease that is not held succeeded, want error")
+	}
+}
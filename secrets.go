@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// secretCommandTimeout bounds how long a *_COMMAND helper may run (it may prompt for a GPG passphrase)
+var secretCommandTimeout = 60 * time.Second
+
+// secretSources lists the variables a secret NAME can be read from, in order of precedence
+func secretSources(name string) []string {
+	return []string{name + "_FILE", name + "_COMMAND", name}
+}
+
+// secretConfigured reports whether any source is configured for the secret
+func secretConfigured(config AccountConfig, name string) bool {
+	for _, source := range secretSources(name) {
+		if config.Getenv(source) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// loadSecret reads a secret of an account from one of:
+//   - NAME_FILE: path to a file holding the secret (Docker/Kubernetes secret mounts)
+//   - NAME_COMMAND: shell command printing the secret on the first line of stdout (e.g. "pass show n26")
+//   - NAME: the secret itself, from the environment or .env file
+//
+// Configuring more than one of them is an error. The caller owns the returned
+// slice and should pass it to zeroSecret once the secret is no longer needed.
+func loadSecret(config AccountConfig, name string) ([]byte, error) {
+	var configured []string
+	for _, source := range secretSources(name) {
+		if config.Getenv(source) != "" {
+			configured = append(configured, source)
+		}
+	}
+
+	switch len(configured) {
+	case 0:
+		return nil, fmt.Errorf("%s is not set (set %s, %s_FILE or %s_COMMAND)", name, name, name, name)
+	case 1:
+	default:
+		return nil, fmt.Errorf("only one of %v may be set", configured)
+	}
+
+	var secret []byte
+	var err error
+	switch source := configured[0]; source {
+	case name + "_FILE":
+		data, err := os.ReadFile(config.Getenv(source))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", source, err)
+		}
+		secret = bytes.Clone(firstLine(data))
+		zeroSecret(data)
+	case name + "_COMMAND":
+		secret, err = runSecretCommand(config.Getenv(source))
+		if err != nil {
+			return nil, fmt.Errorf("failed to run %s: %w", source, err)
+		}
+	default:
+		secret = []byte(config.Getenv(source))
+	}
+
+	if len(secret) == 0 {
+		zeroSecret(secret)
+		return nil, fmt.Errorf("%s is empty", configured[0])
+	}
+	return secret, nil
+}
+
+// loadSecretString reads a secret that has to be handed to an API taking strings.
+// Go strings are immutable and cannot be zeroed, so prefer loadSecret where the
+// consumer accepts bytes.
+func loadSecretString(config AccountConfig, name string) (string, error) {
+	secret, err := loadSecret(config, name)
+	if err != nil {
+		return "", err
+	}
+	defer zeroSecret(secret)
+	return string(secret), nil
+}
+
+// runSecretCommand runs a helper through the shell and returns the first line of its output.
+// The helper's stderr is passed through so it can prompt (e.g. for a GPG passphrase).
+func runSecretCommand(command string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	// Processes the helper started may keep stdout open after it is killed, stop waiting for them
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	output := stdout.Bytes()
+	if ctx.Err() == context.DeadlineExceeded {
+		zeroSecret(output)
+		return nil, fmt.Errorf("timed out after %s", secretCommandTimeout)
+	}
+	if err != nil {
+		zeroSecret(output)
+		return nil, err
+	}
+
+	secret := bytes.Clone(firstLine(output))
+	zeroSecret(output)
+	return secret, nil
+}
+
+// firstLine returns the first line of data without its line ending.
+// Password managers like pass store the secret on the first line.
+func firstLine(data []byte) []byte {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		data = data[:i]
+	}
+	return bytes.TrimRight(data, "\r")
+}
+
+// zeroSecret overwrites a secret in memory once it is no longer needed
+func zeroSecret(secret []byte) {
+	for i := range secret {
+		secret[i] = 0
+	}
+}
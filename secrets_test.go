@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadSecret(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr string
+	}{
+		{name: "value", env: map[string]string{"TEST_SECRET": "s3cret"}, want: "s3cret"},
+		{name: "file", env: map[string]string{"TEST_SECRET_FILE": writeFile("plain", "s3cret")}, want: "s3cret"},
+		{name: "file with trailing newline", env: map[string]string{"TEST_SECRET_FILE": writeFile("newline", "s3cret\n")}, want: "s3cret"},
+		{name: "file with CRLF and more lines", env: map[string]string{"TEST_SECRET_FILE": writeFile("crlf", "s3cret\r\nsecond line\r\n")}, want: "s3cret"},
+		{name: "command", env: map[string]string{"TEST_SECRET_COMMAND": "printf 's3cret\\nuser: me\\n'"}, want: "s3cret"},
+		{name: "command with CRLF", env: map[string]string{"TEST_SECRET_COMMAND": "printf 's3cret\\r\\n'"}, want: "s3cret"},
+		{name: "nothing set", wantErr: "is not set"},
+		{name: "value and file", env: map[string]string{"TEST_SECRET": "s3cret", "TEST_SECRET_FILE": writeFile("both", "other")}, wantErr: "only one of"},
+		{name: "file and command", env: map[string]string{"TEST_SECRET_FILE": writeFile("both2", "other"), "TEST_SECRET_COMMAND": "echo other"}, wantErr: "only one of"},
+		{name: "empty file", env: map[string]string{"TEST_SECRET_FILE": writeFile("empty", "\n")}, wantErr: "is empty"},
+		{name: "empty command output", env: map[string]string{"TEST_SECRET_COMMAND": "true"}, wantErr: "is empty"},
+		{name: "missing file", env: map[string]string{"TEST_SECRET_FILE": filepath.Join(dir, "missing")}, wantErr: "failed to read TEST_SECRET_FILE"},
+		{name: "failing command", env: map[string]string{"TEST_SECRET_COMMAND": "echo s3cret; exit 3"}, wantErr: "failed to run TEST_SECRET_COMMAND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, source := range secretSources("TEST_SECRET") {
+				t.Setenv(source, tt.env[source])
+			}
+
+			secret, err := loadSecret(globalConfig, "TEST_SECRET")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("loadSecret() = %q, %v, want error containing %q", secret, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || string(secret) != tt.want {
+				t.Errorf("loadSecret() = %q, %v, want %q", secret, err, tt.want)
+			}
+
+			text, err := loadSecretString(globalConfig, "TEST_SECRET")
+			if err != nil || text != tt.want {
+				t.Errorf("loadSecretString() = %q, %v, want %q", text, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadSecretFromAccountConfig(t *testing.T) {
+	t.Setenv("TEST_SECRET", "global")
+	t.Setenv("ACCOUNT_ALICE_TEST_SECRET_COMMAND", "echo alice")
+	secret, err := loadSecret(loadAccountConfig("alice"), "TEST_SECRET")
+	if err != nil || string(secret) != "alice" {
+		t.Errorf("loadSecret(alice) = %q, %v, want the account's command to replace the global value", secret, err)
+	}
+	if !secretConfigured(loadAccountConfig("bob"), "TEST_SECRET") {
+		t.Error("secretConfigured(bob) = false, want the global value")
+	}
+}
+
+func TestRunSecretCommandTimeout(t *testing.T) {
+	timeout := secretCommandTimeout
+	secretCommandTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { secretCommandTimeout = timeout })
+
+	start := time.Now()
+	secret, err := runSecretCommand("echo early; sleep 10")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("runSecretCommand() = %q, %v, want a timeout error", secret, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("runSecretCommand() returned after %s, want soon after the timeout", elapsed)
+	}
+}
+
+func TestFirstLine(t *testing.T) {
+	tests := map[string]string{
+		"":                 "",
+		"s3cret":           "s3cret",
+		"s3cret\n":         "s3cret",
+		"s3cret\r\n":       "s3cret",
+		"s3cret\nsecond":   "s3cret",
+		"s3cret\r\nsecond": "s3cret",
+		"\nsecond":         "",
+		"with space \n":    "with space ",
+	}
+	for data, want := range tests {
+		if got := string(firstLine([]byte(data))); got != want {
+			t.Errorf("firstLine(%q) = %q, want %q", data, got, want)
+		}
+	}
+}
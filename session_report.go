@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+)
+
+// CookieRetentionPolicy controls how long old cookie rows are kept
+type CookieRetentionPolicy struct {
+	MaxAge time.Duration // Cookies older than this are pruned (0 disables pruning)
+	Keep   int           // The most recent cookies are always kept, regardless of age
+}
+
+// SessionStats summarises how long stored cookies stayed valid
+type SessionStats struct {
+	Total          int
+	Active         int // Sessions that were never rejected by the endpoint
+	Expired        int // Sessions that were rejected by the endpoint
+	MinLifetime    time.Duration
+	MaxLifetime    time.Duration
+	AvgLifetime    time.Duration
+	MedianLifetime time.Duration
+	LoginsPer30d   float64   // How many logins (and 2FA prompts) happened per 30 days since Since
+	Since          time.Time // Creation of the oldest session the cookie retention kept
+}
+
+// loadCookieRetentionPolicy reads the retention policy from COOKIE_RETENTION_DAYS and COOKIE_RETENTION_KEEP
+func loadCookieRetentionPolicy() CookieRetentionPolicy {
+	days := globalConfig.envInt("COOKIE_RETENTION_DAYS", 90)
+	keep := globalConfig.envInt("COOKIE_RETENTION_KEEP", 5)
+	if keep < 1 {
+		keep = 1
+	}
+	if days < 0 {
+		days = 0
+	}
+
+	return CookieRetentionPolicy{
+		MaxAge: time.Duration(days) * 24 * time.Hour,
+		Keep:   keep,
+	}
+}
+
+// sessionLifetime returns how long a session is known to have been valid.
+// Expired sessions are measured until they were rejected, active ones until their last successful use.
+func sessionLifetime(session CookieSession) time.Duration {
+	switch {
+	case session.InvalidatedAt != nil:
+		return session.InvalidatedAt.Sub(session.CreatedAt)
+	case session.LastValidatedAt != nil:
+		return session.LastValidatedAt.Sub(session.CreatedAt)
+	default:
+		return 0
+	}
+}
+
+// computeSessionStats aggregates lifetime statistics over the expired sessions. The cookie
+// retention prunes old sessions, so the login rate only covers the window it kept.
+func computeSessionStats(sessions []CookieSession, now time.Time) SessionStats {
+	stats := SessionStats{Total: len(sessions)}
+	if len(sessions) == 0 {
+		return stats
+	}
+
+	var lifetimes []time.Duration
+	var total time.Duration
+	oldest := sessions[0].CreatedAt
+	for _, session := range sessions {
+		if session.CreatedAt.Before(oldest) {
+			oldest = session.CreatedAt
+		}
+		if session.InvalidatedAt == nil {
+			stats.Active++
+			continue
+		}
+
+		stats.Expired++
+		lifetime := sessionLifetime(session)
+		lifetimes = append(lifetimes, lifetime)
+		total += lifetime
+	}
+
+	if len(lifetimes) > 0 {
+		slices.Sort(lifetimes)
+		stats.MinLifetime = lifetimes[0]
+		stats.MaxLifetime = lifetimes[len(lifetimes)-1]
+		stats.AvgLifetime = total / time.Duration(len(lifetimes))
+		stats.MedianLifetime = lifetimes[len(lifetimes)/2]
+		if len(lifetimes)%2 == 0 {
+			stats.MedianLifetime = (lifetimes[len(lifetimes)/2-1] + lifetimes[len(lifetimes)/2]) / 2
+		}
+	}
+
+	stats.Since = oldest
+	if span := now.Sub(oldest); span > 0 {
+		stats.LoginsPer30d = float64(len(sessions)) / span.Hours() * 24 * 30
+	}
+
+	return stats
+}
+
+// printSessionReport writes the per-session table and aggregate statistics
+func printSessionReport(w io.Writer, sessions []CookieSession, now time.Time) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCREATED\tFIRST USED\tLAST VALIDATED\tINVALIDATED\tLIFETIME")
+	for _, session := range sessions {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			session.ID,
+			session.CreatedAt.UTC().Format(time.RFC3339),
+			formatOptionalTime(session.FirstUsedAt),
+			formatOptionalTime(session.LastValidatedAt),
+			formatOptionalTime(session.InvalidatedAt),
+			formatLifetime(sessionLifetime(session)),
+		)
+	}
+	tw.Flush()
+
+	stats := computeSessionStats(sessions, now)
+	fmt.Fprintf(w, "\nSessions: %d (active: %d, expired: %d)\n", stats.Total, stats.Active, stats.Expired)
+	if stats.Expired > 0 {
+		fmt.Fprintf(w, "Lifetime of expired sessions: min %s, median %s, avg %s, max %s\n",
+			formatLifetime(stats.MinLifetime),
+			formatLifetime(stats.MedianLifetime),
+			formatLifetime(stats.AvgLifetime),
+			formatLifetime(stats.MaxLifetime),
+		)
+	}
+	if stats.Total > 0 {
+		fmt.Fprintf(w, "Logins (2FA prompts) per 30 days since %s (the sessions the retention kept): %.1f\n",
+			stats.Since.UTC().Format(time.DateOnly), stats.LoginsPer30d)
+	}
+}
+
+// formatOptionalTime formats a nullable timestamp for the report
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// formatLifetime formats a lifetime as days and hours
+func formatLifetime(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	days := int(d / (24 * time.Hour))
+	hours := int((d % (24 * time.Hour)) / time.Hour)
+	if days == 0 {
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dd %dh", days, hours)
+}
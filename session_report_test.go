@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionReportLoginRateCoversTheRetainedWindow(t *testing.T) {
+	now := time.Date(2025, time.October, 31, 0, 0, 0, 0, time.UTC)
+	var sessions []CookieSession
+	for days := 60; days > 0; days -= 10 {
+		created := now.AddDate(0, 0, -days)
+		invalidated := created.AddDate(0, 0, 10)
+		sessions = append(sessions, CookieSession{CreatedAt: created, InvalidatedAt: &invalidated})
+	}
+
+	stats := computeSessionStats(sessions, now)
+	if !stats.Since.Equal(now.AddDate(0, 0, -60)) || stats.LoginsPer30d != 3 {
+		t.Errorf("computeSessionStats() = %.1f logins per 30 days since %s, want 3 since 60 days ago", stats.LoginsPer30d, stats.Since)
+	}
+
+	var report bytes.Buffer
+	printSessionReport(&report, sessions, now)
+	if !strings.Contains(report.String(), "per 30 days since 2025-09-01 (the sessions the retention kept): 3.0") {
+		t.Errorf("printSessionReport() = %q, want the rate labelled with its window", report.String())
+	}
+}
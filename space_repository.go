@@ -0,0 +1,88 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// SpaceRepository stores the N26 Spaces listed for an account, so listings and spending
+// checks can name them without asking N26
+type SpaceRepository interface {
+	ListSpaces() ([]Space, error)
+	ReplaceSpaces(spaces []Space) error
+}
+
+// SQLSpaceRepository implements SpaceRepository on both PostgreSQL and SQLite,
+// the queries only use syntax the two have in common
+type SQLSpaceRepository struct {
+	db      *sql.DB
+	account string
+}
+
+// NewSQLSpaceRepository creates a space repository for an account on a migrated database
+func NewSQLSpaceRepository(db *sql.DB, account string) *SQLSpaceRepository {
+	return &SQLSpaceRepository{db: db, account: account}
+}
+
+// ListSpaces returns the stored spaces, the main account first and the others by name
+func (r *SQLSpaceRepository) ListSpaces() ([]Space, error) {
+	query := `
+		SELECT space_id, name, is_primary
+		FROM spaces
+		WHERE account = $1
+		ORDER BY is_primary DESC, name ASC, space_id ASC
+	`
+	rows, err := r.db.Query(query, r.account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list spaces: %w", err)
+	}
+	defer rows.Close()
+
+	var spaces []Space
+	for rows.Next() {
+		var space Space
+		if err := rows.Scan(&space.ID, &space.Name, &space.Primary); err != nil {
+			return nil, fmt.Errorf("failed to scan space: %w", err)
+		}
+		spaces = append(spaces, space)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list spaces: %w", err)
+	}
+	return spaces, nil
+}
+
+// ReplaceSpaces replaces the stored spaces with a freshly listed set
+func (r *SQLSpaceRepository) ReplaceSpaces(spaces []Space) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM spaces WHERE account = $1`, r.account); err != nil {
+		return fmt.Errorf("failed to clear spaces: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO spaces (account, space_id, name, is_primary, updated_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		ON CONFLICT (account, space_id)
+		DO UPDATE SET name = EXCLUDED.name, is_primary = EXCLUDED.is_primary, updated_at = CURRENT_TIMESTAMP
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare space insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, space := range spaces {
+		if _, err := stmt.Exec(r.account, space.ID, space.Name, space.Primary); err != nil {
+			return fmt.Errorf("failed to store space: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit spaces: %w", err)
+	}
+	return nil
+}
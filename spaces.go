@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	spacesEndpoint = "https://app.n26.com/api/spaces"
+)
+
+// mainSpaceName labels the main account when N26 does not name its primary space
+const mainSpaceName = "Main Account"
+
+// Space is an N26 Space, a sub-account with its own balance and statement. The main account is
+// the primary space.
+type Space struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Primary bool   `json:"isPrimary"`
+}
+
+// mainSpace returns the primary space of the account, named mainSpaceName until the spaces are listed
+func mainSpace(accountID string) Space {
+	return Space{ID: accountID, Name: mainSpaceName, Primary: true}
+}
+
+// isMain reports whether the space is the main account. The zero Space is the main account too.
+func (s Space) isMain() bool {
+	return s.Primary || s.ID == ""
+}
+
+// scope returns the value stored in the space columns of its statements and transactions: empty
+// for the main account, whose history predates spaces, the space ID otherwise
+func (s Space) scope() string {
+	if s.isMain() {
+		return ""
+	}
+	return s.ID
+}
+
+// label returns how notifications name the space, empty for the main account
+func (s Space) label() string {
+	if s.isMain() {
+		return ""
+	}
+	return s.Name
+}
+
+// fetchSpaces lists the spaces of the logged-in user, the main account included
+func fetchSpaces(cookieHeader string) ([]Space, error) {
+	body, err := fetchWithCookie(spacesEndpoint, cookieHeader)
+	if err != nil {
+		return nil, err
+	}
+	return parseSpaces(body)
+}
+
+// parseSpaces reads the spaces response, either {"spaces": [...]} or a bare list
+func parseSpaces(body []byte) ([]Space, error) {
+	var spaces []Space
+	if err := json.Unmarshal(body, &spaces); err != nil {
+		var response struct {
+			Spaces []Space `json:"spaces"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse spaces: %w", err)
+		}
+		spaces = response.Spaces
+	}
+
+	valid := spaces[:0]
+	for _, space := range spaces {
+		if space.ID == "" {
+			continue
+		}
+		space.Name = strings.TrimSpace(space.Name)
+		valid = append(valid, space)
+	}
+	return valid, nil
+}
+
+// selectSpaces returns the spaces besides the main account whose statements are downloaded,
+// as configured in N26_SPACES: "all" (default), "main" for the main account only, or a
+// comma-separated list of space names or IDs
+func selectSpaces(config AccountConfig, spaces []Space, accountID string) []Space {
+	value := strings.TrimSpace(config.Getenv("N26_SPACES"))
+	var wanted []string
+	switch strings.ToLower(value) {
+	case "", "all":
+	case "main", "off", "none":
+		return nil
+	default:
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.ToLower(strings.TrimSpace(part)); part != "" {
+				wanted = append(wanted, part)
+			}
+		}
+	}
+
+	var selected []Space
+	found := make(map[string]bool)
+	for _, space := range spaces {
+		if space.Primary || space.ID == accountID {
+			continue
+		}
+		if wanted != nil {
+			name, id := strings.ToLower(space.Name), strings.ToLower(space.ID)
+			if !slices.Contains(wanted, name) && !slices.Contains(wanted, id) {
+				continue
+			}
+			found[name], found[id] = true, true
+		}
+		selected = append(selected, space)
+	}
+	for _, name := range wanted {
+		if !found[name] {
+			log.Printf("Warning: Unknown space %q in N26_SPACES", name)
+		}
+	}
+	return selected
+}
+
+// primarySpace returns the main account among the listed spaces, falling back to the configured
+// account ID when N26 does not mark one as primary
+func primarySpace(spaces []Space, accountID string) Space {
+	for _, space := range spaces {
+		if space.Primary || (accountID != "" && space.ID == accountID) {
+			space.Primary = true
+			if space.Name == "" {
+				space.Name = mainSpaceName
+			}
+			return space
+		}
+	}
+	return mainSpace(accountID)
+}
+
+// listSpending returns the stored transactions booked between from and to that spend or earn
+// money: those of the main account except transfers to and from the user's own spaces. Money
+// moved into a savings space is not spent, so budgets, subscriptions, anomalies and digests
+// only look at these.
+func listSpending(storage *Storage, from, to time.Time) ([]StoredTransaction, error) {
+	transactions, err := storage.Transactions.ListTransactions(from, to)
+	if err != nil {
+		return nil, err
+	}
+	spaces, err := storage.Spaces.ListSpaces()
+	if err != nil {
+		return nil, err
+	}
+	return spendingTransactions(transactions, spaces), nil
+}
+
+// spendingTransactions drops the transactions of other spaces than the main account, and the
+// transfers of the main account whose partner is one of the spaces
+func spendingTransactions(transactions []StoredTransaction, spaces []Space) []StoredTransaction {
+	own := make(map[string]bool, len(spaces))
+	for _, space := range spaces {
+		if !space.Primary {
+			own[normalizePartnerName(space.Name)] = true
+		}
+	}
+
+	var spending []StoredTransaction
+	for _, t := range spaceTransactions(transactions, "") {
+		if !own[normalizePartnerName(t.PartnerName)] {
+			spending = append(spending, t)
+		}
+	}
+	return spending
+}
+
+// spaceTransactions returns the transactions stored for a space, by the value of its space column
+func spaceTransactions(transactions []StoredTransaction, space string) []StoredTransaction {
+	var filtered []StoredTransaction
+	for _, t := range transactions {
+		if t.Space == space {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered
+}
+
+// listSpaces lists and stores the spaces of the logged-in user, returning the main account and the
+// other spaces to download. When N26 does not list them only the main account is downloaded.
+func listSpaces(config AccountConfig, cookieHeader, accountID string, storage *Storage) (Space, []Space) {
+	spaces, err := fetchSpaces(cookieHeader)
+	if err != nil {
+		log.Printf("Warning: Failed to list spaces, only the main account is downloaded: %v", err)
+		return mainSpace(accountID), nil
+	}
+	if err := storage.Spaces.ReplaceSpaces(spaces); err != nil {
+		log.Printf("Warning: Failed to store spaces: %v", err)
+	}
+
+	main := primarySpace(spaces, accountID)
+	if accountID != "" {
+		// Balance snapshots of the main account are recorded under the configured ID
+		main.ID = accountID
+	}
+	selected := selectSpaces(config, spaces, accountID)
+	fmt.Printf("Found %d spaces, downloading %d besides the main account\n", len(spaces), len(selected))
+	return main, selected
+}
+
+// processSpaces downloads and processes the statement of every space. A space that fails is
+// logged and does not stop the others.
+func processSpaces(config AccountConfig, cookieHeader string, period StatementPeriod, spaces []Space, storage *Storage) {
+	for _, space := range spaces {
+		fmt.Printf("Downloading the statement of space %s...\n", space.Name)
+		pdfData, err := callEndpointWithCookie(cookieHeader, space.ID, period)
+		if err != nil {
+			log.Printf("Warning: Failed to download the statement of space %s: %v", space.Name, err)
+			continue
+		}
+		if err := processStatement(config, pdfData, period, space, storage); err != nil {
+			log.Printf("Warning: Failed to process the statement of space %s: %v", space.Name, err)
+		}
+	}
+}
+
+// spaceNames maps the space column values of stored transactions to the names of the spaces
+func spaceNames(spaces []Space) map[string]string {
+	names := map[string]string{"": mainSpaceName}
+	for _, space := range spaces {
+		if space.Name == "" {
+			continue
+		}
+		if space.Primary {
+			names[""] = space.Name
+		} else {
+			names[space.ID] = space.Name
+		}
+	}
+	return names
+}
+
+// spaceName returns the name of the space a stored transaction belongs to, its ID when unknown
+func spaceName(names map[string]string, space string) string {
+	if name, ok := names[space]; ok {
+		return name
+	}
+	return space
+}
+
+// findSpace returns the stored space with the given name or ID, ignoring case
+func findSpace(spaces []Space, name string) (Space, bool) {
+	for _, space := range spaces {
+		if strings.EqualFold(space.Name, name) || strings.EqualFold(space.ID, name) {
+			return space, true
+		}
+	}
+	return Space{}, false
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseSpaces(t *testing.T) {
+	want := []Space{{ID: "space-main", Name: "Main Account", Primary: true}, {ID: "space-savings", Name: "Savings"}}
+	for _, body := range []string{
+		`{"spaces": [{"id": "space-main", "name": "Main Account", "isPrimary": true}, {"id": "space-savings", "name": " Savings "}, {"name": "No ID"}]}`,
+		`[{"id": "space-main", "name": "Main Account", "isPrimary": true}, {"id": "space-savings", "name": "Savings"}]`,
+	} {
+		got, err := parseSpaces([]byte(body))
+		if err != nil {
+			t.Fatalf("parseSpaces(%s) error: %v", body, err)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("parseSpaces(%s) = %+v, want %+v", body, got, want)
+		}
+	}
+
+	if _, err := parseSpaces([]byte(`<html>login</html>`)); err == nil {
+		t.Error("parseSpaces(html) succeeded, want error")
+	}
+}
+
+func TestSelectSpaces(t *testing.T) {
+	spaces := []Space{
+		{ID: "space-main", Name: "Main Account", Primary: true},
+		{ID: "space-savings", Name: "Savings"},
+		{ID: "space-travel", Name: "Travel"},
+	}
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{value: "", want: []string{"space-savings", "space-travel"}},
+		{value: "all", want: []string{"space-savings", "space-travel"}},
+		{value: "main", want: nil},
+		{value: "savings, space-travel", want: []string{"space-savings", "space-travel"}},
+		{value: "Travel,Holidays", want: []string{"space-travel"}},
+	}
+	for _, tt := range tests {
+		t.Setenv("N26_SPACES", tt.value)
+		var got []string
+		for _, space := range selectSpaces(globalConfig, spaces, "space-main") {
+			got = append(got, space.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("selectSpaces(N26_SPACES=%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestStatementKeysAreScopedBySpace(t *testing.T) {
+	transfer := Transaction{BookingDate: statementDay("01.10.2025"), PartnerName: "Main Account", Amount: eur("100,00")}
+	inSpace := transfer
+	inSpace.Space = "space-savings"
+
+	keys := transactionKeys([]Transaction{transfer, inSpace})
+	if keys[0] != generateStatementKey(transfer.BookingDate, transfer.PartnerName, transfer.Amount, 1) {
+		t.Error("key of a main account transaction changed, it must stay the same as before spaces")
+	}
+	if keys[1] == keys[0] {
+		t.Error("a space transaction has the key of the same main account transaction")
+	}
+}
+
+func TestSpendingTransactionsSkipOtherSpacesAndOwnTransfers(t *testing.T) {
+	spaces := []Space{{ID: "space-main", Name: "Main Account", Primary: true}, {ID: "space-savings", Name: "Savings"}}
+	stored := []StoredTransaction{
+		{Transaction: Transaction{PartnerName: "Supermarket", Amount: eur("-45,10")}},
+		{Transaction: Transaction{PartnerName: "SAVINGS", Amount: eur("-200,00")}},
+		{Transaction: Transaction{PartnerName: "Main Account", Amount: eur("200,00"), Space: "space-savings"}},
+	}
+
+	got := spendingTransactions(stored, spaces)
+	if len(got) != 1 || got[0].PartnerName != "Supermarket" {
+		t.Errorf("spendingTransactions() = %+v, want only the supermarket", got)
+	}
+}
+
+func TestNotifyStatementLabelsAndSeparatesSpaces(t *testing.T) {
+	storage, err := OpenStorage("memory://")
+	if err != nil {
+		t.Fatalf("failed to open memory storage: %v", err)
+	}
+	discord := &fakeNotifier{channel: "discord"}
+	savings := Space{ID: "space-savings", Name: "Savings"}
+
+	// A savings statement whose delivery fails stays pending for the savings space only
+	spaceStatement := &ParsedStatement{Space: savings, Transactions: []Transaction{
+		{BookingDate: statementDay("01.10.2025"), PartnerName: "Main Account", Amount: eur("200,00"), Space: savings.scope()},
+	}}
+	discord.err = errors.New("webhook unavailable")
+	if err := notifyStatement(globalConfig, spaceStatement, storage, []Notifier{discord}, nil); err == nil {
+		t.Fatal("notifyStatement() with a failing channel succeeded, want error")
+	}
+	discord.err = nil
+
+	mainStatement := &ParsedStatement{Transactions: []Transaction{
+		{BookingDate: statementDay("01.10.2025"), PartnerName: "Savings", Amount: eur("-200,00")},
+	}}
+	if err := notifyStatement(globalConfig, mainStatement, storage, []Notifier{discord}, nil); err != nil {
+		t.Fatalf("notifyStatement(main) failed: %v", err)
+	}
+	if len(discord.sent) != 1 || discord.sent[0].Space != "" || len(discord.sent[0].Changes) != 1 || discord.sent[0].Changes[0].Record.Space != "" {
+		t.Fatalf("main account notifications = %+v, want only the main account transaction", discord.sent)
+	}
+
+	if err := notifyStatement(globalConfig, spaceStatement, storage, []Notifier{discord}, nil); err != nil {
+		t.Fatalf("notifyStatement(space) failed: %v", err)
+	}
+	if len(discord.sent) != 2 || discord.sent[1].Space != "Savings" || len(discord.sent[1].Changes) != 1 {
+		t.Errorf("space notifications = %+v, want the retried savings transaction labelled with its space", discord.sent[1:])
+	}
+}
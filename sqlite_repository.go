@@ -0,0 +1,224 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteCookieRepository implements CookieRepository using a single-file SQLite database
+type SQLiteCookieRepository struct {
+	db      *sql.DB
+	account string
+}
+
+// SQLiteStatementRepository implements StatementRepository using a single-file SQLite database
+type SQLiteStatementRepository struct {
+	db      *sql.DB
+	account string
+}
+
+// sqliteDSN adds the connection pragmas we rely on to a database path
+func sqliteDSN(path string) string {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	// busy_timeout lets concurrent runs wait for each other's writes instead of failing,
+	// _time_format stores time.Time parameters in a format SQLite can compare and parse back
+	return path + separator + "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite"
+}
+
+// openSQLiteDB opens (or creates) a SQLite database file, the schema is prepared by OpenStorage
+func openSQLiteDB(path string) (*sql.DB, error) {
+	if path == "" {
+		return nil, fmt.Errorf("SQLite database path is empty")
+	}
+
+	db, err := sql.Open("sqlite", sqliteDSN(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	return db, nil
+}
+
+// NewSQLiteCookieRepository creates a SQLite-based cookie repository for an account on a migrated database
+func NewSQLiteCookieRepository(db *sql.DB, account string) (*SQLiteCookieRepository, error) {
+	return &SQLiteCookieRepository{db: db, account: account}, nil
+}
+
+// GetDB returns the underlying database connection (for sharing with other repositories)
+func (r *SQLiteCookieRepository) GetDB() *sql.DB {
+	return r.db
+}
+
+// Get retrieves the most recent cookie from SQLite
+func (r *SQLiteCookieRepository) Get() (string, error) {
+	var cookieValue string
+	var updatedAt time.Time
+
+	query := `SELECT cookie_value, updated_at FROM cookies WHERE account = ? ORDER BY updated_at DESC, id DESC LIMIT 1`
+	err := r.db.QueryRow(query, r.account).Scan(&cookieValue, &updatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("no cookie found in database")
+		}
+		return "", fmt.Errorf("failed to get cookie: %w", err)
+	}
+
+	// Format timestamp and prepend to cookie value
+	timestamp := updatedAt.UTC().Format(time.RFC3339)
+	cookieWithTimestamp := fmt.Sprintf("TIMESTAMP=%s; %s", timestamp, cookieValue)
+
+	fmt.Printf("Cookie retrieved from SQLite (updated at: %s)\n", timestamp)
+	return cookieWithTimestamp, nil
+}
+
+// Save stores the cookie to SQLite with a timestamp
+func (r *SQLiteCookieRepository) Save(cookie string) error {
+	cookie = normalizeCookie(cookie)
+
+	query := `INSERT INTO cookies (account, cookie_value, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)`
+	if _, err := r.db.Exec(query, r.account, cookie); err != nil {
+		return fmt.Errorf("failed to save cookie: %w", err)
+	}
+
+	fmt.Println("Cookie saved to SQLite")
+	return nil
+}
+
+// MarkValidated records that the most recent cookie was accepted by the endpoint
+func (r *SQLiteCookieRepository) MarkValidated() error {
+	query := `
+		UPDATE cookies
+		SET first_used_at = COALESCE(first_used_at, CURRENT_TIMESTAMP),
+			last_validated_at = CURRENT_TIMESTAMP
+		WHERE id = (SELECT id FROM cookies WHERE account = ? ORDER BY updated_at DESC, id DESC LIMIT 1)
+	`
+	if _, err := r.db.Exec(query, r.account); err != nil {
+		return fmt.Errorf("failed to mark cookie as validated: %w", err)
+	}
+	return nil
+}
+
+// MarkInvalid records that the most recent cookie was rejected by the endpoint
+func (r *SQLiteCookieRepository) MarkInvalid() error {
+	query := `
+		UPDATE cookies
+		SET first_used_at = COALESCE(first_used_at, CURRENT_TIMESTAMP),
+			invalidated_at = COALESCE(invalidated_at, CURRENT_TIMESTAMP)
+		WHERE id = (SELECT id FROM cookies WHERE account = ? ORDER BY updated_at DESC, id DESC LIMIT 1)
+	`
+	if _, err := r.db.Exec(query, r.account); err != nil {
+		return fmt.Errorf("failed to mark cookie as invalid: %w", err)
+	}
+	return nil
+}
+
+// Sessions returns the lifecycle of every stored cookie, oldest first
+func (r *SQLiteCookieRepository) Sessions() ([]CookieSession, error) {
+	// Timestamps are selected as plain columns: the driver only converts them to
+	// time.Time when the column has a declared TIMESTAMP type
+	query := `
+		SELECT id, created_at, updated_at, first_used_at, last_validated_at, invalidated_at
+		FROM cookies
+		WHERE account = ?
+		ORDER BY created_at ASC, id ASC
+	`
+	rows, err := r.db.Query(query, r.account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cookie sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []CookieSession
+	for rows.Next() {
+		var session CookieSession
+		var createdAt, updatedAt, firstUsed, lastValidated, invalidated sql.NullTime
+		if err := rows.Scan(&session.ID, &createdAt, &updatedAt, &firstUsed, &lastValidated, &invalidated); err != nil {
+			return nil, fmt.Errorf("failed to scan cookie session: %w", err)
+		}
+		session.CreatedAt = createdAt.Time
+		if !createdAt.Valid {
+			session.CreatedAt = updatedAt.Time
+		}
+		session.FirstUsedAt = nullTimePtr(firstUsed)
+		session.LastValidatedAt = nullTimePtr(lastValidated)
+		session.InvalidatedAt = nullTimePtr(invalidated)
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list cookie sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// Close closes the database connection
+func (r *SQLiteCookieRepository) Close() error {
+	return r.db.Close()
+}
+
+// NewSQLiteStatementRepository creates a SQLite-based statement repository for an account
+func NewSQLiteStatementRepository(db *sql.DB, account string) (*SQLiteStatementRepository, error) {
+	// Migrations are handled by prepareSchema in OpenStorage
+	return &SQLiteStatementRepository{db: db, account: account}, nil
+}
+
+// IsNotified checks if a statement has already been notified
+func (r *SQLiteStatementRepository) IsNotified(key string) (bool, error) {
+	var notified bool
+	query := `SELECT notified FROM statements WHERE account = ? AND statement_key = ?`
+	err := r.db.QueryRow(query, r.account, key).Scan(&notified)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// Statement not found, means it hasn't been notified
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check if statement is notified: %w", err)
+	}
+
+	return notified, nil
+}
+
+// FilterUnnotified returns the keys that have not been notified yet, in their original order.
+// SQLite has no array parameters, so the keys are passed as one JSON array and expanded with json_each.
+func (r *SQLiteStatementRepository) FilterUnnotified(keys []string) ([]string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode statement keys: %w", err)
+	}
+
+	query := `SELECT statement_key FROM statements WHERE account = ? AND notified AND statement_key IN (SELECT value FROM json_each(?))`
+	rows, err := r.db.Query(query, r.account, string(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up notified statements: %w", err)
+	}
+	return unnotifiedKeys(keys, rows)
+}
+
+// MarkMultipleAsNotified marks multiple statements as notified in a single database transaction
+func (r *SQLiteStatementRepository) MarkMultipleAsNotified(records []StatementRecord) error {
+	return markStatementsNotified(r.db, r.account, records, 1)
+}
+
+// FindOutdated returns notified statements of a space with the given booking date and amount
+// whose key was generated by a key version older than version
+func (r *SQLiteStatementRepository) FindOutdated(space string, bookingDate time.Time, amount Money, version int) ([]StatementRecord, error) {
+	return findOutdatedStatements(r.db, r.account, space, bookingDate, amount, version)
+}
+
+// Rekey moves a notified statement to the key of the current key version
+func (r *SQLiteStatementRepository) Rekey(oldKey string, record StatementRecord) error {
+	return rekeyStatement(r.db, r.account, oldKey, record)
+}
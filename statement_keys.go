@@ -0,0 +1,289 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Statement keys identify a transaction across runs. They are the SHA-256 of the
+// key version, booking date, partner, amount and the occurrence of that combination
+// within the statement, so two identical coffees on the same day get different keys
+// and long partner names never overflow the key column.
+//
+// Any change to how transactions are parsed (partner name extraction in particular)
+// changes the keys. Such changes must bump statementKeyVersion: notified statements of
+// older versions are then matched to the new keys by reconcileStatementKeys instead of
+// every transaction in the window being notified again.
+
+// statementKeyVersion is the version of the key algorithm and transaction parser. Version 3
+// hashes the amount in cents instead of the text the statement shows.
+const statementKeyVersion = 3
+
+// partnerMatchThreshold is the minimum partner similarity for two keys of different
+// versions with the same booking date and amount to be treated as the same transaction
+const partnerMatchThreshold = 0.8
+
+// generateStatementKey creates a fixed-length key for the occurrence-th transaction
+// (starting at 1) with this date, partner and amount in a statement
+func generateStatementKey(date time.Time, partner string, amount Money, occurrence int) string {
+	version := "v" + strconv.Itoa(statementKeyVersion)
+	sum := sha256.Sum256([]byte(strings.Join([]string{version, formatStatementDate(date), partner, amount.decimal(), strconv.Itoa(occurrence)}, "|")))
+	return hex.EncodeToString(sum[:])
+}
+
+// spaceStatementKey scopes a statement key to an N26 Space other than the main account, so a
+// transaction of a space never shares its key with one of the main account
+func spaceStatementKey(space, key string) string {
+	sum := sha256.Sum256([]byte(space + "|" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// transactionKeys returns the statement key of every transaction, in order.
+// Identical transactions are numbered by their position in the statement.
+func transactionKeys(transactions []Transaction) []string {
+	occurrences := make(map[string]int)
+	keys := make([]string, len(transactions))
+	for i, t := range transactions {
+		identity := strings.Join([]string{t.Space, formatStatementDate(t.BookingDate), t.PartnerName, t.Amount.decimal()}, "|")
+		occurrences[identity]++
+		keys[i] = generateStatementKey(t.BookingDate, t.PartnerName, t.Amount, occurrences[identity])
+		if t.Space != "" {
+			keys[i] = spaceStatementKey(t.Space, keys[i])
+		}
+	}
+	return keys
+}
+
+// statementRecords returns the statement record of every transaction, in order
+func statementRecords(transactions []Transaction) []StatementRecord {
+	keys := transactionKeys(transactions)
+	records := make([]StatementRecord, len(transactions))
+	for i, t := range transactions {
+		records[i] = StatementRecord{
+			Key:         keys[i],
+			KeyVersion:  statementKeyVersion,
+			BookingDate: t.BookingDate,
+			PartnerName: t.PartnerName,
+			Amount:      t.Amount,
+			Category:    t.Category,
+			Space:       t.Space,
+		}
+	}
+	return records
+}
+
+// recordKeys returns the keys of the records, in order
+func recordKeys(records []StatementRecord) []string {
+	keys := make([]string, len(records))
+	for i, record := range records {
+		keys[i] = record.Key
+	}
+	return keys
+}
+
+// reconcileStatementKeys moves statements notified under an older key version to the
+// current keys of the same transactions, so a parser upgrade does not re-notify them.
+// A transaction matches an outdated statement with the same booking date and amount
+// and a similar partner name; the most similar one wins. Returns how many were moved.
+func reconcileStatementKeys(storage *Storage, transactions []Transaction) (int, error) {
+	records := statementRecords(transactions)
+	unnotified, err := storage.Statements.FilterUnnotified(recordKeys(records))
+	if err != nil {
+		return 0, err
+	}
+	isNew := make(map[string]bool, len(unnotified))
+	for _, key := range unnotified {
+		isNew[key] = true
+	}
+
+	moved := 0
+	for _, record := range records {
+		if !isNew[record.Key] {
+			continue
+		}
+
+		candidates, err := storage.Statements.FindOutdated(record.Space, record.BookingDate, record.Amount, statementKeyVersion)
+		if err != nil {
+			return moved, err
+		}
+
+		var best *StatementRecord
+		bestScore := partnerMatchThreshold
+		for i := range candidates {
+			if score := partnerSimilarity(candidates[i].PartnerName, record.PartnerName); score >= bestScore {
+				best, bestScore = &candidates[i], score
+			}
+		}
+		if best == nil {
+			continue
+		}
+
+		if err := moveStatementKey(storage, best.Key, record); err != nil {
+			return moved, err
+		}
+		log.Printf("Matched statement key v%d %q to v%d %q (partner similarity %.2f)",
+			best.KeyVersion, best.PartnerName, record.KeyVersion, record.PartnerName, bestScore)
+		moved++
+	}
+	return moved, nil
+}
+
+// partnerSimilarity scores how alike two partner names are, from 0 (unrelated) to 1 (equal).
+// Names are compared case- and punctuation-insensitively; a name contained in the other
+// (e.g. "AMAZON" and "AMAZON EU SARL") counts as very similar.
+func partnerSimilarity(a, b string) float64 {
+	a, b = normalizePartnerName(a), normalizePartnerName(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	if strings.Contains(a, b) || strings.Contains(b, a) {
+		return 0.9
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	return 1 - float64(levenshtein(ra, rb))/float64(max(len(ra), len(rb)))
+}
+
+// normalizePartnerName lowercases a partner name and collapses everything that is not
+// a letter or digit into single spaces
+func normalizePartnerName(name string) string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// parseLegacyStatementKey splits a key of the original "date|partner|amount" scheme.
+// The partner name may itself contain "|", so date and amount are taken from the ends.
+func parseLegacyStatementKey(key string) (date, partner, amount string, ok bool) {
+	first := strings.Index(key, "|")
+	last := strings.LastIndex(key, "|")
+	if first < 0 || first == last {
+		return "", "", "", false
+	}
+	return key[:first], key[first+1 : last], key[last+1:], true
+}
+
+// upgradeLegacyStatementKeys rewrites "date|partner|amount" keys to the hashed scheme so
+// statements notified before the upgrade are not notified again. The legacy scheme could
+// only store one of several identical transactions, so they all become occurrence 1.
+// SQLite cannot hash in SQL, which is why this runs in Go after the schema migrations.
+func upgradeLegacyStatementKeys(db *sql.DB) (int, error) {
+	upgraded := 0
+	for _, table := range []string{"statements", "transactions"} {
+		count, err := upgradeLegacyKeysInTable(db, table)
+		if err != nil {
+			return upgraded, err
+		}
+		upgraded += count
+	}
+	return upgraded, nil
+}
+
+// upgradeLegacyKeysInTable rewrites the legacy keys of one table in a single transaction
+func upgradeLegacyKeysInTable(db *sql.DB, table string) (int, error) {
+	rows, err := db.Query(fmt.Sprintf(`SELECT statement_key FROM %s WHERE statement_key LIKE '%%|%%'`, table))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find legacy keys in %s: %w", table, err)
+	}
+	var legacyKeys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan legacy key: %w", err)
+		}
+		legacyKeys = append(legacyKeys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to find legacy keys in %s: %w", table, err)
+	}
+	if len(legacyKeys) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin key upgrade: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Rename the row unless the new key already exists, then drop whatever legacy row is left
+	rename := fmt.Sprintf(`
+		UPDATE %[1]s SET statement_key = $1
+		WHERE statement_key = $2
+		AND NOT EXISTS (SELECT 1 FROM %[1]s AS upgraded WHERE upgraded.account = %[1]s.account AND upgraded.statement_key = $1)
+	`, table)
+	remove := fmt.Sprintf(`DELETE FROM %s WHERE statement_key = $1`, table)
+
+	upgraded := 0
+	for _, legacyKey := range legacyKeys {
+		dateText, partner, amountText, ok := parseLegacyStatementKey(legacyKey)
+		if !ok {
+			continue
+		}
+		date, dateErr := parseStatementDate(dateText)
+		amount, err := parseMoney(amountText, statementCurrency)
+		if dateErr != nil || err != nil {
+			// The parser never produces such a date or amount, so no current key can match it
+			continue
+		}
+		newKey := generateStatementKey(date, partner, amount, 1)
+		if _, err := tx.Exec(rename, newKey, legacyKey); err != nil {
+			return 0, fmt.Errorf("failed to upgrade key in %s: %w", table, err)
+		}
+		if table == "statements" {
+			// Keep the key fields so later key versions can be matched against this statement
+			record := StatementRecord{Key: newKey, KeyVersion: statementKeyVersion, BookingDate: date, PartnerName: partner, Amount: amount}
+			bookingDate, amountValue := statementRecordColumns(record)
+			if _, err := tx.Exec(
+				`UPDATE statements SET key_version = $1, booking_date = $2, partner_name = $3, amount = $4 WHERE statement_key = $5`,
+				record.KeyVersion, bookingDate, record.PartnerName, amountValue, newKey,
+			); err != nil {
+				return 0, fmt.Errorf("failed to store key fields: %w", err)
+			}
+		}
+		if _, err := tx.Exec(remove, legacyKey); err != nil {
+			return 0, fmt.Errorf("failed to remove legacy key from %s: %w", table, err)
+		}
+		upgraded++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit key upgrade: %w", err)
+	}
+	return upgraded, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTransactionKeysDisambiguateIdenticalTransactions(t *testing.T) {
+	coffee := Transaction{BookingDate: statementDay("02.10.2025"), PartnerName: "Coffee Shop", Amount: eur("-2,50")}
+	other := Transaction{BookingDate: statementDay("02.10.2025"), PartnerName: "Bakery", Amount: eur("-2,50")}
+
+	keys := transactionKeys([]Transaction{coffee, other, coffee})
+	if keys[0] == keys[2] {
+		t.Fatalf("identical same-day transactions share key %q", keys[0])
+	}
+
+	again := transactionKeys([]Transaction{coffee, other, coffee})
+	for i := range keys {
+		if keys[i] != again[i] {
+			t.Errorf("key %d is not stable across runs: %q != %q", i, keys[i], again[i])
+		}
+	}
+
+	if keys[0] != generateStatementKey(statementDay("02.10.2025"), "Coffee Shop", eur("-2,50"), 1) ||
+		keys[2] != generateStatementKey(statementDay("02.10.2025"), "Coffee Shop", eur("-2,50"), 2) {
+		t.Error("keys are not numbered by occurrence within the statement")
+	}
+}
+
+func TestGenerateStatementKeyIsFixedLength(t *testing.T) {
+	for _, partner := range []string{"A", strings.Repeat("Very Long Partner Name ", 40)} {
+		if key := generateStatementKey(statementDay("02.10.2025"), partner, eur("-2,50"), 1); len(key) != 64 {
+			t.Errorf("key for partner of length %d has length %d, want 64", len(partner), len(key))
+		}
+	}
+}
+
+func TestParseLegacyStatementKey(t *testing.T) {
+	date, partner, amount, ok := parseLegacyStatementKey("02.10.2025|Shop | Café|-2,50")
+	if !ok || date != "02.10.2025" || partner != "Shop | Café" || amount != "-2,50" {
+		t.Errorf("parseLegacyStatementKey() = %q, %q, %q, %v", date, partner, amount, ok)
+	}
+
+	if _, _, _, ok := parseLegacyStatementKey(generateStatementKey(statementDay("02.10.2025"), "Shop", eur("-2,50"), 1)); ok {
+		t.Error("parseLegacyStatementKey() accepted a hashed key")
+	}
+}
+
+func TestUpgradeLegacyStatementKeys(t *testing.T) {
+	conn := "sqlite://" + filepath.Join(t.TempDir(), "n26.db")
+	storage := openTestStorage(t, conn)
+
+	legacy := "02.10.2025|Coffee Shop|-2,50"
+	if _, err := storage.DB.Exec(`INSERT INTO statements (statement_key, notified) VALUES ($1, true)`, legacy); err != nil {
+		t.Fatalf("failed to insert legacy statement: %v", err)
+	}
+	if _, err := storage.DB.Exec(
+		`INSERT INTO transactions (statement_key, booking_date, partner_name, amount) VALUES ($1, '2025-10-02', 'Coffee Shop', -2.50)`,
+		legacy,
+	); err != nil {
+		t.Fatalf("failed to insert legacy transaction: %v", err)
+	}
+	storage.Close()
+
+	// Reopening the storage upgrades the keys
+	storage = openTestStorage(t, conn)
+
+	upgraded := generateStatementKey(statementDay("02.10.2025"), "Coffee Shop", eur("-2,50"), 1)
+	if notified, err := storage.Statements.IsNotified(upgraded); err != nil || !notified {
+		t.Errorf("IsNotified(upgraded key) = %v, %v, want true, nil", notified, err)
+	}
+	if notified, err := storage.Statements.IsNotified(legacy); err != nil || notified {
+		t.Errorf("IsNotified(legacy key) = %v, %v, want false, nil", notified, err)
+	}
+
+	var transactionKey string
+	if err := storage.DB.QueryRow(`SELECT statement_key FROM transactions`).Scan(&transactionKey); err != nil {
+		t.Fatalf("failed to read transaction key: %v", err)
+	}
+	if transactionKey != upgraded {
+		t.Errorf("transaction key = %q, want %q", transactionKey, upgraded)
+	}
+
+	// Running the upgrade again finds nothing to do
+	if count, err := upgradeLegacyStatementKeys(storage.DB); err != nil || count != 0 {
+		t.Errorf("second upgrade = %d, %v, want 0, nil", count, err)
+	}
+}
+
+func TestPartnerSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		similar bool
+	}{
+		{"Coffee Shop", "COFFEE SHOP", true},
+		{"AMAZON", "Amazon EU S.a.r.l.", true},
+		{"Rewe Markt GmbH", "REWE Markt GmbH.", true},
+		{"Spotify", "Netflix", false},
+		{"Coffee Shop", "", false},
+	}
+	for _, tt := range tests {
+		if got := partnerSimilarity(tt.a, tt.b) >= partnerMatchThreshold; got != tt.similar {
+			t.Errorf("partnerSimilarity(%q, %q) = %.2f, similar = %v, want %v",
+				tt.a, tt.b, partnerSimilarity(tt.a, tt.b), got, tt.similar)
+		}
+	}
+}
+
+func TestReconcileStatementKeys(t *testing.T) {
+	storage, err := OpenStorage("memory://")
+	if err != nil {
+		t.Fatalf("failed to open memory storage: %v", err)
+	}
+	statements := storage.Statements
+
+	outdated := []StatementRecord{
+		{Key: "v1-coffee", KeyVersion: 1, BookingDate: statementDay("02.10.2025"), PartnerName: "COFFEE SHOP BERLIN", Amount: eur("-2,50")},
+		{Key: "v1-bakery", KeyVersion: 1, BookingDate: statementDay("02.10.2025"), PartnerName: "Bakery", Amount: eur("-3,00")},
+	}
+	if err := statements.MarkMultipleAsNotified(outdated); err != nil {
+		t.Fatalf("MarkMultipleAsNotified() failed: %v", err)
+	}
+
+	parsed := []Transaction{
+		{BookingDate: statementDay("02.10.2025"), PartnerName: "Coffee Shop Berlin", Amount: eur("-2,50")},
+		{BookingDate: statementDay("02.10.2025"), PartnerName: "Hardware Store", Amount: eur("-3,00")},
+	}
+	moved, err := reconcileStatementKeys(storage, parsed)
+	if err != nil {
+		t.Fatalf("reconcileStatementKeys() failed: %v", err)
+	}
+	if moved != 1 {
+		t.Errorf("reconcileStatementKeys() moved %d statements, want 1", moved)
+	}
+
+	records := statementRecords(parsed)
+	if notified, _ := statements.IsNotified(records[0].Key); !notified {
+		t.Error("similar partner was not matched to the outdated key")
+	}
+	if notified, _ := statements.IsNotified(records[1].Key); notified {
+		t.Error("different partner with the same date and amount was matched")
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// StatementPeriod is the date range a statement covers
+type StatementPeriod struct {
+	From time.Time
+	To   time.Time
+}
+
+// ParsedStatement is everything extracted from one downloaded PDF statement
+type ParsedStatement struct {
+	Space        Space
+	Period       StatementPeriod // As the PDF header states it, the requested period when it states none
+	PeriodStated bool            // Whether Period is the one the PDF header states
+	Document     StatementDocument
+	Language     string
+	Transactions []Transaction
+	Balance      *AccountBalance // nil when the balance could not be found
+	Opening      *AccountBalance // Balance before the period, nil when it could not be found
+}
+
+// lastDaysPeriod returns the period covering the last days days up to now
+func lastDaysPeriod(days int, now time.Time) StatementPeriod {
+	return StatementPeriod{From: now.AddDate(0, 0, -days), To: now}
+}
+
+// parseStatement extracts transactions and the account balance from a PDF statement of a space
+func parseStatement(pdfData []byte, period StatementPeriod, space Space) (*ParsedStatement, error) {
+	parser, err := NewPDFParserFromBytes(pdfData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PDF parser: %w", err)
+	}
+	defer parser.Close()
+
+	extractedText, err := parser.ExtractText()
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract PDF text: %w", err)
+	}
+	detectedLanguage := "en"
+	if strings.Contains(extractedText, "Actividad de la cuenta") {
+		detectedLanguage = "es"
+	}
+	log.Printf("Extracted PDF text (%d characters)\n", len(extractedText))
+	log.Printf("Detected language: %s", detectedLanguage)
+
+	var stated *StatementPeriod
+	if headerPeriod, err := parsePeriodFromText(extractedText); err != nil {
+		log.Printf("Warning: Failed to read the statement period, using the requested one: %v", err)
+	} else {
+		log.Printf("Statement period: %s – %s", formatStatementDate(headerPeriod.From), formatStatementDate(headerPeriod.To))
+		period, stated = headerPeriod, &headerPeriod
+	}
+
+	transactions, err := parseTransactionsFromText(extractedText, stated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PDF transactions: %w", err)
+	}
+
+	if len(transactions) == 0 {
+		return nil, fmt.Errorf("PDF has no transaction data")
+	}
+
+	// Parse account balance
+	balance, err := parseBalanceFromText(extractedText)
+	if err != nil {
+		log.Printf("Warning: Failed to parse account balance: %v", err)
+		balance = nil
+	} else {
+		log.Printf("Account balance: %s", balance.Balance)
+	}
+	opening, err := parseOpeningBalanceFromText(extractedText)
+	if err != nil {
+		log.Printf("Warning: Failed to parse previous balance: %v", err)
+		opening = nil
+	}
+
+	for i := range transactions {
+		transactions[i].Space = space.scope()
+	}
+
+	checksum := sha256.Sum256(pdfData)
+	return &ParsedStatement{
+		Space:        space,
+		Period:       period,
+		PeriodStated: stated != nil,
+		Document: StatementDocument{
+			SHA256:      hex.EncodeToString(checksum[:]),
+			PeriodStart: period.From,
+			PeriodEnd:   period.To,
+			SizeBytes:   len(pdfData),
+			Space:       space.scope(),
+		},
+		Language:     detectedLanguage,
+		Transactions: transactions,
+		Balance:      balance,
+		Opening:      opening,
+	}, nil
+}
+
+// processStatement stores a downloaded statement of a space in the local history and notifies
+// about new, updated, reversed and removed transactions. The statement of the main account then
+// has the updated history checked; other spaces hold no spending, only their balance is recorded.
+func processStatement(config AccountConfig, pdfData []byte, period StatementPeriod, space Space, storage *Storage) error {
+	statement, err := parseStatement(pdfData, period, space)
+	if err != nil {
+		return err
+	}
+
+	categorizer, err := loadCategorizer(config, storage.Rules)
+	if err != nil {
+		log.Printf("Warning: Failed to load category rules, using the default rules only: %v", err)
+		categorizer, _ = NewCategorizer(nil, true)
+	}
+	categorizer.CategorizeAll(statement.Transactions)
+
+	// Adopt statements notified under an older key version before they look new
+	if moved, err := reconcileStatementKeys(storage, statement.Transactions); err != nil {
+		log.Printf("Warning: Failed to reconcile statement keys: %v", err)
+	} else if moved > 0 {
+		fmt.Printf("Moved %d statements to the current key version\n", moved)
+	}
+
+	// Compare with the history before this statement is recorded into it
+	changes, err := detectTransactionChanges(storage.Transactions, statement)
+	if err != nil {
+		log.Printf("Warning: Failed to detect transaction changes: %v", err)
+		changes = nil
+	} else {
+		fmt.Printf("Compared with the stored history: %s\n", summarizeTransactionChanges(changes))
+		if err := applyTransactionChanges(storage, changes); err != nil {
+			log.Printf("Warning: Failed to apply transaction changes: %v", err)
+		}
+	}
+
+	// Storing the history must not prevent notifications
+	if err := recordStatement(storage.Transactions, statement); err != nil {
+		log.Printf("Warning: Failed to store transactions: %v", err)
+	}
+	if err := recordBalanceSnapshot(storage.Balances, space.ID, statement, time.Now()); err != nil {
+		log.Printf("Warning: Failed to store balance snapshot: %v", err)
+	}
+
+	notifiers, err := loadNotifiers(config)
+	if err != nil {
+		return err
+	}
+	if err := checkReconciliation(statement, storage.Alerts, notifiers); err != nil {
+		log.Printf("Warning: Failed to send reconciliation alert: %v", err)
+	}
+	notifyErr := notifyStatement(config, statement, storage, notifiers, changes)
+
+	if space.isMain() {
+		checkHistory(config, storage, notifiers, statement.Period, space.ID, time.Now())
+	}
+
+	if notifyErr != nil {
+		return fmt.Errorf("failed to send notifications: %w", notifyErr)
+	}
+	return nil
+}
+
+// notifyStatement queues the transactions that were not notified before, and the updates,
+// reversals and removals among changes, for every notification channel. It then sends each
+// channel everything it has not received yet, one notification per kind of change, and
+// warns about new transactions that stand out from the history.
+// Channels are independent: a failed channel is retried on later runs without
+// re-sending to the channels that succeeded.
+func notifyStatement(config AccountConfig, statement *ParsedStatement, storage *Storage, notifiers []Notifier, changes []TransactionChange) error {
+	channels := make([]string, len(notifiers))
+	for i, notifier := range notifiers {
+		channels[i] = notifier.Channel()
+	}
+
+	records := statementRecords(statement.Transactions)
+	keys := recordKeys(records)
+
+	// Updates and reversals are announced as such, never as new transactions
+	var queue []TransactionChange
+	var notified []StatementRecord
+	isChange := make(map[string]bool)
+	for _, change := range changes {
+		if change.Kind == ChangeNew {
+			continue
+		}
+		queue = append(queue, change)
+		isChange[change.Record.Key] = true
+		if change.Kind != ChangeRemoved {
+			notified = append(notified, change.Record)
+		}
+	}
+
+	// Look up the notified state of every key in one query
+	unnotified, err := storage.Statements.FilterUnnotified(keys)
+	if err != nil {
+		log.Printf("Warning: Failed to check which statements are notified: %v", err)
+		// Assume not notified if we can't check, deliveries that were already sent stay sent
+		unnotified = keys
+	}
+	isNew := make(map[string]bool, len(unnotified))
+	for _, key := range unnotified {
+		isNew[key] = true
+	}
+	var newRecords []StatementRecord
+	for _, record := range records {
+		if isNew[record.Key] && !isChange[record.Key] {
+			queue = append(queue, TransactionChange{Kind: ChangeNew, Record: record})
+			notified = append(notified, record)
+			newRecords = append(newRecords, record)
+		}
+	}
+
+	if len(newRecords) > 0 {
+		fmt.Printf("Found %d new statements out of %d total statements\n", len(newRecords), len(records))
+	}
+	if len(queue) > 0 {
+		// Queue before marking, a statement marked without deliveries would never be sent
+		if err := storage.Deliveries.Enqueue(channels, queue); err != nil {
+			return fmt.Errorf("failed to queue notifications: %w", err)
+		}
+		if err := storage.Statements.MarkMultipleAsNotified(notified); err != nil {
+			log.Printf("Warning: Failed to mark statements as notified: %v", err)
+		} else if len(notified) > 0 {
+			fmt.Printf("Marked %d statements as notified\n", len(notified))
+		}
+	}
+
+	var balance *Money
+	if statement.Balance != nil {
+		balance = &statement.Balance.Balance
+	}
+
+	var failed []string
+	for _, notifier := range notifiers {
+		channel := notifier.Channel()
+		pending, err := storage.Deliveries.Pending(channel)
+		if err != nil {
+			return err
+		}
+		// Changes of other spaces wait for the statement of their space, which they are labelled with
+		pending = slices.DeleteFunc(pending, func(change TransactionChange) bool {
+			return change.Record.Space != statement.Space.scope()
+		})
+		if len(pending) == 0 {
+			fmt.Printf("No new statements to send to %s\n", channel)
+			continue
+		}
+
+		channelFailed := false
+		for _, kind := range changeKinds {
+			var batch []TransactionChange
+			var batchKeys []string
+			for _, change := range pending {
+				if change.Kind == kind {
+					batch = append(batch, change)
+					batchKeys = append(batchKeys, change.Record.Key)
+				}
+			}
+			if len(batch) == 0 {
+				continue
+			}
+
+			notification := Notification{Kind: kind, Space: statement.Space.label(), Changes: batch, TotalTransactions: len(records), Balance: balance}
+			if err := notifier.Notify(notification); err != nil {
+				log.Printf("Warning: Failed to send %s statements to %s: %v", kind, channel, err)
+				if err := storage.Deliveries.MarkFailed(channel, kind, batchKeys, err); err != nil {
+					log.Printf("Warning: Failed to record failed %s delivery: %v", channel, err)
+				}
+				channelFailed = true
+				continue
+			}
+
+			fmt.Printf("Sent %d %s statements to %s\n", len(batch), kind, channel)
+			if err := storage.Deliveries.MarkSent(channel, kind, batchKeys); err != nil {
+				// Not fatal, but the statements will be sent to this channel again
+				log.Printf("Warning: Failed to record %s delivery: %v", channel, err)
+			}
+		}
+		if channelFailed {
+			failed = append(failed, channel)
+		}
+	}
+
+	// Outliers among the new transactions get a highlighted warning of their own. Spaces other
+	// than the main account only hold transfers, which are not scored.
+	if statement.Space.isMain() {
+		if err := checkAnomalies(config, storage, notifiers, newRecords, time.Now()); err != nil {
+			log.Printf("Warning: Failed to check for unusual transactions: %v", err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("delivery to %s failed, it will be retried on the next run", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// checkHistory runs the checks on the stored history, which now includes the statement:
+// budgets crossing a threshold, irregular subscription charges and a low or falling balance,
+// then sends the digests of the weeks and months that ended. A failed check or alert is only
+// logged, alerts and digests that were not sent are retried on the next run.
+func checkHistory(config AccountConfig, storage *Storage, notifiers []Notifier, period StatementPeriod, account string, now time.Time) {
+	months := []time.Time{monthStart(now)}
+	if !period.From.IsZero() && !period.To.IsZero() {
+		months = periodMonths(period.From, period.To)
+	}
+	if err := checkBudgets(config, storage, notifiers, months); err != nil {
+		log.Printf("Warning: Failed to check budgets: %v", err)
+	}
+
+	if err := checkSubscriptions(storage, notifiers, now); err != nil {
+		log.Printf("Warning: Failed to check subscriptions: %v", err)
+	}
+
+	if err := checkBalance(config, storage, notifiers, account, now); err != nil {
+		log.Printf("Warning: Failed to check balance: %v", err)
+	}
+
+	if err := checkDigests(config, storage, notifiers, account, now); err != nil {
+		log.Printf("Warning: Failed to send digests: %v", err)
+	}
+}
+
+// recordStatement saves the source document and upserts its transactions
+func recordStatement(transactionRepo TransactionRepository, statement *ParsedStatement) error {
+	documentID, err := transactionRepo.SaveDocument(statement.Document)
+	if err != nil {
+		return err
+	}
+	statement.Document.ID = documentID
+
+	if err := transactionRepo.RecordTransactions(documentID, statement.Transactions); err != nil {
+		return err
+	}
+
+	fmt.Printf("Stored %d transactions in the local history\n", len(statement.Transactions))
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// fakeNotifier records the notifications and alerts it receives and fails while err is set
+type fakeNotifier struct {
+	channel string
+	err     error
+	sent    []Notification
+	alerts  []Alert
+}
+
+func (n *fakeNotifier) Channel() string {
+	return n.channel
+}
+
+func (n *fakeNotifier) Notify(notification Notification) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.sent = append(n.sent, notification)
+	return nil
+}
+
+func (n *fakeNotifier) SendAlert(alert Alert) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+func TestNotifyStatementRetriesFailedChannelsOnly(t *testing.T) {
+	storage, err := OpenStorage("memory://")
+	if err != nil {
+		t.Fatalf("failed to open memory storage: %v", err)
+	}
+
+	discord := &fakeNotifier{channel: "discord"}
+	email := &fakeNotifier{channel: "email", err: errors.New("smtp unavailable")}
+	notifiers := []Notifier{discord, email}
+
+	statement := &ParsedStatement{Transactions: []Transaction{
+		{BookingDate: statementDay("01.10.2025"), PartnerName: "Coffee Shop", Amount: eur("-2,50")},
+		{BookingDate: statementDay("02.10.2025"), PartnerName: "Supermarket", Amount: eur("-45,10")},
+	}}
+
+	if err := notifyStatement(globalConfig, statement, storage, notifiers, nil); err == nil {
+		t.Fatal("notifyStatement() with a failing channel succeeded, want error")
+	}
+	if len(discord.sent) != 1 || len(discord.sent[0].Changes) != 2 {
+		t.Fatalf("discord received %+v, want one notification with 2 statements", discord.sent)
+	}
+
+	// The next run retries email only, even though nothing new was parsed
+	email.err = nil
+	if err := notifyStatement(globalConfig, statement, storage, notifiers, nil); err != nil {
+		t.Fatalf("notifyStatement() failed: %v", err)
+	}
+	if len(discord.sent) != 1 {
+		t.Errorf("discord received %d notifications, want 1 (no re-send)", len(discord.sent))
+	}
+	if len(email.sent) != 1 || len(email.sent[0].Changes) != 2 {
+		t.Errorf("email received %+v, want one notification with 2 statements", email.sent)
+	}
+
+	// Once every channel succeeded nothing is sent again
+	if err := notifyStatement(globalConfig, statement, storage, notifiers, nil); err != nil {
+		t.Fatalf("notifyStatement() failed: %v", err)
+	}
+	if len(discord.sent) != 1 || len(email.sent) != 1 {
+		t.Errorf("notifications were re-sent: discord %d, email %d", len(discord.sent), len(email.sent))
+	}
+}
@@ -3,23 +3,40 @@ package main
 import (
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 )
 
 // StatementRepository defines the interface for statement notification tracking
 type StatementRepository interface {
 	IsNotified(key string) (bool, error)
-	MarkMultipleAsNotified(keys []string) error
+	FilterUnnotified(keys []string) ([]string, error)
+	MarkMultipleAsNotified(records []StatementRecord) error
+	FindOutdated(space string, bookingDate time.Time, amount Money, version int) ([]StatementRecord, error)
+	Rekey(oldKey string, record StatementRecord) error
+}
+
+// StatementRecord is a notified statement together with the fields its key was derived from
+type StatementRecord struct {
+	Key         string
+	KeyVersion  int
+	BookingDate time.Time // Zero when unknown
+	PartnerName string
+	Amount      Money
+	Category    string // Shown in notifications, not part of the key
+	Space       string // ID of the N26 Space, empty for the main account
 }
 
 // PostgresStatementRepository implements StatementRepository using PostgreSQL storage
 type PostgresStatementRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	account string
 }
 
-// NewPostgresStatementRepository creates a new PostgreSQL-based statement repository
-func NewPostgresStatementRepository(db *sql.DB) (*PostgresStatementRepository, error) {
-	repo := &PostgresStatementRepository{db: db}
-	// Migrations are handled by runMigrations in cookie_repository.go
+// NewPostgresStatementRepository creates a PostgreSQL-based statement repository for an account
+func NewPostgresStatementRepository(db *sql.DB, account string) (*PostgresStatementRepository, error) {
+	repo := &PostgresStatementRepository{db: db, account: account}
+	// Migrations are handled by prepareSchema in OpenStorage
 	// No need to run them again here since we share the same database
 	return repo, nil
 }
@@ -27,8 +44,8 @@ func NewPostgresStatementRepository(db *sql.DB) (*PostgresStatementRepository, e
 // IsNotified checks if a statement has already been notified
 func (r *PostgresStatementRepository) IsNotified(key string) (bool, error) {
 	var notified bool
-	query := `SELECT notified FROM statements WHERE statement_key = $1`
-	err := r.db.QueryRow(query, key).Scan(&notified)
+	query := `SELECT notified FROM statements WHERE account = $1 AND statement_key = $2`
+	err := r.db.QueryRow(query, r.account, key).Scan(&notified)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -41,32 +58,215 @@ func (r *PostgresStatementRepository) IsNotified(key string) (bool, error) {
 	return notified, nil
 }
 
-// MarkMultipleAsNotified marks multiple statements as notified
-func (r *PostgresStatementRepository) MarkMultipleAsNotified(keys []string) error {
+// FilterUnnotified returns the keys that have not been notified yet, in their original order
+func (r *PostgresStatementRepository) FilterUnnotified(keys []string) ([]string, error) {
 	if len(keys) == 0 {
-		return nil
+		return nil, nil
 	}
 
-	// Use INSERT ... ON CONFLICT to upsert
-	query := `
-		INSERT INTO statements (statement_key, notified, updated_at)
-		VALUES ($1, true, CURRENT_TIMESTAMP)
-		ON CONFLICT (statement_key) 
-		DO UPDATE SET notified = true, updated_at = CURRENT_TIMESTAMP
-	`
+	query := `SELECT statement_key FROM statements WHERE account = $1 AND notified AND statement_key = ANY($2)`
+	rows, err := r.db.Query(query, r.account, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up notified statements: %w", err)
+	}
+	return unnotifiedKeys(keys, rows)
+}
+
+// MarkMultipleAsNotified marks multiple statements as notified in a single database transaction
+func (r *PostgresStatementRepository) MarkMultipleAsNotified(records []StatementRecord) error {
+	return markStatementsNotified(r.db, r.account, records, postgresStatementBatchSize)
+}
+
+// FindOutdated returns notified statements of a space with the given booking date and amount
+// whose key was generated by a key version older than version
+func (r *PostgresStatementRepository) FindOutdated(space string, bookingDate time.Time, amount Money, version int) ([]StatementRecord, error) {
+	return findOutdatedStatements(r.db, r.account, space, bookingDate, amount, version)
+}
+
+// Rekey moves a notified statement to the key of the current key version
+func (r *PostgresStatementRepository) Rekey(oldKey string, record StatementRecord) error {
+	return rekeyStatement(r.db, r.account, oldKey, record)
+}
+
+// statementRecordColumns converts the record fields to their column values (a nil date when unknown)
+func statementRecordColumns(record StatementRecord) (bookingDate, amount any) {
+	return sqlDate(record.BookingDate), record.Amount.decimal()
+}
+
+// postgresStatementBatchSize is the number of rows per multi-row insert on PostgreSQL, where
+// every statement is a network round trip. SQLite inserts one row per statement instead:
+// it runs in-process and binds large parameter lists slower than it executes small statements.
+const postgresStatementBatchSize = 500
+
+// unnotifiedKeys drains rows of notified statement keys and returns the keys not among them
+func unnotifiedKeys(keys []string, rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
 
+	notified := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan statement key: %w", err)
+		}
+		notified[key] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to look up notified statements: %w", err)
+	}
+
+	var unnotified []string
 	for _, key := range keys {
-		_, err := r.db.Exec(query, key)
-		if err != nil {
-			return fmt.Errorf("failed to mark statement as notified: %w", err)
+		if !notified[key] {
+			unnotified = append(unnotified, key)
+		}
+	}
+	return unnotified, nil
+}
+
+// markStatementsNotified implements MarkMultipleAsNotified with SQL both backends understand.
+// Records are upserted batchSize rows per insert inside one transaction, so either the whole
+// batch is marked or none of it is.
+func markStatementsNotified(db *sql.DB, account string, records []StatementRecord, batchSize int) error {
+	// A key may only appear once per insert for ON CONFLICT DO UPDATE
+	seen := make(map[string]bool, len(records))
+	var unique []StatementRecord
+	for _, record := range records {
+		if !seen[record.Key] {
+			seen[record.Key] = true
+			unique = append(unique, record)
 		}
 	}
+	if len(unique) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
+	// Full batches share one prepared statement, only the remainder needs its own
+	prepared := make(map[int]*sql.Stmt)
+	defer func() {
+		for _, stmt := range prepared {
+			stmt.Close()
+		}
+	}()
+
+	for start := 0; start < len(unique); start += batchSize {
+		batch := unique[start:min(start+batchSize, len(unique))]
+
+		stmt, ok := prepared[len(batch)]
+		if !ok {
+			if stmt, err = tx.Prepare(markNotifiedQuery(len(batch))); err != nil {
+				return fmt.Errorf("failed to prepare statement upsert: %w", err)
+			}
+			prepared[len(batch)] = stmt
+		}
+
+		args := make([]any, 0, len(batch)*7)
+		for _, record := range batch {
+			bookingDate, amount := statementRecordColumns(record)
+			args = append(args, account, record.Key, record.KeyVersion, bookingDate, record.PartnerName, amount, record.Space)
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return fmt.Errorf("failed to mark statements as notified: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit notified statements: %w", err)
+	}
 	return nil
 }
 
-// generateStatementKey creates a unique key from date, partner, and amount
-func generateStatementKey(date, partner, amount string) string {
-	return fmt.Sprintf("%s|%s|%s", date, partner, amount)
+// markNotifiedQuery returns the upsert marking rows statements as notified
+func markNotifiedQuery(rows int) string {
+	values := make([]string, rows)
+	for i := range values {
+		n := i * 7
+		values[i] = fmt.Sprintf("($%d, $%d, true, $%d, $%d, $%d, $%d, $%d, CURRENT_TIMESTAMP)", n+1, n+2, n+3, n+4, n+5, n+6, n+7)
+	}
+	return `
+		INSERT INTO statements (account, statement_key, notified, key_version, booking_date, partner_name, amount, space, updated_at)
+		VALUES ` + strings.Join(values, ", ") + `
+		ON CONFLICT (account, statement_key)
+		DO UPDATE SET notified = true, updated_at = CURRENT_TIMESTAMP
+	`
+}
+
+// findOutdatedStatements implements FindOutdated with SQL both backends understand
+func findOutdatedStatements(db *sql.DB, account, space string, bookingDate time.Time, amount Money, version int) ([]StatementRecord, error) {
+	query := `
+		SELECT statement_key, key_version, booking_date, partner_name, CAST(amount AS TEXT)
+		FROM statements
+		WHERE account = $1 AND notified AND space = $2 AND booking_date = $3 AND amount = $4 AND key_version < $5
+		ORDER BY statement_key
+	`
+	rows, err := db.Query(query, account, space, sqlDate(bookingDate), amount.decimal(), version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find outdated statements: %w", err)
+	}
+	defer rows.Close()
+
+	var records []StatementRecord
+	for rows.Next() {
+		var record StatementRecord
+		var date time.Time
+		var partner sql.NullString
+		var amount string
+		if err := rows.Scan(&record.Key, &record.KeyVersion, &date, &partner, &amount); err != nil {
+			return nil, fmt.Errorf("failed to scan statement: %w", err)
+		}
+		record.BookingDate = statementDate(date)
+		record.PartnerName = partner.String
+		record.Amount, err = parseMoney(amount, statementCurrency)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read amount of statement %s: %w", record.Key, err)
+		}
+		record.Space = space
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find outdated statements: %w", err)
+	}
+	return records, nil
 }
 
+// rekeyStatement implements Rekey with SQL both backends understand
+func rekeyStatement(db *sql.DB, account string, oldKey string, record StatementRecord) error {
+	if oldKey == record.Key {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin rekey: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The new key may already exist as an unnotified row; the old row carries the notified state
+	if _, err := tx.Exec(`
+		DELETE FROM statements
+		WHERE account = $1 AND statement_key = $2
+		AND EXISTS (SELECT 1 FROM statements WHERE account = $1 AND statement_key = $3)
+	`, account, record.Key, oldKey); err != nil {
+		return fmt.Errorf("failed to clear new statement key: %w", err)
+	}
+
+	bookingDate, amount := statementRecordColumns(record)
+	query := `
+		UPDATE statements
+		SET statement_key = $1, key_version = $2, booking_date = $3, partner_name = $4, amount = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE account = $6 AND statement_key = $7
+	`
+	if _, err := tx.Exec(query, record.Key, record.KeyVersion, bookingDate, record.PartnerName, amount, account, oldKey); err != nil {
+		return fmt.Errorf("failed to rekey statement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rekey: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// benchmarkStatementRows is the number of statements the benchmarks look up and mark
+const benchmarkStatementRows = 5000
+
+// benchmarkStatementRecords returns count distinct statement records
+func benchmarkStatementRecords(count int) []StatementRecord {
+	transactions := make([]Transaction, count)
+	for i := range transactions {
+		transactions[i] = Transaction{
+			BookingDate: statementDay(fmt.Sprintf("%02d.10.2025", i%28+1)),
+			PartnerName: fmt.Sprintf("Partner %d", i),
+			Amount:      newMoney(-int64(i), statementCurrency),
+		}
+	}
+	return statementRecords(transactions)
+}
+
+// benchmarkStatementBackends runs a benchmark against every backend with a database
+func benchmarkStatementBackends(b *testing.B, run func(b *testing.B, storage *Storage)) {
+	for _, backend := range repositoryBackends() {
+		if backend.name == "memory" {
+			continue
+		}
+		b.Run(backend.name, func(b *testing.B) {
+			run(b, backend.newRepo(b))
+		})
+	}
+}
+
+// BenchmarkIsNotifiedPerKey is the lookup pattern FilterUnnotified replaces: one query per key
+func BenchmarkIsNotifiedPerKey(b *testing.B) {
+	benchmarkStatementBackends(b, func(b *testing.B, storage *Storage) {
+		records := benchmarkStatementRecords(benchmarkStatementRows)
+		if err := storage.Statements.MarkMultipleAsNotified(records[:len(records)/2]); err != nil {
+			b.Fatalf("MarkMultipleAsNotified() failed: %v", err)
+		}
+
+		for b.Loop() {
+			for _, record := range records {
+				if _, err := storage.Statements.IsNotified(record.Key); err != nil {
+					b.Fatalf("IsNotified() failed: %v", err)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkFilterUnnotified(b *testing.B) {
+	benchmarkStatementBackends(b, func(b *testing.B, storage *Storage) {
+		records := benchmarkStatementRecords(benchmarkStatementRows)
+		if err := storage.Statements.MarkMultipleAsNotified(records[:len(records)/2]); err != nil {
+			b.Fatalf("MarkMultipleAsNotified() failed: %v", err)
+		}
+		keys := recordKeys(records)
+
+		for b.Loop() {
+			if _, err := storage.Statements.FilterUnnotified(keys); err != nil {
+				b.Fatalf("FilterUnnotified() failed: %v", err)
+			}
+		}
+	})
+}
+
+// BenchmarkMarkNotifiedPerKey is the marking pattern MarkMultipleAsNotified replaces:
+// one statement per key, each in its own implicit transaction
+func BenchmarkMarkNotifiedPerKey(b *testing.B) {
+	benchmarkStatementBackends(b, func(b *testing.B, storage *Storage) {
+		records := benchmarkStatementRecords(benchmarkStatementRows)
+		query := `
+			INSERT INTO statements (account, statement_key, notified, key_version, booking_date, partner_name, amount, updated_at)
+			VALUES ($1, $2, true, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+			ON CONFLICT (account, statement_key)
+			DO UPDATE SET notified = true, updated_at = CURRENT_TIMESTAMP
+		`
+
+		for b.Loop() {
+			for _, record := range records {
+				bookingDate, amount := statementRecordColumns(record)
+				if _, err := storage.DB.Exec(query, defaultAccount, record.Key, record.KeyVersion, bookingDate, record.PartnerName, amount); err != nil {
+					b.Fatalf("failed to mark statement: %v", err)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkMarkMultipleAsNotified(b *testing.B) {
+	benchmarkStatementBackends(b, func(b *testing.B, storage *Storage) {
+		records := benchmarkStatementRecords(benchmarkStatementRows)
+
+		for b.Loop() {
+			if err := storage.Statements.MarkMultipleAsNotified(records); err != nil {
+				b.Fatalf("MarkMultipleAsNotified() failed: %v", err)
+			}
+		}
+	})
+}
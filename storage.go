@@ -0,0 +1,212 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Supported storage backends, selected from the DB_CONN URL scheme
+const (
+	backendPostgres = "postgres"
+	backendSQLite   = "sqlite"
+	backendMemory   = "memory" // Nothing is persisted, useful for dry runs
+)
+
+// Storage bundles the repositories of the configured storage backend. Everything but the
+// retention repository is scoped to one account, ForAccount returns the repositories of another.
+type Storage struct {
+	Backend       string
+	DB            *sql.DB
+	Account       string
+	Cookies       CookieRepository
+	Statements    StatementRepository
+	Transactions  TransactionRepository
+	Deliveries    DeliveryRepository
+	Rules         CategoryRuleRepository
+	Budgets       BudgetRepository
+	Alerts        AlertRepository
+	Subscriptions SubscriptionRepository
+	Balances      BalanceRepository
+	Spaces        SpaceRepository
+	Digests       DigestRepository
+	Retention     RetentionRepository
+	accounts      map[string]*Storage // In-memory backend: the storage of every account opened so far
+}
+
+// storageBackend picks the backend from the connection string scheme.
+// postgres:// and postgresql:// (or a key=value DSN) select PostgreSQL,
+// sqlite:// and sqlite: select a single-file SQLite database, memory:// keeps
+// everything in memory for dry runs.
+func storageBackend(connString string) (backend string, target string, err error) {
+	switch {
+	case strings.HasPrefix(connString, "postgres://"), strings.HasPrefix(connString, "postgresql://"):
+		return backendPostgres, connString, nil
+	case strings.HasPrefix(connString, "sqlite://"):
+		return backendSQLite, strings.TrimPrefix(connString, "sqlite://"), nil
+	case strings.HasPrefix(connString, "sqlite:"):
+		return backendSQLite, strings.TrimPrefix(connString, "sqlite:"), nil
+	case strings.HasPrefix(connString, "memory://"):
+		return backendMemory, "", nil
+	case !strings.Contains(connString, "://") && strings.Contains(connString, "="):
+		// libpq key=value connection string, e.g. "host=localhost user=n26"
+		return backendPostgres, connString, nil
+	default:
+		return "", "", fmt.Errorf("unsupported DB_CONN scheme (expected postgres://, postgresql://, sqlite:// or memory://)")
+	}
+}
+
+// OpenStorage opens the backend selected by the connection string and checks its schema version,
+// migrating it up first unless AUTO_MIGRATE is false. The returned storage belongs to the default
+// account.
+func OpenStorage(connString string) (*Storage, error) {
+	backend, target, err := storageBackend(connString)
+	if err != nil {
+		return nil, err
+	}
+
+	if backend == backendMemory {
+		storage := newMemoryStorage(defaultAccount, NewMemoryRetentionRepository())
+		storage.accounts = map[string]*Storage{defaultAccount: storage}
+		return storage, nil
+	}
+
+	db, err := openDatabase(backend, target)
+	if err != nil {
+		return nil, err
+	}
+	if err := prepareSchema(db, backend, globalConfig.envBool("AUTO_MIGRATE", true)); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	upgraded, err := upgradeLegacyStatementKeys(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to upgrade statement keys: %w", err)
+	}
+	if upgraded > 0 {
+		log.Printf("Upgraded %d statement keys to the hashed key scheme", upgraded)
+	}
+
+	storage, err := newSQLStorage(backend, db, defaultAccount)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return storage, nil
+}
+
+// ForAccount returns the storage of an account on the same backend and connection. Only the
+// storage returned by OpenStorage needs to be closed.
+func (s *Storage) ForAccount(account string) (*Storage, error) {
+	if account == s.Account {
+		return s, nil
+	}
+	if s.Backend == backendMemory {
+		storage, ok := s.accounts[account]
+		if !ok {
+			storage = newMemoryStorage(account, s.Retention)
+			storage.accounts = s.accounts
+			s.accounts[account] = storage
+		}
+		return storage, nil
+	}
+	return newSQLStorage(s.Backend, s.DB, account)
+}
+
+// openDatabase opens the database of a SQL backend without touching its schema
+func openDatabase(backend, target string) (*sql.DB, error) {
+	switch backend {
+	case backendSQLite:
+		return openSQLiteDB(target)
+	case backendPostgres:
+		return openPostgresDB(target)
+	default:
+		return nil, fmt.Errorf("storage backend %q has no database", backend)
+	}
+}
+
+// newSQLStorage wires the repositories of an account together, the backend-specific ones
+// with the shared SQL ones
+func newSQLStorage(backend string, db *sql.DB, account string) (*Storage, error) {
+	var cookies CookieRepository
+	var statements StatementRepository
+	var err error
+	switch backend {
+	case backendSQLite:
+		if cookies, err = NewSQLiteCookieRepository(db, account); err != nil {
+			return nil, fmt.Errorf("failed to initialize SQLite cookie repository: %w", err)
+		}
+		if statements, err = NewSQLiteStatementRepository(db, account); err != nil {
+			return nil, fmt.Errorf("failed to initialize SQLite statement repository: %w", err)
+		}
+	default:
+		if cookies, err = NewPostgresCookieRepository(db, account); err != nil {
+			return nil, fmt.Errorf("failed to initialize PostgreSQL cookie repository: %w", err)
+		}
+		if statements, err = NewPostgresStatementRepository(db, account); err != nil {
+			return nil, fmt.Errorf("failed to initialize PostgreSQL statement repository: %w", err)
+		}
+	}
+
+	return &Storage{
+		Backend:       backend,
+		DB:            db,
+		Account:       account,
+		Cookies:       cookies,
+		Statements:    statements,
+		Transactions:  NewSQLTransactionRepository(db, account),
+		Deliveries:    NewSQLDeliveryRepository(db, account),
+		Rules:         NewSQLCategoryRuleRepository(db, account),
+		Budgets:       NewSQLBudgetRepository(db, account),
+		Alerts:        NewSQLAlertRepository(db, account),
+		Subscriptions: NewSQLSubscriptionRepository(db, account),
+		Balances:      NewSQLBalanceRepository(db, account),
+		Spaces:        NewSQLSpaceRepository(db, account),
+		Digests:       NewSQLDigestRepository(db, account),
+		Retention:     NewSQLRetentionRepository(db),
+	}, nil
+}
+
+// newMemoryStorage creates empty in-memory repositories for an account. The retention repository
+// is shared by every account.
+func newMemoryStorage(account string, retention RetentionRepository) *Storage {
+	return &Storage{
+		Backend:       backendMemory,
+		Account:       account,
+		Cookies:       NewMemoryCookieRepository(),
+		Statements:    NewMemoryStatementRepository(),
+		Transactions:  NewMemoryTransactionRepository(),
+		Deliveries:    NewMemoryDeliveryRepository(),
+		Rules:         NewMemoryCategoryRuleRepository(),
+		Budgets:       NewMemoryBudgetRepository(),
+		Alerts:        NewMemoryAlertRepository(),
+		Subscriptions: NewMemorySubscriptionRepository(),
+		Balances:      NewMemoryBalanceRepository(),
+		Spaces:        NewMemorySpaceRepository(),
+		Digests:       NewMemoryDigestRepository(),
+		Retention:     retention,
+	}
+}
+
+// Name returns a human readable backend name for log output
+func (s *Storage) Name() string {
+	switch s.Backend {
+	case backendSQLite:
+		return "SQLite"
+	case backendMemory:
+		return "in-memory (nothing is persisted)"
+	default:
+		return "PostgreSQL"
+	}
+}
+
+// Close closes the shared database connection
+func (s *Storage) Close() error {
+	if s.DB == nil {
+		return nil
+	}
+	return s.DB.Close()
+}
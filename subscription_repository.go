@@ -0,0 +1,102 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SubscriptionRepository stores the recurring payments detected in the transaction history
+type SubscriptionRepository interface {
+	ListSubscriptions() ([]Subscription, error)
+	ReplaceSubscriptions(subscriptions []Subscription) error
+}
+
+// SQLSubscriptionRepository implements SubscriptionRepository on both PostgreSQL and SQLite,
+// the queries only use syntax the two have in common
+type SQLSubscriptionRepository struct {
+	db      *sql.DB
+	account string
+}
+
+// NewSQLSubscriptionRepository creates a subscription repository for an account on a migrated database
+func NewSQLSubscriptionRepository(db *sql.DB, account string) *SQLSubscriptionRepository {
+	return &SQLSubscriptionRepository{db: db, account: account}
+}
+
+// ListSubscriptions returns the stored subscriptions, next charge first
+func (r *SQLSubscriptionRepository) ListSubscriptions() ([]Subscription, error) {
+	query := `
+		SELECT partner_name, cadence, CAST(amount AS TEXT), CAST(previous_amount AS TEXT), occurrences,
+			first_charge_date, previous_charge_date, last_charge_date, next_charge_date, status
+		FROM subscriptions
+		WHERE account = $1
+		ORDER BY next_charge_date ASC, id ASC
+	`
+	rows, err := r.db.Query(query, r.account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []Subscription
+	for rows.Next() {
+		var s Subscription
+		var amount, previousAmount string
+		err := rows.Scan(&s.PartnerName, &s.Cadence, &amount, &previousAmount, &s.Occurrences,
+			&s.FirstChargeDate, &s.PreviousChargeDate, &s.LastChargeDate, &s.NextChargeDate, &s.Status)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan subscription: %w", err)
+		}
+		if s.Amount, err = parseMoney(amount, statementCurrency); err != nil {
+			return nil, fmt.Errorf("failed to read amount of subscription %s: %w", s.PartnerName, err)
+		}
+		if s.PreviousAmount, err = parseMoney(previousAmount, statementCurrency); err != nil {
+			return nil, fmt.Errorf("failed to read previous amount of subscription %s: %w", s.PartnerName, err)
+		}
+		for _, date := range []*time.Time{&s.FirstChargeDate, &s.PreviousChargeDate, &s.LastChargeDate, &s.NextChargeDate} {
+			*date = date.UTC()
+		}
+		subscriptions = append(subscriptions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// ReplaceSubscriptions replaces the stored subscriptions with a freshly detected list
+func (r *SQLSubscriptionRepository) ReplaceSubscriptions(subscriptions []Subscription) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM subscriptions WHERE account = $1`, r.account); err != nil {
+		return fmt.Errorf("failed to clear subscriptions: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO subscriptions (account, partner_name, cadence, amount, previous_amount, occurrences,
+			first_charge_date, previous_charge_date, last_charge_date, next_charge_date, status, detected_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, CURRENT_TIMESTAMP)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare subscription insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, s := range subscriptions {
+		_, err := stmt.Exec(r.account, s.PartnerName, string(s.Cadence), s.Amount.decimal(), s.PreviousAmount.decimal(), s.Occurrences,
+			sqlDate(s.FirstChargeDate), sqlDate(s.PreviousChargeDate), sqlDate(s.LastChargeDate), sqlDate(s.NextChargeDate), string(s.Status))
+		if err != nil {
+			return fmt.Errorf("failed to store subscription: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit subscriptions: %w", err)
+	}
+	return nil
+}
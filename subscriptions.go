@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// Cadence is how often a recurring payment is charged
+type Cadence string
+
+const (
+	CadenceWeekly  Cadence = "weekly"
+	CadenceMonthly Cadence = "monthly"
+	CadenceYearly  Cadence = "yearly"
+)
+
+// SubscriptionStatus tells whether a recurring payment is charged on schedule
+type SubscriptionStatus string
+
+const (
+	SubscriptionActive  SubscriptionStatus = "active"  // The next charge is not due yet
+	SubscriptionOverdue SubscriptionStatus = "overdue" // The expected charge is missing
+)
+
+// subscriptionHistoryDays is how far back the history is searched, enough for two yearly charges
+const subscriptionHistoryDays = 400
+
+// subscriptionAmountTolerance is how much a charge may differ from the previous one of the
+// same payment, relative to it. Generous enough to follow price increases.
+const subscriptionAmountTolerance = 0.35
+
+// cadenceSpec describes how the charges of one cadence are spaced
+type cadenceSpec struct {
+	cadence        Cadence
+	minInterval    int // Every gap between charges, in days, is within these bounds
+	maxInterval    int
+	minMedian      int // And the median gap within these
+	maxMedian      int
+	toleranceDays  int // How early or late a charge may arrive before it is reported
+	minOccurrences int
+	next           func(time.Time) time.Time
+}
+
+// cadenceSpecs lists the recognized cadences, shortest first
+var cadenceSpecs = []cadenceSpec{
+	{CadenceWeekly, 4, 10, 6, 8, 2, 3, func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }},
+	{CadenceMonthly, 20, 40, 27, 33, 4, 3, func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+	{CadenceYearly, 330, 400, 355, 375, 10, 2, func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+}
+
+// Subscription is a recurring payment found in the transaction history
+type Subscription struct {
+	PartnerName        string
+	Cadence            Cadence
+	Amount             Money // Price of the latest charge, positive
+	PreviousAmount     Money // Price of the charge before, in the same currency
+	Occurrences        int
+	FirstChargeDate    time.Time
+	PreviousChargeDate time.Time
+	LastChargeDate     time.Time
+	NextChargeDate     time.Time // Expected from the cadence
+	Status             SubscriptionStatus
+}
+
+// subscriptionCharge is one debit of a recurring payment candidate
+type subscriptionCharge struct {
+	partner string
+	date    time.Time
+	amount  Money // Positive
+}
+
+// detectSubscriptions finds recurring payments: debits of the same partner with similar amounts
+// and a regular weekly, monthly or yearly cadence. Payments that missed two charges in a row
+// are assumed to be cancelled and left out.
+func detectSubscriptions(transactions []StoredTransaction, now time.Time) []Subscription {
+	byPartner := make(map[string][]subscriptionCharge)
+	var partners []string
+	for _, t := range transactions {
+		if t.RemovedAt != nil {
+			continue
+		}
+		if t.Amount.Minor >= 0 {
+			continue
+		}
+		partner := normalizePartnerName(t.PartnerName)
+		if partner == "" {
+			continue
+		}
+		if _, seen := byPartner[partner]; !seen {
+			partners = append(partners, partner)
+		}
+		byPartner[partner] = append(byPartner[partner], subscriptionCharge{partner: t.PartnerName, date: t.BookingDate, amount: t.Amount.Neg()})
+	}
+
+	var subscriptions []Subscription
+	for _, partner := range partners {
+		charges := byPartner[partner]
+		slices.SortStableFunc(charges, func(a, b subscriptionCharge) int { return a.date.Compare(b.date) })
+		for _, series := range splitChargesByAmount(charges) {
+			if subscription, ok := classifySubscription(series, now); ok {
+				subscriptions = append(subscriptions, subscription)
+			}
+		}
+	}
+
+	slices.SortStableFunc(subscriptions, func(a, b Subscription) int { return a.NextChargeDate.Compare(b.NextChargeDate) })
+	return subscriptions
+}
+
+// splitChargesByAmount separates the charges of one partner into series of similar amounts in
+// the same currency, each charge joining the series whose latest charge is closest in amount
+func splitChargesByAmount(charges []subscriptionCharge) [][]subscriptionCharge {
+	var series [][]subscriptionCharge
+	for _, charge := range charges {
+		best, bestDiff := -1, subscriptionAmountTolerance
+		for i, s := range series {
+			last := s[len(s)-1].amount
+			if last.currency() != charge.amount.currency() {
+				continue
+			}
+			if diff := math.Abs(float64(charge.amount.Minor-last.Minor)) / float64(last.Minor); diff <= bestDiff {
+				best, bestDiff = i, diff
+			}
+		}
+		if best < 0 {
+			series = append(series, []subscriptionCharge{charge})
+		} else {
+			series[best] = append(series[best], charge)
+		}
+	}
+	return series
+}
+
+// classifySubscription returns the subscription a series of charges forms, if its gaps fit a cadence
+func classifySubscription(charges []subscriptionCharge, now time.Time) (Subscription, bool) {
+	if len(charges) < 2 {
+		return Subscription{}, false
+	}
+	intervals := make([]int, len(charges)-1)
+	for i := 1; i < len(charges); i++ {
+		intervals[i-1] = int(math.Round(charges[i].date.Sub(charges[i-1].date).Hours() / 24))
+	}
+	sorted := slices.Clone(intervals)
+	slices.Sort(sorted)
+	median := sorted[len(sorted)/2]
+
+	for _, spec := range cadenceSpecs {
+		if len(charges) < spec.minOccurrences || median < spec.minMedian || median > spec.maxMedian {
+			continue
+		}
+		if sorted[0] < spec.minInterval || sorted[len(sorted)-1] > spec.maxInterval {
+			continue
+		}
+
+		last, previous := charges[len(charges)-1], charges[len(charges)-2]
+		subscription := Subscription{
+			PartnerName:        last.partner,
+			Cadence:            spec.cadence,
+			Amount:             last.amount,
+			PreviousAmount:     previous.amount,
+			Occurrences:        len(charges),
+			FirstChargeDate:    charges[0].date,
+			PreviousChargeDate: previous.date,
+			LastChargeDate:     last.date,
+			NextChargeDate:     spec.next(last.date),
+			Status:             SubscriptionActive,
+		}
+		if now.After(subscription.NextChargeDate.AddDate(0, 0, spec.toleranceDays)) {
+			subscription.Status = SubscriptionOverdue
+		}
+		if now.After(spec.next(subscription.NextChargeDate).AddDate(0, 0, spec.toleranceDays)) {
+			return Subscription{}, false
+		}
+		return subscription, true
+	}
+	return Subscription{}, false
+}
+
+// cadenceSpecOf returns the spec of a cadence
+func cadenceSpecOf(cadence Cadence) cadenceSpec {
+	for _, spec := range cadenceSpecs {
+		if spec.cadence == cadence {
+			return spec
+		}
+	}
+	return cadenceSpecs[1]
+}
+
+// subscriptionAlertItems reports subscriptions whose expected charge is missing, whose latest
+// charge came early, or whose price went up. Each is keyed by the charge it is about, so it is
+// reported once.
+func subscriptionAlertItems(subscriptions []Subscription) []AlertItem {
+	var items []AlertItem
+	for _, s := range subscriptions {
+		prefix := fmt.Sprintf("subscription|%s|%s", normalizePartnerName(s.PartnerName), s.Cadence)
+		spec := cadenceSpecOf(s.Cadence)
+
+		if s.Status == SubscriptionOverdue {
+			items = append(items, AlertItem{
+				Key: prefix + "|missing|" + s.NextChargeDate.Format("2006-01-02"),
+				Line: fmt.Sprintf("**%s** (%s, `%s`): expected charge on %s is missing",
+					s.PartnerName, s.Cadence, s.Amount, s.NextChargeDate.Format(statementDateLayout)),
+			})
+		}
+		expected := spec.next(s.PreviousChargeDate)
+		if s.LastChargeDate.Before(expected.AddDate(0, 0, -spec.toleranceDays)) {
+			items = append(items, AlertItem{
+				Key: prefix + "|early|" + s.LastChargeDate.Format("2006-01-02"),
+				Line: fmt.Sprintf("**%s** (%s): charged on %s, expected on %s",
+					s.PartnerName, s.Cadence, s.LastChargeDate.Format(statementDateLayout), expected.Format(statementDateLayout)),
+			})
+		}
+		if s.Amount.Minor > s.PreviousAmount.Minor {
+			items = append(items, AlertItem{
+				Key: prefix + "|price|" + s.LastChargeDate.Format("2006-01-02"),
+				Line: fmt.Sprintf("**%s** (%s): price went up from `%s` to `%s` (+%.0f%%)",
+					s.PartnerName, s.Cadence, s.PreviousAmount, s.Amount, (float64(s.Amount.Minor)/float64(s.PreviousAmount.Minor)-1)*100),
+			})
+		}
+	}
+	return items
+}
+
+// refreshSubscriptions detects the subscriptions in the stored history and replaces the stored list
+func refreshSubscriptions(storage *Storage, now time.Time) ([]Subscription, error) {
+	transactions, err := listSpending(storage, now.AddDate(0, 0, -subscriptionHistoryDays), now)
+	if err != nil {
+		return nil, err
+	}
+	subscriptions := detectSubscriptions(transactions, now)
+	if err := storage.Subscriptions.ReplaceSubscriptions(subscriptions); err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// checkSubscriptions refreshes the subscriptions list and alerts every channel about
+// missing, early and more expensive charges
+func checkSubscriptions(storage *Storage, notifiers []Notifier, now time.Time) error {
+	subscriptions, err := refreshSubscriptions(storage, now)
+	if err != nil {
+		return err
+	}
+
+	overdue := 0
+	for _, s := range subscriptions {
+		if s.Status == SubscriptionOverdue {
+			overdue++
+		}
+	}
+	fmt.Printf("Tracking %d subscriptions, %d overdue\n", len(subscriptions), overdue)
+	return sendAlerts(storage.Alerts, notifiers, AlertSubscription, "🔁 N26 Subscription Alerts", subscriptionAlertItems(subscriptions))
+}
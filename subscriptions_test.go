@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// chargeHistory builds stored debits of one partner on the given dates with the given amounts
+func chargeHistory(partner string, dates []string, amounts []string) []StoredTransaction {
+	var transactions []StoredTransaction
+	for i, date := range dates {
+		transactions = append(transactions, StoredTransaction{
+			Transaction: Transaction{BookingDate: statementDay(date), PartnerName: partner, Amount: eur(amounts[i])},
+		})
+	}
+	return transactions
+}
+
+func TestDetectSubscriptions(t *testing.T) {
+	now := time.Date(2025, 10, 20, 0, 0, 0, 0, time.UTC)
+	var history []StoredTransaction
+	history = append(history, chargeHistory("Spotify AB",
+		[]string{"05.07.2025", "05.08.2025", "05.09.2025", "05.10.2025"},
+		[]string{"-10,99", "-10,99", "-10,99", "-12,99"})...)
+	history = append(history, chargeHistory("Gym Club",
+		[]string{"17.09.2025", "24.09.2025", "01.10.2025", "08.10.2025"},
+		[]string{"-5,00", "-5,00", "-5,00", "-5,00"})...)
+	// Irregular purchases and a salary are not subscriptions
+	history = append(history, chargeHistory("Supermarket",
+		[]string{"02.09.2025", "03.09.2025", "20.09.2025", "14.10.2025"},
+		[]string{"-45,10", "-12,00", "-80,00", "-33,00"})...)
+	history = append(history, chargeHistory("Employer",
+		[]string{"30.07.2025", "30.08.2025", "30.09.2025"},
+		[]string{"2500,00", "2500,00", "2500,00"})...)
+	// Amazon Prime every month among regular purchases
+	history = append(history, chargeHistory("AMAZON",
+		[]string{"15.07.2025", "20.07.2025", "15.08.2025", "15.09.2025", "02.10.2025", "15.10.2025"},
+		[]string{"-8,99", "-54,20", "-8,99", "-8,99", "-129,00", "-8,99"})...)
+
+	subscriptions := detectSubscriptions(history, now)
+	found := make(map[string]Subscription)
+	for _, s := range subscriptions {
+		found[s.PartnerName] = s
+	}
+	if len(subscriptions) != 3 {
+		t.Fatalf("detectSubscriptions() found %d subscriptions, want 3: %+v", len(subscriptions), subscriptions)
+	}
+
+	spotify := found["Spotify AB"]
+	if spotify.Cadence != CadenceMonthly || spotify.Amount != eur("12,99") || spotify.PreviousAmount != eur("10,99") || spotify.Occurrences != 4 {
+		t.Errorf("spotify = %+v, want monthly 12.99 after 10.99 with 4 charges", spotify)
+	}
+	if !spotify.NextChargeDate.Equal(statementDay("05.11.2025")) || spotify.Status != SubscriptionActive {
+		t.Errorf("spotify next charge = %v %s, want 2025-11-05 active", spotify.NextChargeDate, spotify.Status)
+	}
+
+	gym := found["Gym Club"]
+	if gym.Cadence != CadenceWeekly || gym.Status != SubscriptionOverdue {
+		t.Errorf("gym = %+v, want an overdue weekly subscription", gym)
+	}
+
+	amazon := found["AMAZON"]
+	if amazon.Cadence != CadenceMonthly || amazon.Occurrences != 4 {
+		t.Errorf("amazon = %+v, want monthly with 4 charges", amazon)
+	}
+}
+
+func TestDetectSubscriptionsYearly(t *testing.T) {
+	now := time.Date(2025, 11, 20, 0, 0, 0, 0, time.UTC)
+	history := chargeHistory("Domain Registrar", []string{"12.11.2024", "10.11.2025"}, []string{"-15,00", "-15,00"})
+
+	subscriptions := detectSubscriptions(history, now)
+	if len(subscriptions) != 1 || subscriptions[0].Cadence != CadenceYearly {
+		t.Fatalf("detectSubscriptions() = %+v, want one yearly subscription", subscriptions)
+	}
+}
+
+func TestDetectSubscriptionsDropsCancelled(t *testing.T) {
+	now := time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC)
+	history := chargeHistory("Netflix", []string{"05.08.2025", "05.09.2025", "05.10.2025"}, []string{"-12,99", "-12,99", "-12,99"})
+
+	if subscriptions := detectSubscriptions(history, now); len(subscriptions) != 0 {
+		t.Errorf("detectSubscriptions() = %+v, want none after two missed charges", subscriptions)
+	}
+}
+
+func TestSubscriptionAlertItems(t *testing.T) {
+	day := func(month time.Month, d int) time.Time { return time.Date(2025, month, d, 0, 0, 0, 0, time.UTC) }
+	subscriptions := []Subscription{
+		{PartnerName: "Spotify AB", Cadence: CadenceMonthly, Amount: eur("12,99"), PreviousAmount: eur("10,99"),
+			PreviousChargeDate: day(9, 5), LastChargeDate: day(10, 5), NextChargeDate: day(11, 5), Status: SubscriptionActive},
+		{PartnerName: "Gym Club", Cadence: CadenceWeekly, Amount: eur("5,00"), PreviousAmount: eur("5,00"),
+			PreviousChargeDate: day(9, 15), LastChargeDate: day(9, 22), NextChargeDate: day(9, 29), Status: SubscriptionOverdue},
+		{PartnerName: "Cloud Storage", Cadence: CadenceMonthly, Amount: eur("2,99"), PreviousAmount: eur("2,99"),
+			PreviousChargeDate: day(9, 28), LastChargeDate: day(10, 18), NextChargeDate: day(11, 18), Status: SubscriptionActive},
+		{PartnerName: "News", Cadence: CadenceMonthly, Amount: eur("9,99"), PreviousAmount: eur("9,99"),
+			PreviousChargeDate: day(9, 10), LastChargeDate: day(10, 10), NextChargeDate: day(11, 10), Status: SubscriptionActive},
+	}
+
+	items := subscriptionAlertItems(subscriptions)
+	var keys []string
+	for _, item := range items {
+		keys = append(keys, item.Key)
+	}
+	want := []string{
+		"subscription|spotify ab|monthly|price|2025-10-05",
+		"subscription|gym club|weekly|missing|2025-09-29",
+		"subscription|cloud storage|monthly|early|2025-10-18",
+	}
+	if strings.Join(keys, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("alert keys = %v, want %v", keys, want)
+	}
+	if !strings.Contains(items[0].Line, "10,99 EUR` to `12,99 EUR` (+18%)") {
+		t.Errorf("price line = %q, want the old and new price", items[0].Line)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+)
+
+// ChangeKind classifies how a transaction differs from the stored history
+type ChangeKind string
+
+const (
+	ChangeNew      ChangeKind = "new"      // Not seen before
+	ChangeUpdated  ChangeKind = "updated"  // Amount changed, e.g. after card settlement
+	ChangeReversed ChangeKind = "reversed" // Refunds an earlier transaction of the same partner
+	ChangeRemoved  ChangeKind = "removed"  // Disappeared although its booking date is still covered
+)
+
+// changeKinds lists every change kind in the order notifications are sent
+var changeKinds = []ChangeKind{ChangeNew, ChangeUpdated, ChangeReversed, ChangeRemoved}
+
+// maxSettlementShiftDays is how far the booking date may move when a card transaction settles
+const maxSettlementShiftDays = 3
+
+// TransactionChange is a difference between a statement and the stored history
+type TransactionChange struct {
+	Kind   ChangeKind
+	Record StatementRecord
+	// Previous is the earlier transaction an update or reversal refers to:
+	// the stored version before the amount changed, or the reversed transaction
+	Previous *StatementRecord
+}
+
+// detectTransactionChanges compares a parsed statement with the stored history of the period it covers
+func detectTransactionChanges(transactionRepo TransactionRepository, statement *ParsedStatement) ([]TransactionChange, error) {
+	from, to := statement.Document.PeriodStart, statement.Document.PeriodEnd
+	if from.IsZero() || to.IsZero() {
+		return nil, nil
+	}
+
+	// The requested period starts at the time of the request, not at midnight: when the PDF
+	// does not state its period, a transaction booked on the first day may be missing from
+	// it without having been removed
+	if !statement.PeriodStated {
+		from = from.AddDate(0, 0, 1)
+	}
+	stored, err := transactionRepo.ListTransactions(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every space has its own statement, the transactions of the others are not missing from it
+	var history []StatementRecord
+	for _, t := range spaceTransactions(stored, statement.Document.Space) {
+		if t.RemovedAt != nil {
+			continue
+		}
+		history = append(history, StatementRecord{
+			Key:         t.Key,
+			KeyVersion:  statementKeyVersion,
+			BookingDate: t.BookingDate,
+			PartnerName: t.PartnerName,
+			Amount:      t.Amount,
+			Category:    t.Category,
+			Space:       t.Space,
+		})
+	}
+
+	return classifyTransactionChanges(statementRecords(statement.Transactions), history), nil
+}
+
+// classifyTransactionChanges returns how the parsed transactions differ from the stored ones.
+// A parsed transaction that negates an earlier one of a similar partner is a reversal,
+// one that replaces a vanished transaction of a similar partner booked within a few days
+// is an amount change. Remaining parsed transactions are new, remaining stored ones removed.
+func classifyTransactionChanges(parsed, stored []StatementRecord) []TransactionChange {
+	storedKeys := make(map[string]bool, len(stored))
+	for _, record := range stored {
+		storedKeys[record.Key] = true
+	}
+	parsedKeys := make(map[string]bool, len(parsed))
+	for _, record := range parsed {
+		parsedKeys[record.Key] = true
+	}
+
+	var added []StatementRecord
+	for _, record := range parsed {
+		if !storedKeys[record.Key] {
+			added = append(added, record)
+		}
+	}
+	var missing []StatementRecord
+	for _, record := range stored {
+		if !parsedKeys[record.Key] {
+			missing = append(missing, record)
+		}
+	}
+
+	var changes []TransactionChange
+	reversed := make(map[string]bool)
+	matched := make(map[string]bool)
+
+	// Reversals may refer to any known transaction, including one added in this statement
+	candidates := append(append([]StatementRecord{}, stored...), added...)
+	for _, record := range added {
+		if original := findReversedTransaction(record, candidates, reversed); original != nil {
+			reversed[original.Key] = true
+			matched[record.Key] = true
+			changes = append(changes, TransactionChange{Kind: ChangeReversed, Record: record, Previous: original})
+		}
+	}
+
+	for _, record := range added {
+		if matched[record.Key] {
+			continue
+		}
+		if previous := findUpdatedTransaction(record, missing, matched); previous != nil {
+			matched[previous.Key] = true
+			matched[record.Key] = true
+			changes = append(changes, TransactionChange{Kind: ChangeUpdated, Record: record, Previous: previous})
+			continue
+		}
+		changes = append(changes, TransactionChange{Kind: ChangeNew, Record: record})
+	}
+
+	for _, record := range missing {
+		if !matched[record.Key] {
+			changes = append(changes, TransactionChange{Kind: ChangeRemoved, Record: record})
+		}
+	}
+	return changes
+}
+
+// findReversedTransaction returns the transaction a record reverses: the most similar
+// partner's transaction of the opposite amount booked on or before it, nil when there is none
+func findReversedTransaction(record StatementRecord, candidates []StatementRecord, reversed map[string]bool) *StatementRecord {
+	if record.BookingDate.IsZero() {
+		return nil
+	}
+
+	var best *StatementRecord
+	bestScore := partnerMatchThreshold
+	for i := range candidates {
+		candidate := &candidates[i]
+		if candidate.Key == record.Key || reversed[candidate.Key] {
+			continue
+		}
+		if candidate.Amount.Minor != -record.Amount.Minor {
+			continue
+		}
+		if candidate.BookingDate.IsZero() || candidate.BookingDate.After(record.BookingDate) {
+			continue
+		}
+		if score := partnerSimilarity(candidate.PartnerName, record.PartnerName); score >= bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	return best
+}
+
+// findUpdatedTransaction returns the vanished transaction a record replaces: the most similar
+// partner's transaction of a different amount with the same sign booked within a few days,
+// nil when there is none
+func findUpdatedTransaction(record StatementRecord, missing []StatementRecord, matched map[string]bool) *StatementRecord {
+	if record.BookingDate.IsZero() {
+		return nil
+	}
+
+	var best *StatementRecord
+	bestScore := partnerMatchThreshold
+	for i := range missing {
+		candidate := &missing[i]
+		if matched[candidate.Key] {
+			continue
+		}
+		if candidate.Amount.Minor == record.Amount.Minor || (candidate.Amount.Minor < 0) != (record.Amount.Minor < 0) {
+			continue
+		}
+		// Rounded to whole days, as a day across a daylight saving change is 23 or 25 hours long
+		shift := math.Abs(math.Round(record.BookingDate.Sub(candidate.BookingDate).Hours() / 24))
+		if candidate.BookingDate.IsZero() || shift > maxSettlementShiftDays {
+			continue
+		}
+		if score := partnerSimilarity(candidate.PartnerName, record.PartnerName); score >= bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	return best
+}
+
+// applyTransactionChanges brings the stored history in line with the detected changes before
+// the statement is recorded: updated transactions keep their history and notified state under
+// the new key, removed ones are flagged so they are not reported again
+func applyTransactionChanges(storage *Storage, changes []TransactionChange) error {
+	var removed []string
+	for _, change := range changes {
+		switch change.Kind {
+		case ChangeUpdated:
+			if err := moveStatementKey(storage, change.Previous.Key, change.Record); err != nil {
+				return err
+			}
+			log.Printf("Transaction %s %q changed amount from %s to %s",
+				formatStatementDate(change.Record.BookingDate), change.Record.PartnerName, change.Previous.Amount, change.Record.Amount)
+		case ChangeRemoved:
+			removed = append(removed, change.Record.Key)
+		}
+	}
+
+	if err := storage.Transactions.MarkRemoved(removed); err != nil {
+		return err
+	}
+	return nil
+}
+
+// moveStatementKey moves a statement's notified state, history and deliveries to a new key
+func moveStatementKey(storage *Storage, oldKey string, record StatementRecord) error {
+	if err := storage.Statements.Rekey(oldKey, record); err != nil {
+		return err
+	}
+	if err := storage.Transactions.RekeyTransaction(oldKey, record.Key); err != nil {
+		return err
+	}
+	if err := storage.Deliveries.RekeyDeliveries(oldKey, record.Key); err != nil {
+		return err
+	}
+	return nil
+}
+
+// summarizeTransactionChanges returns a one-line count of the changes per kind, e.g. "2 new, 1 updated"
+func summarizeTransactionChanges(changes []TransactionChange) string {
+	counts := make(map[ChangeKind]int)
+	for _, change := range changes {
+		counts[change.Kind]++
+	}
+
+	var parts []string
+	for _, kind := range changeKinds {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClassifyTransactionChanges(t *testing.T) {
+	stored := statementRecords([]Transaction{
+		{BookingDate: statementDay("01.10.2025"), PartnerName: "Coffee Shop", Amount: eur("-2,50")},
+		{BookingDate: statementDay("02.10.2025"), PartnerName: "GAS STATION 123", Amount: eur("-1,00")},
+		{BookingDate: statementDay("03.10.2025"), PartnerName: "Online Shop", Amount: eur("-30,00")},
+		{BookingDate: statementDay("04.10.2025"), PartnerName: "Bakery", Amount: eur("-3,00")},
+	})
+	parsed := statementRecords([]Transaction{
+		{BookingDate: statementDay("01.10.2025"), PartnerName: "Coffee Shop", Amount: eur("-2,50")},      // unchanged
+		{BookingDate: statementDay("03.10.2025"), PartnerName: "Gas Station 123", Amount: eur("-48,20")}, // settled
+		{BookingDate: statementDay("03.10.2025"), PartnerName: "Online Shop", Amount: eur("-30,00")},     // unchanged
+		{BookingDate: statementDay("06.10.2025"), PartnerName: "ONLINE SHOP", Amount: eur("30,00")},      // refund
+		{BookingDate: statementDay("07.10.2025"), PartnerName: "Supermarket", Amount: eur("-45,10")},     // new
+		// Bakery disappeared
+	})
+
+	changes := classifyTransactionChanges(parsed, stored)
+
+	byKind := make(map[ChangeKind][]TransactionChange)
+	for _, change := range changes {
+		byKind[change.Kind] = append(byKind[change.Kind], change)
+	}
+
+	if got := byKind[ChangeUpdated]; len(got) != 1 || got[0].Record != parsed[1] || got[0].Previous.Key != stored[1].Key {
+		t.Errorf("updated = %+v, want the gas station settling from -1,00 to -48,20", got)
+	}
+	if got := byKind[ChangeReversed]; len(got) != 1 || got[0].Record != parsed[3] || got[0].Previous.Key != stored[2].Key {
+		t.Errorf("reversed = %+v, want the online shop refund", got)
+	}
+	if got := byKind[ChangeNew]; len(got) != 1 || got[0].Record != parsed[4] {
+		t.Errorf("new = %+v, want the supermarket", got)
+	}
+	if got := byKind[ChangeRemoved]; len(got) != 1 || got[0].Record != stored[3] {
+		t.Errorf("removed = %+v, want the bakery", got)
+	}
+	if summary := summarizeTransactionChanges(changes); summary != "1 new, 1 updated, 1 reversed, 1 removed" {
+		t.Errorf("summarizeTransactionChanges() = %q", summary)
+	}
+}
+
+func TestClassifyTransactionChangesKeepsUnrelatedPartnersApart(t *testing.T) {
+	stored := statementRecords([]Transaction{{BookingDate: statementDay("01.10.2025"), PartnerName: "Coffee Shop", Amount: eur("-2,50")}})
+	parsed := statementRecords([]Transaction{{BookingDate: statementDay("01.10.2025"), PartnerName: "Hardware Store", Amount: eur("-4,00")}})
+
+	changes := classifyTransactionChanges(parsed, stored)
+	if len(changes) != 2 || changes[0].Kind != ChangeNew || changes[1].Kind != ChangeRemoved {
+		t.Errorf("classifyTransactionChanges() = %+v, want one new and one removed", changes)
+	}
+}
+
+func TestStatementUpdatesAreNotifiedAsUpdates(t *testing.T) {
+	storage, err := OpenStorage("memory://")
+	if err != nil {
+		t.Fatalf("failed to open memory storage: %v", err)
+	}
+	discord := &fakeNotifier{channel: "discord"}
+	notifiers := []Notifier{discord}
+
+	period := StatementDocument{
+		PeriodStart: time.Date(2025, 9, 15, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:   time.Date(2025, 10, 15, 0, 0, 0, 0, time.UTC),
+	}
+
+	// run processes a statement the way processStatement does after parsing
+	run := func(transactions ...Transaction) {
+		t.Helper()
+		statement := &ParsedStatement{Document: period, Transactions: transactions}
+		statement.Document.SHA256 = time.Now().String()
+
+		changes, err := detectTransactionChanges(storage.Transactions, statement)
+		if err != nil {
+			t.Fatalf("detectTransactionChanges() failed: %v", err)
+		}
+		if err := applyTransactionChanges(storage, changes); err != nil {
+			t.Fatalf("applyTransactionChanges() failed: %v", err)
+		}
+		if err := recordStatement(storage.Transactions, statement); err != nil {
+			t.Fatalf("recordStatement() failed: %v", err)
+		}
+		if err := notifyStatement(globalConfig, statement, storage, notifiers, changes); err != nil {
+			t.Fatalf("notifyStatement() failed: %v", err)
+		}
+	}
+
+	fuel := Transaction{BookingDate: statementDay("02.10.2025"), PartnerName: "Gas Station", Amount: eur("-1,00")}
+	shop := Transaction{BookingDate: statementDay("03.10.2025"), PartnerName: "Online Shop", Amount: eur("-30,00")}
+	run(fuel, shop)
+
+	settled := Transaction{BookingDate: statementDay("03.10.2025"), PartnerName: "Gas Station", Amount: eur("-48,20")}
+	refund := Transaction{BookingDate: statementDay("05.10.2025"), PartnerName: "Online Shop", Amount: eur("30,00")}
+	run(settled, shop, refund)
+
+	if len(discord.sent) != 3 {
+		t.Fatalf("discord received %d notifications, want new, updated and reversed", len(discord.sent))
+	}
+	if kind := discord.sent[0].Kind; kind != ChangeNew || len(discord.sent[0].Changes) != 2 {
+		t.Errorf("first notification = %s with %d changes, want 2 new", kind, len(discord.sent[0].Changes))
+	}
+	if updated := discord.sent[1]; updated.Kind != ChangeUpdated || updated.Changes[0].Previous.Amount != eur("-1,00") {
+		t.Errorf("second notification = %+v, want the fuel update from -1,00", updated)
+	}
+	if reversed := discord.sent[2]; reversed.Kind != ChangeReversed || reversed.Changes[0].Record.Amount != eur("30,00") {
+		t.Errorf("third notification = %+v, want the shop refund", reversed)
+	}
+
+	// The settled amount replaced the pending one in the history
+	history, err := storage.Transactions.ListTransactions(period.PeriodStart, period.PeriodEnd)
+	if err != nil {
+		t.Fatalf("ListTransactions() failed: %v", err)
+	}
+	if len(history) != 3 || history[0].Amount != eur("-48,20") {
+		t.Errorf("history = %+v, want the settled fuel amount and no duplicate", history)
+	}
+
+	// Nothing changes on the next run
+	run(settled, shop, refund)
+	if len(discord.sent) != 3 {
+		t.Errorf("discord received %d notifications after an unchanged run, want 3", len(discord.sent))
+	}
+}
+
+func TestDetectTransactionChangesOnTheFirstDay(t *testing.T) {
+	for _, stated := range []bool{true, false} {
+		storage, err := OpenStorage("memory://")
+		if err != nil {
+			t.Fatalf("failed to open memory storage: %v", err)
+		}
+		document := StatementDocument{PeriodStart: statementDay("01.10.2025"), PeriodEnd: statementDay("31.10.2025")}
+		rent := Transaction{BookingDate: statementDay("01.10.2025"), PartnerName: "Landlord", Amount: eur("-500,00")}
+		first := &ParsedStatement{Document: document, PeriodStated: true, Transactions: []Transaction{rent}}
+		first.Document.SHA256 = "first"
+		if err := recordStatement(storage.Transactions, first); err != nil {
+			t.Fatalf("recordStatement() failed: %v", err)
+		}
+
+		// The rent is missing from the next statement of the same period
+		coffee := Transaction{BookingDate: statementDay("02.10.2025"), PartnerName: "Coffee Shop", Amount: eur("-2,50")}
+		second := &ParsedStatement{Document: document, PeriodStated: stated, Transactions: []Transaction{coffee}}
+		changes, err := detectTransactionChanges(storage.Transactions, second)
+		if err != nil {
+			t.Fatalf("detectTransactionChanges() failed: %v", err)
+		}
+
+		removed := 0
+		for _, change := range changes {
+			if change.Kind == ChangeRemoved {
+				removed++
+			}
+		}
+		// Only a stated period fully covers its first day
+		if want := map[bool]int{true: 1, false: 0}[stated]; removed != want {
+			t.Errorf("period stated %v: detectTransactionChanges() = %+v, want %d removed", stated, changes, want)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TransactionRepository defines the interface for the local transaction history
+type TransactionRepository interface {
+	SaveDocument(doc StatementDocument) (int64, error)
+	RecordTransactions(documentID int64, transactions []Transaction) error
+	ListTransactions(from, to time.Time) ([]StoredTransaction, error)
+	RekeyTransaction(oldKey, newKey string) error
+	MarkRemoved(keys []string) error
+	UpdateCategories(categories map[string]string) error
+}
+
+// StatementDocument describes a downloaded PDF statement that transactions were parsed from
+type StatementDocument struct {
+	ID          int64
+	SHA256      string
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	SizeBytes   int
+	FetchedAt   time.Time
+	Space       string // ID of the N26 Space the statement is of, empty for the main account
+}
+
+// StoredTransaction is a transaction from the local history
+type StoredTransaction struct {
+	Transaction
+	Key         string
+	DocumentID  int64 // 0 when the source statement is no longer stored
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+	RemovedAt   *time.Time // Set when a later statement no longer contained the transaction
+}
+
+// SQLTransactionRepository implements TransactionRepository on both PostgreSQL and SQLite,
+// the queries only use syntax the two have in common
+type SQLTransactionRepository struct {
+	db      *sql.DB
+	account string
+}
+
+// statementDateLayout is the DD.MM.YYYY format of dates in N26 statements
+const statementDateLayout = "02.01.2006"
+
+// NewSQLTransactionRepository creates a transaction repository for an account on a migrated database
+func NewSQLTransactionRepository(db *sql.DB, account string) *SQLTransactionRepository {
+	return &SQLTransactionRepository{db: db, account: account}
+}
+
+// SaveDocument stores the statement document, or refreshes it when the same PDF was seen before
+func (r *SQLTransactionRepository) SaveDocument(doc StatementDocument) (int64, error) {
+	query := `
+		INSERT INTO statement_documents (account, sha256, period_start, period_end, size_bytes, space, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+		ON CONFLICT (account, sha256)
+		DO UPDATE SET fetched_at = CURRENT_TIMESTAMP
+		RETURNING id
+	`
+
+	var id int64
+	err := r.db.QueryRow(query, r.account, doc.SHA256, sqlDate(doc.PeriodStart), sqlDate(doc.PeriodEnd), doc.SizeBytes, doc.Space).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save statement document: %w", err)
+	}
+	return id, nil
+}
+
+// RecordTransactions upserts the transactions parsed from a document in a single database transaction.
+// New transactions get their first-seen timestamp, known ones have last-seen refreshed and take
+// over the parsed fields, which differ after an amount change moved them to a new key.
+// Uncategorized input keeps the stored category.
+func (r *SQLTransactionRepository) RecordTransactions(documentID int64, transactions []Transaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO transactions (account, statement_key, booking_date, value_date, partner_name, amount, currency, raw_text, document_id, category, space, first_seen_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT (account, statement_key)
+		DO UPDATE SET booking_date = EXCLUDED.booking_date,
+			value_date = EXCLUDED.value_date,
+			partner_name = EXCLUDED.partner_name,
+			amount = EXCLUDED.amount,
+			raw_text = EXCLUDED.raw_text,
+			document_id = EXCLUDED.document_id,
+			category = COALESCE(EXCLUDED.category, transactions.category),
+			last_seen_at = CURRENT_TIMESTAMP,
+			removed_at = NULL
+	`
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare transaction upsert: %w", err)
+	}
+	defer stmt.Close()
+
+	keys := transactionKeys(transactions)
+	for i, t := range transactions {
+		if t.BookingDate.IsZero() {
+			return fmt.Errorf("transaction %q has no booking date", t.PartnerName)
+		}
+		var document any
+		if documentID > 0 {
+			document = documentID
+		}
+
+		if _, err := stmt.Exec(r.account, keys[i], sqlDate(t.BookingDate), sqlDate(t.ValueDate), t.PartnerName, t.Amount.decimal(), t.Amount.currency(), t.RawText, document, nullString(t.Category), t.Space); err != nil {
+			return fmt.Errorf("failed to record transaction: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transactions: %w", err)
+	}
+	return nil
+}
+
+// ListTransactions returns the stored transactions booked between from and to (inclusive), oldest first
+func (r *SQLTransactionRepository) ListTransactions(from, to time.Time) ([]StoredTransaction, error) {
+	query := `
+		SELECT statement_key, booking_date, value_date, partner_name, CAST(amount AS TEXT), currency, raw_text, document_id, category, space, first_seen_at, last_seen_at, removed_at
+		FROM transactions
+		WHERE account = $1 AND booking_date >= $2 AND booking_date <= $3
+		ORDER BY booking_date ASC, id ASC
+	`
+	rows, err := r.db.Query(query, r.account, sqlDate(from), sqlDate(to))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var transactions []StoredTransaction
+	for rows.Next() {
+		var stored StoredTransaction
+		var bookingDate time.Time
+		var valueDate sql.NullTime
+		var amount, currency string
+		var rawText sql.NullString
+		var documentID sql.NullInt64
+		var category sql.NullString
+		var removedAt sql.NullTime
+		err := rows.Scan(&stored.Key, &bookingDate, &valueDate, &stored.PartnerName, &amount,
+			&currency, &rawText, &documentID, &category, &stored.Space, &stored.FirstSeenAt, &stored.LastSeenAt, &removedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+
+		stored.BookingDate = statementDate(bookingDate)
+		stored.ValueDate = stored.BookingDate
+		if valueDate.Valid {
+			stored.ValueDate = statementDate(valueDate.Time)
+		}
+		stored.Amount, err = parseMoney(amount, strings.TrimSpace(currency))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read amount of transaction %s: %w", stored.Key, err)
+		}
+		stored.RawText = rawText.String
+		stored.DocumentID = documentID.Int64
+		stored.Category = category.String
+		stored.RemovedAt = nullTimePtr(removedAt)
+		transactions = append(transactions, stored)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list transactions: %w", err)
+	}
+
+	return transactions, nil
+}
+
+// RekeyTransaction moves a stored transaction to a new statement key, keeping its history
+func (r *SQLTransactionRepository) RekeyTransaction(oldKey, newKey string) error {
+	if oldKey == newKey {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin rekey: %w", err)
+	}
+	defer tx.Rollback()
+
+	// A row recorded under the new key would only duplicate the older, longer history
+	if _, err := tx.Exec(`
+		DELETE FROM transactions
+		WHERE account = $1 AND statement_key = $2
+		AND EXISTS (SELECT 1 FROM transactions WHERE account = $1 AND statement_key = $3)
+	`, r.account, newKey, oldKey); err != nil {
+		return fmt.Errorf("failed to clear new transaction key: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE transactions SET statement_key = $1 WHERE account = $2 AND statement_key = $3`, newKey, r.account, oldKey); err != nil {
+		return fmt.Errorf("failed to rekey transaction: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rekey: %w", err)
+	}
+	return nil
+}
+
+// MarkRemoved flags transactions that a later statement no longer contained
+func (r *SQLTransactionRepository) MarkRemoved(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE transactions SET removed_at = CURRENT_TIMESTAMP WHERE account = $1 AND statement_key = $2 AND removed_at IS NULL`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare removal: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, key := range keys {
+		if _, err := stmt.Exec(r.account, key); err != nil {
+			return fmt.Errorf("failed to mark transaction as removed: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit removals: %w", err)
+	}
+	return nil
+}
+
+// UpdateCategories sets the category of stored transactions by statement key in one transaction
+func (r *SQLTransactionRepository) UpdateCategories(categories map[string]string) error {
+	if len(categories) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE transactions SET category = $1 WHERE account = $2 AND statement_key = $3`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare category update: %w", err)
+	}
+	defer stmt.Close()
+
+	for key, category := range categories {
+		if _, err := stmt.Exec(nullString(category), r.account, key); err != nil {
+			return fmt.Errorf("failed to update transaction category: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit category updates: %w", err)
+	}
+	return nil
+}
+
+// parseStatementDate parses a DD.MM.YYYY statement date as midnight in the account's timezone
+func parseStatementDate(value string) (time.Time, error) {
+	parsed, err := time.ParseInLocation(statementDateLayout, strings.TrimSpace(value), statementLocation())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid statement date %q: %w", value, err)
+	}
+	return parsed, nil
+}
+
+// statementDate moves the day of a DATE column, which drivers return as midnight UTC, to
+// midnight in the account's timezone
+func statementDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, statementLocation())
+}
+
+// formatStatementDate formats a date the way statements show it, empty for the zero time
+func formatStatementDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(statementDateLayout)
+}
+
+// sqlDate formats a date for DATE columns (nil for the zero time)
+func sqlDate(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Format("2006-01-02")
+}